@@ -0,0 +1,309 @@
+// Package accesslog parses access log lines written with an nginx log_format
+// or an Apache LogFormat.
+package accesslog
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Named formats, usable in place of the format string.
+var NginxFormats = map[string]string{
+	"combined": `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"`,
+	"main":     `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent" "$http_x_forwarded_for"`,
+}
+
+var ApacheFormats = map[string]string{
+	"common":   `%h %l %u %t "%r" %>s %b`,
+	"combined": `%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-Agent}i"`,
+}
+
+// Types of the fields that aren't strings, by field name.
+var DefaultTypes = map[string]string{
+	"status":                 "int",
+	"body_bytes_sent":        "int",
+	"bytes_sent":             "int",
+	"request_length":         "int",
+	"connection":             "int",
+	"connection_requests":    "int",
+	"remote_port":            "int",
+	"server_port":            "int",
+	"content_length":         "int",
+	"pid":                    "int",
+	"request_time_us":        "int",
+	"request_time":           "float",
+	"upstream_response_time": "float",
+	"upstream_connect_time":  "float",
+	"upstream_header_time":   "float",
+	"msec":                   "float",
+}
+
+// Fields the message time is read from, in order of preference.
+var timeFields = []struct{ name, layout string }{
+	{"time_local", "02/Jan/2006:15:04:05 -0700"},
+	{"time_iso8601", time.RFC3339},
+	{"msec", ""},
+}
+
+// A Parser turns lines of one format into fields.
+type Parser struct {
+	re     *regexp.Regexp
+	fields []string
+	// Types by field name, int or float.
+	Types map[string]string
+}
+
+// Returns a parser of the nginx log_format, or of a name in NginxFormats.
+func Nginx(format string) (*Parser, error) {
+	if named, ok := NginxFormats[format]; ok {
+		format = named
+	}
+	var tokens []token
+	for i := 0; i < len(format); {
+		if format[i] != '$' {
+			j := strings.IndexByte(format[i:], '$')
+			if j < 0 {
+				j = len(format) - i
+			}
+			tokens = append(tokens, token{literal: format[i : i+j]})
+			i += j
+			continue
+		}
+		var name string
+		if strings.HasPrefix(format[i:], "${") {
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated ${ in %s", format)
+			}
+			name = format[i+2 : i+end]
+			i += end + 1
+		} else {
+			j := i + 1
+			for j < len(format) && isNameChar(format[j]) {
+				j++
+			}
+			name = format[i+1 : j]
+			i = j
+		}
+		if name == "" {
+			return nil, fmt.Errorf("$ without a variable name in %s", format)
+		}
+		tokens = append(tokens, token{field: name})
+	}
+	return newParser(tokens)
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// The nginx names of the Apache directives.
+var apacheDirectives = map[byte]string{
+	'a': "remote_addr",
+	'A': "server_addr",
+	'B': "body_bytes_sent",
+	'b': "body_bytes_sent",
+	'D': "request_time_us",
+	'h': "remote_addr",
+	'H': "server_protocol",
+	'I': "request_length",
+	'k': "connection_requests",
+	'l': "remote_logname",
+	'm': "request_method",
+	'O': "bytes_sent",
+	'p': "server_port",
+	'P': "pid",
+	'q': "query_string",
+	'r': "request",
+	's': "status",
+	'T': "request_time",
+	't': "time_local",
+	'u': "remote_user",
+	'U': "uri",
+	'v': "server_name",
+	'V': "server_name",
+	'X': "connection_status",
+}
+
+// Returns a parser of the Apache LogFormat, or of a name in ApacheFormats.
+// Headers become nginx style fields, %{User-Agent}i is http_user_agent.
+func Apache(format string) (*Parser, error) {
+	if named, ok := ApacheFormats[format]; ok {
+		format = named
+	}
+	var tokens []token
+	literal := ""
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			literal += format[i : i+1]
+			continue
+		}
+		i++
+		// Status conditions and the < > modifiers don't change the output.
+		for i < len(format) && strings.IndexByte("<>!,0123456789", format[i]) >= 0 {
+			i++
+		}
+		if i >= len(format) {
+			return nil, fmt.Errorf("%% without a directive in %s", format)
+		}
+		if format[i] == '%' {
+			literal += "%"
+			continue
+		}
+		arg := ""
+		if format[i] == '{' {
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 || i+end+1 >= len(format) {
+				return nil, fmt.Errorf("unterminated %%{ in %s", format)
+			}
+			arg = format[i+1 : i+end]
+			i += end + 1
+		}
+		name, err := apacheField(format[i], arg)
+		if err != nil {
+			return nil, err
+		}
+		if format[i] == 't' {
+			// %t writes the time in brackets.
+			literal += "["
+		}
+		tokens = append(tokens, token{literal: literal}, token{field: name})
+		literal = ""
+		if format[i] == 't' {
+			literal = "]"
+		}
+	}
+	tokens = append(tokens, token{literal: literal})
+	return newParser(tokens)
+}
+
+func apacheField(directive byte, arg string) (string, error) {
+	header := strings.ToLower(strings.Replace(arg, "-", "_", -1))
+	switch {
+	case directive == 'i' && arg != "":
+		return "http_" + header, nil
+	case directive == 'o' && arg != "":
+		return "sent_http_" + header, nil
+	case directive == 'C' && arg != "":
+		return "cookie_" + arg, nil
+	case directive == 'e' && arg != "":
+		return arg, nil
+	case directive == 't' && arg != "":
+		return "", fmt.Errorf("%%{%s}t: only the default time format is supported", arg)
+	}
+	if name, ok := apacheDirectives[directive]; ok && arg == "" {
+		return name, nil
+	}
+	return "", fmt.Errorf("unsupported directive %%%c", directive)
+}
+
+// A piece of a log format, either literal text or a field.
+type token struct {
+	literal string
+	field   string
+}
+
+func newParser(tokens []token) (*Parser, error) {
+	p := &Parser{Types: make(map[string]string)}
+	for _, t := range tokens {
+		if t.field != "" {
+			p.fields = append(p.fields, t.field)
+		}
+	}
+	if len(p.fields) == 0 {
+		return nil, fmt.Errorf("log format without variables")
+	}
+	re := "^"
+	n := 0
+	for _, t := range tokens {
+		if t.field == "" {
+			re += regexp.QuoteMeta(t.literal)
+			continue
+		}
+		// Lazy so the literal after the field ends it, the last field
+		// takes the rest of the line.
+		if n++; n == len(p.fields) {
+			re += "(.*)"
+		} else {
+			re += "(.*?)"
+		}
+	}
+	for k, v := range DefaultTypes {
+		p.Types[k] = v
+	}
+	var err error
+	if p.re, err = regexp.Compile(re + "$"); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Adds the fields of the line to data and returns the time of the request,
+// zero if the format has none. Fields logged as "-" are left out, $request
+// is also split into method, path and protocol.
+func (this *Parser) Parse(line string, data map[string]interface{}) (time.Time, error) {
+	m := this.re.FindStringSubmatch(line)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("line does not match the log format")
+	}
+	for i, name := range this.fields {
+		value := m[i+1]
+		if value == "-" || value == "" {
+			continue
+		}
+		data[name] = convert(value, this.Types[name])
+		if name == "request" {
+			splitRequest(value, data)
+		}
+	}
+	for _, tf := range timeFields {
+		value, ok := data[tf.name]
+		if !ok {
+			continue
+		}
+		if tf.layout == "" {
+			if f, ok := value.(float64); ok {
+				return time.Unix(int64(f), int64((f-float64(int64(f)))*1e9)), nil
+			}
+			continue
+		}
+		t, err := time.Parse(tf.layout, fmt.Sprint(value))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%s: %s", tf.name, err)
+		}
+		return t, nil
+	}
+	return time.Time{}, nil
+}
+
+// "GET /index.html HTTP/1.1"
+func splitRequest(request string, data map[string]interface{}) {
+	parts := strings.SplitN(request, " ", 3)
+	if len(parts) < 2 {
+		return
+	}
+	data["method"] = parts[0]
+	data["path"] = parts[1]
+	if len(parts) == 3 {
+		data["protocol"] = parts[2]
+	}
+}
+
+// Values that don't convert, like "0.1, 0.2" of several upstreams, stay
+// strings.
+func convert(value, typ string) interface{} {
+	switch typ {
+	case "int":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case "float":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return value
+}
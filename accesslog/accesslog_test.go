@@ -0,0 +1,72 @@
+package accesslog
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNginx(t *testing.T) {
+	p, err := Nginx(`$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent" ${request_time}s $upstream_response_time`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{}
+	ts, err := p.Parse(`10.1.2.3 - - [19/Oct/2026:13:55:36 +0800] "GET /a?b=1 HTTP/1.1" 404 153 "-" "curl/8.0" 0.012s 0.010, 0.002`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"remote_addr":            "10.1.2.3",
+		"time_local":             "19/Oct/2026:13:55:36 +0800",
+		"request":                "GET /a?b=1 HTTP/1.1",
+		"method":                 "GET",
+		"path":                   "/a?b=1",
+		"protocol":               "HTTP/1.1",
+		"status":                 int64(404),
+		"body_bytes_sent":        int64(153),
+		"http_user_agent":        "curl/8.0",
+		"request_time":           0.012,
+		"upstream_response_time": "0.010, 0.002",
+	}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("got %v\nwant %v", data, want)
+	}
+	if ts.Unix() != time.Date(2026, 10, 19, 5, 55, 36, 0, time.UTC).Unix() {
+		t.Errorf("got time %s", ts)
+	}
+	if _, err = p.Parse("garbage", data); err == nil {
+		t.Error("garbage parsed")
+	}
+}
+
+func TestApache(t *testing.T) {
+	p, err := Apache("combined")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{}
+	_, err = p.Parse(`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08 [en] (Win98; I ;Nav)"`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]interface{}{
+		"remote_addr":     "127.0.0.1",
+		"remote_user":     "frank",
+		"time_local":      "10/Oct/2000:13:55:36 -0700",
+		"status":          int64(200),
+		"body_bytes_sent": int64(2326),
+		"http_referer":    "http://www.example.com/start.html",
+		"http_user_agent": "Mozilla/4.08 [en] (Win98; I ;Nav)",
+		"path":            "/apache_pb.gif",
+	} {
+		if data[k] != v {
+			t.Errorf("%s: got %#v, want %#v", k, data[k], v)
+		}
+	}
+	for _, format := range []string{"%Z", "%{%Y}t", "no variables"} {
+		if _, err = Apache(format); err == nil {
+			t.Errorf("%s accepted", format)
+		}
+	}
+}
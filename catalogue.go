@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/millken/kaman/plugins"
+)
+
+// Prints every plugin compiled into the binary, grouped by category.
+func listPlugins(asJson bool) error {
+	names := plugins.PluginNames()
+	if asJson {
+		return json.NewEncoder(os.Stdout).Encode(names)
+	}
+	for _, category := range plugins.PluginCategories {
+		fmt.Printf("%ss:\n", category)
+		for _, name := range names[category] {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+	return nil
+}
+
+// Prints the config options of a plugin with their TOML key, type, default
+// value and description.
+func describePlugin(name string, asJson bool) error {
+	desc, err := plugins.DescribePlugin(name)
+	if err != nil {
+		return err
+	}
+	if asJson {
+		return json.NewEncoder(os.Stdout).Encode(desc)
+	}
+	fmt.Printf("%s (%s)\n\n", desc.Name, desc.Category)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tTYPE\tDEFAULT\tDESCRIPTION")
+	for _, opt := range desc.Options {
+		def, _ := json.Marshal(opt.Default)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", opt.Key, opt.Type, def, opt.Description)
+	}
+	return w.Flush()
+}
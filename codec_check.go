@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/millken/kaman/plugins"
+)
+
+type codecCheckConfig struct {
+	ConfigPath string
+	Decoder    string
+	Encoder    string
+	// Golden file the output is compared with.
+	Expect string
+	// Write the output to the golden file instead of comparing.
+	Update bool
+}
+
+type codecCheckSummary struct {
+	Lines   int
+	Decoded int
+	Encoded int
+	Failed  int
+}
+
+// Runs every line read from `in` through the named decoder and encoder and
+// writes the resulting Msg.Data and Msg.MsgBytes to `out`. Returns the number
+// of lines that matched and failed.
+func checkCodecs(decoder, encoder string, in io.Reader, out io.Writer) (summary codecCheckSummary, err error) {
+	recycleChan := make(chan *plugins.PipelinePack, 1)
+	pack := plugins.NewPipelinePack(recycleChan)
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		summary.Lines++
+		pack.MsgBytes = append(pack.MsgBytes[:0], scanner.Bytes()...)
+		fmt.Fprintf(out, "line %d: %s\n", summary.Lines, pack.MsgBytes)
+
+		if pack, err = plugins.PipeDecoder(decoder, pack); err == plugins.ErrDropped {
+			fmt.Fprintf(out, "  dropped\n")
+		} else if err != nil {
+			fmt.Fprintf(out, "  decode error: %s\n", err)
+			summary.Failed++
+		} else {
+			summary.Decoded++
+			if pack, err = plugins.PipeEncoder(encoder, pack); err != nil {
+				fmt.Fprintf(out, "  encode error: %s\n", err)
+				summary.Failed++
+			} else {
+				summary.Encoded++
+				data, _ := json.Marshal(pack.Msg.Data)
+				fmt.Fprintf(out, "  data: %s\n", data)
+				fmt.Fprintf(out, "  bytes: %s\n", pack.Msg.MsgBytes)
+			}
+		}
+		pack.Recycle()
+		pack = <-recycleChan
+	}
+	return summary, scanner.Err()
+}
+
+// Implements `kaman -test-codec`: loads the codecs of the config file, runs
+// stdin through them and optionally compares the result with a golden file.
+func runCodecCheck(cf *codecCheckConfig) (ok bool, err error) {
+	masterConf, plugConf, err := LoadConfig(cf.ConfigPath)
+	if err != nil {
+		return false, fmt.Errorf("read config failed, err: %s", err)
+	}
+	plugins.ShareDir = masterConf.ShareDir
+	pipeline := plugins.NewPipeLine()
+	if err = pipeline.LoadConfig(plugConf); err != nil {
+		return false, fmt.Errorf("load config failed, err: %s", err)
+	}
+	err = pipeline.InitCodecs()
+	defer pipeline.StopCodecs()
+	if err != nil {
+		return false, err
+	}
+	if cf.Decoder != "" && !plugins.HasDecoder(cf.Decoder) {
+		return false, fmt.Errorf("decoder %s not found in %s", cf.Decoder, cf.ConfigPath)
+	}
+	if cf.Encoder != "" && !plugins.HasEncoder(cf.Encoder) {
+		return false, fmt.Errorf("encoder %s not found in %s", cf.Encoder, cf.ConfigPath)
+	}
+
+	var out bytes.Buffer
+	summary, err := checkCodecs(cf.Decoder, cf.Encoder, os.Stdin, &out)
+	if err != nil {
+		return false, err
+	}
+	ok = summary.Failed == 0
+
+	switch {
+	case cf.Expect != "" && cf.Update:
+		if err = ioutil.WriteFile(cf.Expect, out.Bytes(), 0644); err != nil {
+			return false, err
+		}
+		ok = true
+	case cf.Expect != "":
+		expected, err := ioutil.ReadFile(cf.Expect)
+		if err != nil {
+			return false, err
+		}
+		os.Stdout.Write(out.Bytes())
+		if !bytes.Equal(expected, out.Bytes()) {
+			fmt.Fprintf(os.Stderr, "output differs from %s at line %d\n",
+				cf.Expect, firstDiffLine(expected, out.Bytes()))
+			return false, nil
+		}
+		// Expected failures are part of the golden file.
+		ok = true
+	default:
+		os.Stdout.Write(out.Bytes())
+	}
+
+	fmt.Fprintf(os.Stderr, "lines: %d, decoded: %d, encoded: %d, failed: %d\n",
+		summary.Lines, summary.Decoded, summary.Encoded, summary.Failed)
+	return ok, nil
+}
+
+func firstDiffLine(a, b []byte) int {
+	al := bytes.Split(a, []byte{'\n'})
+	bl := bytes.Split(b, []byte{'\n'})
+	for i := 0; i < len(al) && i < len(bl); i++ {
+		if !bytes.Equal(al[i], bl[i]) {
+			return i + 1
+		}
+	}
+	if len(al) < len(bl) {
+		return len(al) + 1
+	}
+	return len(bl) + 1
+}
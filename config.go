@@ -44,6 +44,26 @@ type MasterConfig struct {
 	PidFile               string        `toml:"pid_file"`
 	Hostname              string
 	MaxMessageSize        uint32 `toml:"max_message_size"`
+	// Report server, the -reportaddr and -admin-token flags override these.
+	ReportAddr        string `toml:"report_addr"`
+	ReportTLSCert     string `toml:"report_tls_cert"`
+	ReportTLSKey      string `toml:"report_tls_key"`
+	ReportTLSClientCA string `toml:"report_tls_client_ca"`
+	ReportUser        string `toml:"report_user"`
+	ReportPassword    string `toml:"report_password"`
+	ReportToken       string `toml:"report_token"`
+	ReportPprof       bool   `toml:"report_pprof"`
+	AdminToken        string `toml:"admin_token"`
+	// Seconds between the samples of the throughput history, which must be
+	// positive, and the number kept, a size of 0 disables the history.
+	HistoryResolution int `toml:"history_resolution"`
+	HistorySize       int `toml:"history_size"`
+	// Traces the trace_sample fraction of the messages, or those with the
+	// trace_field matching the trace_value regex.
+	TraceSample float64 `toml:"trace_sample"`
+	TraceField  string  `toml:"trace_field"`
+	TraceValue  string  `toml:"trace_value"`
+	TraceSize   int     `toml:"trace_size"`
 }
 
 func ReplaceEnvsFile(path string) (string, error) {
@@ -76,10 +96,12 @@ func LoadConfig(configPath string) (masterConfig *MasterConfig, plugConfig map[s
 		MaxMsgTimerInject:     10,
 		MaxPackIdle:           idle,
 		BaseDir:               filepath.FromSlash("/var/cache/hekad"),
-		ShareDir:              filepath.FromSlash("/usr/share/heka"),
+		ShareDir:              filepath.FromSlash("/usr/share/kaman"),
 		SampleDenominator:     1000,
 		PidFile:               "",
 		Hostname:              hostname,
+		HistoryResolution:     10,
+		HistorySize:           8640,
 	}
 
 	var configFile map[string]toml.Primitive
@@ -126,6 +148,12 @@ func LoadConfig(configPath string) (masterConfig *MasterConfig, plugConfig map[s
 			err = fmt.Errorf("Can't unmarshal master config: %s", err)
 		}
 	}
+	if err == nil && masterConfig.HistoryResolution <= 0 {
+		err = fmt.Errorf("history_resolution must be positive")
+	}
+	if err == nil && masterConfig.HistorySize < 0 {
+		err = fmt.Errorf("history_size must not be negative")
+	}
 	plugConfig = configFile
 	delete(plugConfig, "master")
 	return
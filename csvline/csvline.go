@@ -0,0 +1,223 @@
+// Package csvline turns single CSV or TSV lines into fields, with the column
+// names given or taken from the header line of each file.
+package csvline
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Parser splits lines into named fields, its settings must not change while
+// it's in use.
+type Parser struct {
+	// Separates the values, "," by default.
+	Delimiter string
+	// Quotes values that contain the delimiter, doubled inside a value. Zero
+	// disables quoting.
+	Quote byte
+	// Trim spaces around unquoted values.
+	TrimSpace bool
+	// int, float, bool or string by column name.
+	Types map[string]string
+	// Columns left out.
+	Skip map[string]bool
+}
+
+// Returns a parser of comma separated values quoted with ".
+func New() *Parser {
+	return &Parser{
+		Delimiter: ",",
+		Quote:     '"',
+		Types:     make(map[string]string),
+		Skip:      make(map[string]bool),
+	}
+}
+
+// Returns the values of the line.
+func (this *Parser) Split(line string) ([]string, error) {
+	line = strings.TrimRight(line, "\r\n")
+	var values []string
+	for {
+		var value string
+		if this.Quote != 0 && strings.HasPrefix(strings.TrimLeft(line, " "), string(this.Quote)) {
+			s := strings.TrimLeft(line, " ")[1:]
+			var b []byte
+			closed := false
+			for i := 0; i < len(s); i++ {
+				if s[i] != this.Quote {
+					b = append(b, s[i])
+					continue
+				}
+				if i+1 < len(s) && s[i+1] == this.Quote {
+					b = append(b, this.Quote)
+					i++
+					continue
+				}
+				line = strings.TrimLeft(s[i+1:], " ")
+				closed = true
+				break
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated quote in column %d", len(values)+1)
+			}
+			if line != "" && !strings.HasPrefix(line, this.Delimiter) {
+				return nil, fmt.Errorf("text after the quoted value of column %d", len(values)+1)
+			}
+			value = string(b)
+		} else {
+			end := strings.Index(line, this.Delimiter)
+			if end < 0 {
+				end = len(line)
+			}
+			value = line[:end]
+			if this.TrimSpace {
+				value = strings.TrimSpace(value)
+			}
+			line = line[end:]
+		}
+		values = append(values, value)
+		if line == "" {
+			return values, nil
+		}
+		line = line[len(this.Delimiter):]
+	}
+}
+
+// Adds the values of the line to data under the column names. Empty values
+// and skipped columns are left out, values beyond the named columns are
+// named column<n> from 1.
+func (this *Parser) Parse(line string, columns []string, data map[string]interface{}) error {
+	values, err := this.Split(line)
+	if err != nil {
+		return err
+	}
+	for i, value := range values {
+		name := fmt.Sprintf("column%d", i+1)
+		if i < len(columns) && columns[i] != "" {
+			name = columns[i]
+		}
+		if value == "" || this.Skip[name] {
+			continue
+		}
+		data[name] = convert(value, this.Types[name])
+	}
+	return nil
+}
+
+// Values that don't convert stay strings.
+func convert(value, typ string) interface{} {
+	switch typ {
+	case "int":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case "float":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case "bool":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// Bounds the files Headers remembers, rotated files with dated names would
+// grow it forever.
+const maxFiles = 1024
+
+// Headers tracks the header line of every file. It reads the first line of
+// a file the first time a line of it comes along, so headers are known after
+// a restart that resumes in the middle of the file.
+type Headers struct {
+	parser *Parser
+	mutex  sync.Mutex
+	files  map[string]*header
+}
+
+type header struct {
+	line    string
+	columns []string
+	// When the file was last read, ragged files would otherwise be read
+	// for every short line.
+	read time.Time
+}
+
+// How often a file whose lines don't match its header is read again.
+var RereadInterval = 10 * time.Second
+
+func NewHeaders(parser *Parser) *Headers {
+	return &Headers{
+		parser: parser,
+		files:  make(map[string]*header),
+	}
+}
+
+// Returns the columns of the line read from file and whether the line is the
+// header itself. Without a file name, or when the file can't be read, the
+// first line seen is the header.
+func (this *Headers) Columns(file, line string) (columns []string, isHeader bool, err error) {
+	// Spreadsheets start files with a byte order mark.
+	line = strings.TrimPrefix(strings.TrimRight(line, "\r\n"), "\ufeff")
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	h := this.files[file]
+	if h == nil {
+		if h, err = this.read(file, line); err != nil {
+			return nil, false, err
+		}
+	}
+	if line == h.line {
+		return h.columns, true, nil
+	}
+	if file != "" {
+		// The file may have been replaced by one with other columns.
+		values, err := this.parser.Split(line)
+		if err == nil && len(values) != len(h.columns) && time.Since(h.read) >= RereadInterval {
+			if h, err = this.read(file, line); err != nil {
+				return nil, false, err
+			}
+			if line == h.line {
+				return h.columns, true, nil
+			}
+		}
+	}
+	return h.columns, false, nil
+}
+
+func (this *Headers) read(file, line string) (*header, error) {
+	h := &header{line: line, read: time.Now()}
+	if file != "" {
+		if first, err := firstLine(file); err == nil && first != "" {
+			h.line = first
+		}
+	}
+	var err error
+	if h.columns, err = this.parser.Split(h.line); err != nil {
+		return nil, fmt.Errorf("header: %s", err)
+	}
+	if len(this.files) >= maxFiles {
+		this.files = make(map[string]*header)
+	}
+	this.files[file] = h
+	return h, nil
+}
+
+func firstLine(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimPrefix(strings.TrimRight(line, "\r\n"), "\ufeff"), nil
+}
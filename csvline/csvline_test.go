@@ -0,0 +1,98 @@
+package csvline
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	p := New()
+	p.TrimSpace = true
+	p.Types["status"] = "int"
+	p.Types["took"] = "float"
+	p.Types["cached"] = "bool"
+	p.Skip["secret"] = true
+	data := map[string]interface{}{}
+	columns := []string{"host", "path", "status", "took", "cached", "secret", "note"}
+	err := p.Parse(` a.example.com ,"/a,b","200",0.5,true,x,,extra`+"\r\n", columns, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"host":    "a.example.com",
+		"path":    "/a,b",
+		"status":  int64(200),
+		"took":    0.5,
+		"cached":  true,
+		"column8": "extra",
+	}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("got %v\nwant %v", data, want)
+	}
+
+	for _, line := range []string{`a,"unterminated`, `a,"b"c`} {
+		if err = p.Parse(line, columns, data); err == nil {
+			t.Errorf("%s parsed", line)
+		}
+	}
+
+	values, err := p.Split(`"say ""hi""",b`)
+	if err != nil || !reflect.DeepEqual(values, []string{`say "hi"`, "b"}) {
+		t.Errorf("doubled quotes: %q %v", values, err)
+	}
+}
+
+func TestTSV(t *testing.T) {
+	p := &Parser{Delimiter: "\t"}
+	values, err := p.Split("a\t\"b\"\t\tc")
+	if err != nil || !reflect.DeepEqual(values, []string{"a", `"b"`, "", "c"}) {
+		t.Errorf("got %q %v", values, err)
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csvline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "a.csv")
+	if err = ioutil.WriteFile(file, []byte("\ufeffhost,status\r\na,200\nb,404\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHeaders(New())
+	// Resumed in the middle of the file, the header comes from disk.
+	columns, isHeader, err := h.Columns(file, "b,404")
+	if err != nil || isHeader || !reflect.DeepEqual(columns, []string{"host", "status"}) {
+		t.Errorf("got %v %v %v", columns, isHeader, err)
+	}
+	if _, isHeader, _ = h.Columns(file, "host,status"); !isHeader {
+		t.Error("header line not recognized")
+	}
+
+	// Replaced by a file with other columns.
+	if err = ioutil.WriteFile(file, []byte("host,status,took\na,200,1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(interval time.Duration) { RereadInterval = interval }(RereadInterval)
+	RereadInterval = 0
+	if _, isHeader, _ = h.Columns(file, "host,status,took"); !isHeader {
+		t.Error("new header line not recognized")
+	}
+	if columns, _, _ = h.Columns(file, "a,200,1"); len(columns) != 3 {
+		t.Errorf("got %v after the file changed", columns)
+	}
+
+	// Without a file the first line is the header.
+	if _, isHeader, _ = h.Columns("", "x,y"); !isHeader {
+		t.Error("first line without a file is not the header")
+	}
+	if columns, isHeader, _ = h.Columns("", "1,2"); isHeader || !reflect.DeepEqual(columns, []string{"x", "y"}) {
+		t.Errorf("got %v %v", columns, isHeader)
+	}
+}
@@ -0,0 +1,77 @@
+package decoders
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bbangert/toml"
+	"github.com/millken/kaman/accesslog"
+	"github.com/millken/kaman/plugins"
+)
+
+type AccessLogDecoderConfig struct {
+	LogFormat string            `toml:"log_format" desc:"nginx log_format or Apache LogFormat string, or combined, main (nginx) or common (apache)"`
+	Style     string            `toml:"style" desc:"nginx or apache, guessed from log_format when empty"`
+	Types     map[string]string `toml:"types" desc:"int or float by field name, on top of the built-in ones"`
+}
+
+type AccessLogDecoder struct {
+	config *AccessLogDecoderConfig
+	parser *accesslog.Parser
+}
+
+func (this *AccessLogDecoder) ConfigStruct() interface{} {
+	return &AccessLogDecoderConfig{}
+}
+
+func (this *AccessLogDecoder) Init(conf toml.Primitive) (err error) {
+	this.config = this.ConfigStruct().(*AccessLogDecoderConfig)
+	if err = toml.PrimitiveDecode(conf, this.config); err != nil {
+		return fmt.Errorf("Can't unmarshal AccessLogDecoder config: %s", err)
+	}
+	style := this.config.Style
+	if style == "" {
+		style = "apache"
+		if _, ok := accesslog.NginxFormats[this.config.LogFormat]; ok || strings.Contains(this.config.LogFormat, "$") {
+			style = "nginx"
+		}
+	}
+	switch style {
+	case "nginx":
+		this.parser, err = accesslog.Nginx(this.config.LogFormat)
+	case "apache":
+		this.parser, err = accesslog.Apache(this.config.LogFormat)
+	default:
+		return fmt.Errorf("AccessLogDecoder style must be nginx or apache, not %s", style)
+	}
+	if err != nil {
+		return fmt.Errorf("AccessLogDecoder: %s", err)
+	}
+	for name, typ := range this.config.Types {
+		if typ != "int" && typ != "float" && typ != "string" {
+			return fmt.Errorf("AccessLogDecoder: unknown type %s of %s", typ, name)
+		}
+		this.parser.Types[name] = typ
+	}
+	return nil
+}
+
+func (this *AccessLogDecoder) Decode(pack *plugins.PipelinePack) (rpack *plugins.PipelinePack, err error) {
+	rpack = pack
+	rpack.Msg.Lock()
+	defer rpack.Msg.Unlock()
+	ts, err := this.parser.Parse(string(pack.MsgBytes), rpack.Msg.Data)
+	if err != nil {
+		return rpack, fmt.Errorf("AccessLogDecoder: %s: %s", err, rpack.MsgBytes)
+	}
+	if !ts.IsZero() {
+		rpack.Msg.Timestamp = ts.Unix()
+	}
+	return rpack, nil
+}
+
+func init() {
+	plugins.RegisterDecoder("AccessLogDecoder", func() interface{} {
+		return new(AccessLogDecoder)
+	})
+}
@@ -0,0 +1,68 @@
+package decoders
+
+import (
+	"fmt"
+
+	"github.com/bbangert/toml"
+	"github.com/millken/kaman/plugins"
+)
+
+type ChainDecoderConfig struct {
+	Decoders []string `toml:"decoders" desc:"names of the decoders run in order on every message, required"`
+}
+
+// Runs several decoders on a message, like a RegexDecoder and a
+// TimestampDecoder for the time it extracted. The first error ends the chain.
+type ChainDecoder struct {
+	config *ChainDecoderConfig
+	common *plugins.PluginCommonConfig
+}
+
+func (this *ChainDecoder) ConfigStruct() interface{} {
+	return &ChainDecoderConfig{}
+}
+
+func (this *ChainDecoder) SetCommonConfig(pcf *plugins.PluginCommonConfig) {
+	this.common = pcf
+}
+
+func (this *ChainDecoder) Init(conf toml.Primitive) (err error) {
+	this.config = this.ConfigStruct().(*ChainDecoderConfig)
+	if err = toml.PrimitiveDecode(conf, this.config); err != nil {
+		return fmt.Errorf("Can't unmarshal ChainDecoder config: %s", err)
+	}
+	if len(this.config.Decoders) == 0 {
+		return fmt.Errorf("ChainDecoder needs at least one decoder")
+	}
+	for _, name := range this.config.Decoders {
+		if this.common != nil && name == this.common.Decoder {
+			return fmt.Errorf("ChainDecoder %s can't run itself", name)
+		}
+	}
+	return nil
+}
+
+func (this *ChainDecoder) ChainedDecoders() []string {
+	return this.config.Decoders
+}
+
+func (this *ChainDecoder) Decode(pack *plugins.PipelinePack) (rpack *plugins.PipelinePack, err error) {
+	rpack = pack
+	for _, name := range this.config.Decoders {
+		// The decoders are initialized in no particular order, so they are
+		// looked up here rather than in Init.
+		if !plugins.HasDecoder(name) {
+			return rpack, fmt.Errorf("ChainDecoder: unknown decoder %s", name)
+		}
+		if rpack, err = plugins.PipeDecoder(name, rpack); err != nil {
+			return rpack, err
+		}
+	}
+	return rpack, nil
+}
+
+func init() {
+	plugins.RegisterDecoder("ChainDecoder", func() interface{} {
+		return new(ChainDecoder)
+	})
+}
@@ -0,0 +1,96 @@
+package decoders
+
+import (
+	"fmt"
+
+	"github.com/bbangert/toml"
+	"github.com/millken/kaman/csvline"
+	"github.com/millken/kaman/plugins"
+)
+
+type CsvDecoderConfig struct {
+	Delimiter   string            `toml:"delimiter" desc:"separates the values, \"\\t\" for TSV"`
+	Quote       string            `toml:"quote" desc:"character quoting values, empty disables quoting"`
+	TrimSpace   bool              `toml:"trim_space" desc:"trim spaces around unquoted values"`
+	Columns     []string          `toml:"columns" desc:"column names, required unless header is set"`
+	Header      bool              `toml:"header" desc:"take the column names from the first line of each file"`
+	Types       map[string]string `toml:"types" desc:"int, float or bool by column name"`
+	SkipColumns []string          `toml:"skip_columns" desc:"columns left out of the data"`
+}
+
+type CsvDecoder struct {
+	config  *CsvDecoderConfig
+	parser  *csvline.Parser
+	headers *csvline.Headers
+}
+
+func (this *CsvDecoder) ConfigStruct() interface{} {
+	return &CsvDecoderConfig{
+		Delimiter: ",",
+		Quote:     `"`,
+	}
+}
+
+func (this *CsvDecoder) Init(conf toml.Primitive) (err error) {
+	this.config = this.ConfigStruct().(*CsvDecoderConfig)
+	if err = toml.PrimitiveDecode(conf, this.config); err != nil {
+		return fmt.Errorf("Can't unmarshal CsvDecoder config: %s", err)
+	}
+	if this.config.Delimiter == "" {
+		return fmt.Errorf("CsvDecoder delimiter must not be empty")
+	}
+	if len(this.config.Quote) > 1 {
+		return fmt.Errorf("CsvDecoder quote must be a single character, not %s", this.config.Quote)
+	}
+	if !this.config.Header && len(this.config.Columns) == 0 {
+		return fmt.Errorf("CsvDecoder needs columns or header = true")
+	}
+	this.parser = csvline.New()
+	this.parser.Delimiter = this.config.Delimiter
+	this.parser.Quote = 0
+	if this.config.Quote != "" {
+		this.parser.Quote = this.config.Quote[0]
+	}
+	this.parser.TrimSpace = this.config.TrimSpace
+	for name, typ := range this.config.Types {
+		if typ != "int" && typ != "float" && typ != "bool" && typ != "string" {
+			return fmt.Errorf("CsvDecoder: unknown type %s of %s", typ, name)
+		}
+		this.parser.Types[name] = typ
+	}
+	for _, name := range this.config.SkipColumns {
+		this.parser.Skip[name] = true
+	}
+	if this.config.Header {
+		this.headers = csvline.NewHeaders(this.parser)
+	}
+	return nil
+}
+
+func (this *CsvDecoder) Decode(pack *plugins.PipelinePack) (rpack *plugins.PipelinePack, err error) {
+	rpack = pack
+	line := string(pack.MsgBytes)
+	rpack.Msg.Lock()
+	defer rpack.Msg.Unlock()
+	columns := this.config.Columns
+	if this.headers != nil {
+		var isHeader bool
+		columns, isHeader, err = this.headers.Columns(rpack.Msg.Meta[plugins.MetaFile], line)
+		if err != nil {
+			return rpack, fmt.Errorf("CsvDecoder: %s: %s", err, line)
+		}
+		if isHeader {
+			return rpack, plugins.ErrDropped
+		}
+	}
+	if err = this.parser.Parse(line, columns, rpack.Msg.Data); err != nil {
+		return rpack, fmt.Errorf("CsvDecoder: %s: %s", err, line)
+	}
+	return rpack, nil
+}
+
+func init() {
+	plugins.RegisterDecoder("CsvDecoder", func() interface{} {
+		return new(CsvDecoder)
+	})
+}
@@ -0,0 +1,137 @@
+package decoders
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bbangert/toml"
+	"github.com/millken/kaman/geoip"
+	"github.com/millken/kaman/metrics"
+	"github.com/millken/kaman/plugins"
+)
+
+type GeoIPDecoderConfig struct {
+	Database       string `toml:"database" desc:"GeoLite2-City or GeoIP2-City .mmdb file, relative to the share dir"`
+	AsnDatabase    string `toml:"asn_database" desc:"GeoLite2-ASN .mmdb file, relative to the share dir"`
+	SourceField    string `toml:"source_field" desc:"field holding the client IP, X-Forwarded-For lists take the first"`
+	TargetPrefix   string `toml:"target_prefix" desc:"prefix of the fields added, like geo_country_code"`
+	CacheSize      int    `toml:"cache_size" desc:"number of IPs whose fields are cached, 0 disables the cache"`
+	ReloadInterval string `toml:"reload_interval" desc:"how often the files are checked for changes, 0 disables reloading"`
+}
+
+// Adds the country, region, city, coordinates and ASN of the client IP to the
+// data, put after the decoder that extracts the IP in a ChainDecoder. IPs the
+// databases don't know add nothing and don't fail the message.
+type GeoIPDecoder struct {
+	config  *GeoIPDecoderConfig
+	common  *plugins.PluginCommonConfig
+	locator *geoip.Locator
+	invalid *metrics.Counter
+	unknown *metrics.Counter
+	// Closed by Stop to end the reloads.
+	stop chan struct{}
+}
+
+func (this *GeoIPDecoder) ConfigStruct() interface{} {
+	return &GeoIPDecoderConfig{
+		SourceField:    "remote_addr",
+		TargetPrefix:   "geo_",
+		CacheSize:      10000,
+		ReloadInterval: "1m",
+	}
+}
+
+func (this *GeoIPDecoder) SetCommonConfig(pcf *plugins.PluginCommonConfig) {
+	this.common = pcf
+}
+
+func (this *GeoIPDecoder) Init(conf toml.Primitive) (err error) {
+	this.config = this.ConfigStruct().(*GeoIPDecoderConfig)
+	if err = toml.PrimitiveDecode(conf, this.config); err != nil {
+		return fmt.Errorf("Can't unmarshal GeoIPDecoder config: %s", err)
+	}
+	if this.config.Database == "" && this.config.AsnDatabase == "" {
+		return fmt.Errorf("GeoIPDecoder needs a database or an asn_database")
+	}
+	if this.config.SourceField == "" {
+		return fmt.Errorf("GeoIPDecoder source_field is required")
+	}
+	interval, err := time.ParseDuration(this.config.ReloadInterval)
+	if err != nil {
+		return fmt.Errorf("GeoIPDecoder reload_interval: %s", err)
+	}
+	var city, asn string
+	if this.config.Database != "" {
+		city = plugins.PrependShareDir(this.config.Database)
+	}
+	if this.config.AsnDatabase != "" {
+		asn = plugins.PrependShareDir(this.config.AsnDatabase)
+	}
+	if this.locator, err = geoip.NewLocator(city, asn, this.config.CacheSize); err != nil {
+		return fmt.Errorf("GeoIPDecoder: %s", err)
+	}
+	registry := this.common.Metrics()
+	this.invalid = registry.Counter("geoip_invalid_ip")
+	this.unknown = registry.Counter("geoip_unknown_ip")
+	if interval > 0 {
+		this.stop = make(chan struct{})
+		go this.reload(interval, this.stop)
+	}
+	return nil
+}
+
+func (this *GeoIPDecoder) reload(interval time.Duration, stop chan struct{}) {
+	log := this.common.Logger()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+		reloaded, err := this.locator.Reload()
+		if err != nil {
+			log.Error("GeoIPDecoder reload", "err", err)
+		} else if reloaded {
+			log.Info("GeoIPDecoder reloaded the databases")
+		}
+	}
+}
+
+// Ends the reloads.
+func (this *GeoIPDecoder) Stop() {
+	if this.stop != nil {
+		close(this.stop)
+		this.stop = nil
+	}
+}
+
+func (this *GeoIPDecoder) Decode(pack *plugins.PipelinePack) (rpack *plugins.PipelinePack, err error) {
+	rpack = pack
+	rpack.Msg.Lock()
+	defer rpack.Msg.Unlock()
+	value, ok := rpack.Msg.Data[this.config.SourceField].(string)
+	if !ok || value == "" || value == "-" {
+		return rpack, nil
+	}
+	fields, err := this.locator.Lookup(geoip.FirstIP(value))
+	if err != nil {
+		this.invalid.Add(1)
+		return rpack, nil
+	}
+	if len(fields) == 0 {
+		this.unknown.Add(1)
+		return rpack, nil
+	}
+	for k, v := range fields {
+		rpack.Msg.Data[this.config.TargetPrefix+k] = v
+	}
+	return rpack, nil
+}
+
+func init() {
+	plugins.RegisterDecoder("GeoIPDecoder", func() interface{} {
+		return new(GeoIPDecoder)
+	})
+}
@@ -0,0 +1,73 @@
+package decoders
+
+import (
+	"fmt"
+
+	"github.com/bbangert/toml"
+	"github.com/millken/kaman/grok"
+	"github.com/millken/kaman/plugins"
+)
+
+type GrokDecoderConfig struct {
+	Match        []string          `toml:"match" desc:"grok expressions, tried in order until one matches"`
+	PatternFiles []string          `toml:"pattern_files" desc:"files of NAME pattern lines, relative to share_dir"`
+	Patterns     map[string]string `toml:"patterns" desc:"extra patterns by name"`
+	PatternField string            `toml:"pattern_field" desc:"field set to the index of the expression that matched"`
+}
+
+type GrokDecoder struct {
+	config   *GrokDecoderConfig
+	patterns []*grok.Pattern
+}
+
+func (this *GrokDecoder) ConfigStruct() interface{} {
+	return &GrokDecoderConfig{}
+}
+
+func (this *GrokDecoder) Init(conf toml.Primitive) (err error) {
+	this.config = this.ConfigStruct().(*GrokDecoderConfig)
+	if err = toml.PrimitiveDecode(conf, this.config); err != nil {
+		return fmt.Errorf("Can't unmarshal GrokDecoder config: %s", err)
+	}
+	if len(this.config.Match) == 0 {
+		return fmt.Errorf("GrokDecoder needs at least one match expression")
+	}
+	g := grok.New()
+	for _, path := range this.config.PatternFiles {
+		if err = g.AddPatternsFromFile(plugins.PrependShareDir(path)); err != nil {
+			return fmt.Errorf("GrokDecoder: %s", err)
+		}
+	}
+	for name, pattern := range this.config.Patterns {
+		g.AddPattern(name, pattern)
+	}
+	this.patterns = make([]*grok.Pattern, len(this.config.Match))
+	for i, expr := range this.config.Match {
+		if this.patterns[i], err = g.Compile(expr); err != nil {
+			return fmt.Errorf("GrokDecoder: %s", err)
+		}
+	}
+	return nil
+}
+
+func (this *GrokDecoder) Decode(pack *plugins.PipelinePack) (rpack *plugins.PipelinePack, err error) {
+	rpack = pack
+	text := string(pack.MsgBytes)
+	rpack.Msg.Lock()
+	defer rpack.Msg.Unlock()
+	for i, p := range this.patterns {
+		if p.Match(text, rpack.Msg.Data) {
+			if this.config.PatternField != "" {
+				rpack.Msg.Data[this.config.PatternField] = i
+			}
+			return rpack, nil
+		}
+	}
+	return rpack, fmt.Errorf("%s matches none of the %d grok expressions", rpack.MsgBytes, len(this.patterns))
+}
+
+func init() {
+	plugins.RegisterDecoder("GrokDecoder", func() interface{} {
+		return new(GrokDecoder)
+	})
+}
@@ -0,0 +1,101 @@
+package decoders
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/bbangert/toml"
+	"github.com/millken/kaman/jsonline"
+	"github.com/millken/kaman/plugins"
+	"github.com/ugorji/go/codec"
+)
+
+type JsonDecoderConfig struct {
+	Flatten         bool   `toml:"flatten" desc:"flatten nested objects into keys joined by the separator"`
+	Separator       string `toml:"separator" desc:"joins the keys of nested objects"`
+	TagField        string `toml:"tag_field" desc:"field copied into the message tag"`
+	TimestampField  string `toml:"timestamp_field" desc:"field parsed into the message timestamp"`
+	TimestampLayout string `toml:"timestamp_layout" desc:"Go time layout of string timestamps, numbers are unix seconds or milliseconds"`
+	BytesField      string `toml:"bytes_field" desc:"field whose value becomes the message bytes the encoders see"`
+	RemoveMapped    bool   `toml:"remove_mapped" desc:"remove the fields copied into the message from the data"`
+}
+
+type JsonDecoder struct {
+	config  *JsonDecoderConfig
+	handle  *codec.JsonHandle
+	parser  *jsonline.Parser
+	mapping *jsonline.Mapping
+}
+
+func (this *JsonDecoder) ConfigStruct() interface{} {
+	return &JsonDecoderConfig{
+		Flatten:         true,
+		Separator:       ".",
+		TimestampLayout: time.RFC3339,
+	}
+}
+
+func (this *JsonDecoder) Init(conf toml.Primitive) (err error) {
+	this.config = this.ConfigStruct().(*JsonDecoderConfig)
+	if err = toml.PrimitiveDecode(conf, this.config); err != nil {
+		return fmt.Errorf("Can't unmarshal JsonDecoder config: %s", err)
+	}
+	if this.config.Flatten && this.config.Separator == "" {
+		return fmt.Errorf("JsonDecoder separator must not be empty when flattening")
+	}
+	this.handle = new(codec.JsonHandle)
+	// Objects decode into map[string]interface{} rather than
+	// map[interface{}]interface{}, at every level.
+	this.handle.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	this.parser = &jsonline.Parser{Flatten: this.config.Flatten, Separator: this.config.Separator}
+	this.mapping = &jsonline.Mapping{
+		TagField:        this.config.TagField,
+		TimestampField:  this.config.TimestampField,
+		TimestampLayout: this.config.TimestampLayout,
+		BytesField:      this.config.BytesField,
+		RemoveMapped:    this.config.RemoveMapped,
+	}
+	return nil
+}
+
+func (this *JsonDecoder) Decode(pack *plugins.PipelinePack) (rpack *plugins.PipelinePack, err error) {
+	rpack = pack
+	dec := codec.NewDecoderBytes(pack.MsgBytes, this.handle)
+	var value, extra interface{}
+	if err = dec.Decode(&value); err != nil {
+		return rpack, fmt.Errorf("JsonDecoder: malformed JSON %s: %s", jsonline.Quote(pack.MsgBytes), err)
+	}
+	if err = dec.Decode(&extra); err != io.EOF {
+		return rpack, fmt.Errorf("JsonDecoder: malformed JSON %s: data after the object", jsonline.Quote(pack.MsgBytes))
+	}
+
+	rpack.Msg.Lock()
+	defer rpack.Msg.Unlock()
+	if err = this.parser.Add(value, rpack.Msg.Data); err != nil {
+		return rpack, fmt.Errorf("JsonDecoder: %s: %s", jsonline.Quote(pack.MsgBytes), err)
+	}
+	env, err := this.mapping.Apply(rpack.Msg.Data)
+	if err != nil {
+		return rpack, fmt.Errorf("JsonDecoder: %s", err)
+	}
+	// Routes matched the tag of the input already, the new one is what the
+	// encoders and the outputs see.
+	if env.Tag != "" {
+		rpack.Msg.Tag = env.Tag
+	}
+	if env.Timestamp != 0 {
+		rpack.Msg.Timestamp = env.Timestamp
+	}
+	if env.Bytes != nil {
+		rpack.Msg.MsgBytes = env.Bytes
+	}
+	return rpack, nil
+}
+
+func init() {
+	plugins.RegisterDecoder("JsonDecoder", func() interface{} {
+		return new(JsonDecoder)
+	})
+}
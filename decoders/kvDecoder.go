@@ -0,0 +1,83 @@
+package decoders
+
+import (
+	"fmt"
+
+	"github.com/bbangert/toml"
+	"github.com/millken/kaman/kv"
+	"github.com/millken/kaman/plugins"
+)
+
+type KVDecoderConfig struct {
+	PairSeparator string   `toml:"pair_separator" desc:"separates the pairs"`
+	KeySeparator  string   `toml:"kv_separator" desc:"separates a key from its value"`
+	Quotes        string   `toml:"quotes" desc:"characters values may be quoted with, empty disables quoting"`
+	Trim          string   `toml:"trim" desc:"characters trimmed from keys and unquoted values"`
+	Prefix        string   `toml:"prefix" desc:"prepended to the keys"`
+	IncludeKeys   []string `toml:"include_keys" desc:"only these keys are kept when not empty"`
+	ExcludeKeys   []string `toml:"exclude_keys" desc:"keys left out"`
+	InferTypes    bool     `toml:"infer_types" desc:"turn unquoted numbers and booleans into numbers and booleans"`
+}
+
+type KVDecoder struct {
+	parser *kv.Parser
+}
+
+func (this *KVDecoder) ConfigStruct() interface{} {
+	p := kv.New()
+	return &KVDecoderConfig{
+		PairSeparator: p.PairSeparator,
+		KeySeparator:  p.KeySeparator,
+		Quotes:        p.Quotes,
+	}
+}
+
+func (this *KVDecoder) Init(conf toml.Primitive) (err error) {
+	config := this.ConfigStruct().(*KVDecoderConfig)
+	if err = toml.PrimitiveDecode(conf, config); err != nil {
+		return fmt.Errorf("Can't unmarshal KVDecoder config: %s", err)
+	}
+	if config.PairSeparator == "" || config.KeySeparator == "" {
+		return fmt.Errorf("KVDecoder pair_separator and kv_separator must not be empty")
+	}
+	if config.PairSeparator == config.KeySeparator {
+		return fmt.Errorf("KVDecoder pair_separator and kv_separator must differ")
+	}
+	this.parser = &kv.Parser{
+		PairSeparator: config.PairSeparator,
+		KeySeparator:  config.KeySeparator,
+		Quotes:        config.Quotes,
+		Trim:          config.Trim,
+		Prefix:        config.Prefix,
+		Include:       make(map[string]bool),
+		Exclude:       make(map[string]bool),
+		InferTypes:    config.InferTypes,
+	}
+	for _, key := range config.IncludeKeys {
+		this.parser.Include[key] = true
+	}
+	for _, key := range config.ExcludeKeys {
+		this.parser.Exclude[key] = true
+	}
+	return nil
+}
+
+func (this *KVDecoder) Decode(pack *plugins.PipelinePack) (rpack *plugins.PipelinePack, err error) {
+	rpack = pack
+	rpack.Msg.Lock()
+	defer rpack.Msg.Unlock()
+	n, err := this.parser.Parse(string(pack.MsgBytes), rpack.Msg.Data)
+	if err != nil {
+		return rpack, fmt.Errorf("KVDecoder: %s: %s", err, rpack.MsgBytes)
+	}
+	if n == 0 {
+		return rpack, fmt.Errorf("KVDecoder: no key-value pairs in %s", rpack.MsgBytes)
+	}
+	return rpack, nil
+}
+
+func init() {
+	plugins.RegisterDecoder("KVDecoder", func() interface{} {
+		return new(KVDecoder)
+	})
+}
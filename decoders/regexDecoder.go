@@ -9,7 +9,7 @@ import (
 )
 
 type RegexDecoderConfig struct {
-	MatchRegex string `toml:"match_regex"`
+	MatchRegex string `toml:"match_regex" desc:"regular expression with capture groups, named groups become field names"`
 }
 
 type RegexDecoder struct {
@@ -17,9 +17,13 @@ type RegexDecoder struct {
 	config *RegexDecoderConfig
 }
 
+func (this *RegexDecoder) ConfigStruct() interface{} {
+	return &RegexDecoderConfig{}
+}
+
 //http://play.golang.org/p/fOWJXgcfKO
 func (this *RegexDecoder) Init(conf toml.Primitive) (err error) {
-	this.config = &RegexDecoderConfig{}
+	this.config = this.ConfigStruct().(*RegexDecoderConfig)
 	if err = toml.PrimitiveDecode(conf, this.config); err != nil {
 		return fmt.Errorf("Can't unmarshal regexdecoder config: %s", err)
 	}
@@ -0,0 +1,61 @@
+package decoders
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bbangert/toml"
+	"github.com/millken/kaman/plugins"
+	"github.com/millken/kaman/syslog"
+)
+
+type SyslogDecoderConfig struct {
+	Timezone     string `toml:"timezone" desc:"zone of RFC3164 timestamps, Local or an IANA name like Asia/Shanghai"`
+	MessageBytes bool   `toml:"message_bytes" desc:"make the MSG part the message bytes the encoders see"`
+}
+
+type SyslogDecoder struct {
+	config   *SyslogDecoderConfig
+	location *time.Location
+}
+
+func (this *SyslogDecoder) ConfigStruct() interface{} {
+	return &SyslogDecoderConfig{
+		Timezone: "Local",
+	}
+}
+
+func (this *SyslogDecoder) Init(conf toml.Primitive) (err error) {
+	this.config = this.ConfigStruct().(*SyslogDecoderConfig)
+	if err = toml.PrimitiveDecode(conf, this.config); err != nil {
+		return fmt.Errorf("Can't unmarshal SyslogDecoder config: %s", err)
+	}
+	if this.location, err = time.LoadLocation(this.config.Timezone); err != nil {
+		return fmt.Errorf("SyslogDecoder timezone: %s", err)
+	}
+	return nil
+}
+
+func (this *SyslogDecoder) Decode(pack *plugins.PipelinePack) (rpack *plugins.PipelinePack, err error) {
+	rpack = pack
+	m, err := syslog.Parse(string(pack.MsgBytes), time.Now(), this.location)
+	if err != nil {
+		return rpack, fmt.Errorf("SyslogDecoder: %s", err)
+	}
+	rpack.Msg.Lock()
+	defer rpack.Msg.Unlock()
+	m.Fields(rpack.Msg.Data)
+	if !m.Timestamp.IsZero() {
+		rpack.Msg.Timestamp = m.Timestamp.Unix()
+	}
+	if this.config.MessageBytes {
+		rpack.Msg.MsgBytes = []byte(m.Message)
+	}
+	return rpack, nil
+}
+
+func init() {
+	plugins.RegisterDecoder("SyslogDecoder", func() interface{} {
+		return new(SyslogDecoder)
+	})
+}
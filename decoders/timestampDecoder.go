@@ -0,0 +1,84 @@
+package decoders
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bbangert/toml"
+	"github.com/millken/kaman/metrics"
+	"github.com/millken/kaman/plugins"
+	"github.com/millken/kaman/timestamp"
+)
+
+type TimestampDecoderConfig struct {
+	Field       string   `toml:"field" desc:"field holding the event time, required"`
+	Layouts     []string `toml:"layouts" desc:"tried in order: Go layouts, names like RFC3339, strftime layouts with % or unix, unix_ms, unix_us, unix_ns"`
+	Timezone    string   `toml:"timezone" desc:"zone of times without one, Local or an IANA name like Asia/Shanghai"`
+	RemoveField bool     `toml:"remove_field" desc:"remove the field once parsed"`
+}
+
+// Sets the message timestamp from a field of the data, put after the
+// decoder that extracts the field in a ChainDecoder. Messages whose time
+// doesn't parse keep the input time and are counted.
+type TimestampDecoder struct {
+	config      *TimestampDecoderConfig
+	common      *plugins.PluginCommonConfig
+	parser      *timestamp.Parser
+	unparseable *metrics.Counter
+}
+
+func (this *TimestampDecoder) ConfigStruct() interface{} {
+	return &TimestampDecoderConfig{
+		Layouts:  []string{"RFC3339"},
+		Timezone: "Local",
+	}
+}
+
+func (this *TimestampDecoder) SetCommonConfig(pcf *plugins.PluginCommonConfig) {
+	this.common = pcf
+}
+
+func (this *TimestampDecoder) Init(conf toml.Primitive) (err error) {
+	this.config = this.ConfigStruct().(*TimestampDecoderConfig)
+	if err = toml.PrimitiveDecode(conf, this.config); err != nil {
+		return fmt.Errorf("Can't unmarshal TimestampDecoder config: %s", err)
+	}
+	if this.config.Field == "" {
+		return fmt.Errorf("TimestampDecoder field is required")
+	}
+	location, err := time.LoadLocation(this.config.Timezone)
+	if err != nil {
+		return fmt.Errorf("TimestampDecoder timezone: %s", err)
+	}
+	if this.parser, err = timestamp.New(this.config.Layouts, location); err != nil {
+		return fmt.Errorf("TimestampDecoder layouts: %s", err)
+	}
+	this.unparseable = this.common.Metrics().Counter("timestamp_unparseable")
+	return nil
+}
+
+func (this *TimestampDecoder) Decode(pack *plugins.PipelinePack) (rpack *plugins.PipelinePack, err error) {
+	rpack = pack
+	rpack.Msg.Lock()
+	defer rpack.Msg.Unlock()
+	value, ok := rpack.Msg.Data[this.config.Field]
+	if !ok {
+		return rpack, nil
+	}
+	t, err := this.parser.Parse(value, time.Now())
+	if err != nil {
+		this.unparseable.Add(1)
+		return rpack, nil
+	}
+	rpack.Msg.Timestamp = t.Unix()
+	if this.config.RemoveField {
+		delete(rpack.Msg.Data, this.config.Field)
+	}
+	return rpack, nil
+}
+
+func init() {
+	plugins.RegisterDecoder("TimestampDecoder", func() interface{} {
+		return new(TimestampDecoder)
+	})
+}
@@ -0,0 +1,79 @@
+package decoders
+
+import (
+	"fmt"
+
+	"github.com/bbangert/toml"
+	"github.com/millken/kaman/plugins"
+	"github.com/millken/kaman/useragent"
+)
+
+type UserAgentDecoderConfig struct {
+	Regexes      string `toml:"regexes" desc:"regexes.yaml rule file of ua-parser relative to the share dir, the built-in rules when empty"`
+	SourceField  string `toml:"source_field" desc:"field holding the User-Agent"`
+	TargetPrefix string `toml:"target_prefix" desc:"prefix of the fields added, like ua_browser"`
+	CacheSize    int    `toml:"cache_size" desc:"number of User-Agents whose results are cached, 0 disables the cache"`
+}
+
+// Adds the browser, OS and device of the User-Agent to the data and whether
+// it is a bot, put after the decoder that extracts the User-Agent in a
+// ChainDecoder.
+type UserAgentDecoder struct {
+	config *UserAgentDecoderConfig
+	common *plugins.PluginCommonConfig
+	parser *useragent.Parser
+}
+
+func (this *UserAgentDecoder) ConfigStruct() interface{} {
+	return &UserAgentDecoderConfig{
+		SourceField:  "http_user_agent",
+		TargetPrefix: "ua_",
+		CacheSize:    10000,
+	}
+}
+
+func (this *UserAgentDecoder) SetCommonConfig(pcf *plugins.PluginCommonConfig) {
+	this.common = pcf
+}
+
+func (this *UserAgentDecoder) Init(conf toml.Primitive) (err error) {
+	this.config = this.ConfigStruct().(*UserAgentDecoderConfig)
+	if err = toml.PrimitiveDecode(conf, this.config); err != nil {
+		return fmt.Errorf("Can't unmarshal UserAgentDecoder config: %s", err)
+	}
+	if this.config.SourceField == "" {
+		return fmt.Errorf("UserAgentDecoder source_field is required")
+	}
+	if this.config.Regexes == "" {
+		this.parser = useragent.New(this.config.CacheSize)
+		return nil
+	}
+	path := plugins.PrependShareDir(this.config.Regexes)
+	var skipped []error
+	if this.parser, skipped, err = useragent.NewFromFile(path, this.config.CacheSize); err != nil {
+		return fmt.Errorf("UserAgentDecoder regexes: %s", err)
+	}
+	if len(skipped) > 0 {
+		this.common.Logger().Warn("UserAgentDecoder skipped rules Go can't compile",
+			"path", path, "count", len(skipped), "first", skipped[0])
+	}
+	return nil
+}
+
+func (this *UserAgentDecoder) Decode(pack *plugins.PipelinePack) (rpack *plugins.PipelinePack, err error) {
+	rpack = pack
+	rpack.Msg.Lock()
+	defer rpack.Msg.Unlock()
+	ua, ok := rpack.Msg.Data[this.config.SourceField].(string)
+	if !ok {
+		return rpack, nil
+	}
+	this.parser.Parse(ua).Fields(this.config.TargetPrefix, rpack.Msg.Data)
+	return rpack, nil
+}
+
+func init() {
+	plugins.RegisterDecoder("UserAgentDecoder", func() interface{} {
+		return new(UserAgentDecoder)
+	})
+}
@@ -15,6 +15,10 @@ type JsonEncoder struct {
 	codec *codec.JsonHandle
 }
 
+func (this *JsonEncoder) ConfigStruct() interface{} {
+	return &JsonEncoderConfig{}
+}
+
 func (this *JsonEncoder) Init(conf toml.Primitive) (err error) {
 	//if err = toml.PrimitiveDecode(conf, this.config); err != nil {
 	//	return fmt.Errorf("Can't unmarshal regexdecoder config: %s", err)
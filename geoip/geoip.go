@@ -0,0 +1,214 @@
+// Package geoip looks up the location and autonomous system of IPs in local
+// MaxMind DB files, like GeoLite2-City and GeoLite2-ASN.
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/millken/kaman/lru"
+)
+
+// A Locator turns IPs into fields with a city and an ASN database, either may
+// be missing. It caches the results and reloads databases that change.
+type Locator struct {
+	cityPath string
+	asnPath  string
+	cache    *lru.Cache
+
+	mutex sync.RWMutex
+	city  *database
+	asn   *database
+}
+
+type database struct {
+	reader  *Reader
+	modTime time.Time
+	size    int64
+}
+
+func openDatabase(path string) (*database, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &database{reader: r, modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// Reports whether the file differs from the one the database was read from.
+func (this *database) changed(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && (!info.ModTime().Equal(this.modTime) || info.Size() != this.size)
+}
+
+// Opens the databases, an empty path leaves one out. cacheSize is the number
+// of IPs whose fields are cached.
+func NewLocator(cityPath, asnPath string, cacheSize int) (*Locator, error) {
+	if cityPath == "" && asnPath == "" {
+		return nil, fmt.Errorf("no database")
+	}
+	l := &Locator{cityPath: cityPath, asnPath: asnPath, cache: lru.New(cacheSize)}
+	var err error
+	if cityPath != "" {
+		if l.city, err = openDatabase(cityPath); err != nil {
+			return nil, err
+		}
+	}
+	if asnPath != "" {
+		if l.asn, err = openDatabase(asnPath); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Reads the databases whose files changed again and empties the cache when
+// one did. A file that can't be read keeps the database already loaded, a
+// database is usually replaced by writing it next to the old one and
+// renaming it over.
+func (this *Locator) Reload() (reloaded bool, err error) {
+	city, asn := this.databases()
+	if city != nil && city.changed(this.cityPath) {
+		if city, err = openDatabase(this.cityPath); err != nil {
+			return false, err
+		}
+		reloaded = true
+	}
+	if asn != nil && asn.changed(this.asnPath) {
+		if asn, err = openDatabase(this.asnPath); err != nil {
+			return false, err
+		}
+		reloaded = true
+	}
+	if reloaded {
+		this.mutex.Lock()
+		this.city, this.asn = city, asn
+		this.cache.Clear()
+		this.mutex.Unlock()
+	}
+	return reloaded, nil
+}
+
+func (this *Locator) databases() (*database, *database) {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+	return this.city, this.asn
+}
+
+// Returns the fields of the IP, empty when the databases don't know it. The
+// map is shared by the callers and must not be changed.
+func (this *Locator) Lookup(ip string) (map[string]interface{}, error) {
+	if fields, ok := this.cache.Get(ip); ok {
+		return fields.(map[string]interface{}), nil
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, fmt.Errorf("invalid IP %q", ip)
+	}
+	// Held until the fields are cached, so a reload can't be followed by
+	// fields of the old database.
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+	fields := make(map[string]interface{})
+	if this.city != nil {
+		record, err := this.city.reader.Lookup(parsed)
+		if err != nil {
+			return nil, err
+		}
+		cityFields(record, fields)
+	}
+	if this.asn != nil {
+		record, err := this.asn.reader.Lookup(parsed)
+		if err != nil {
+			return nil, err
+		}
+		asnFields(record, fields)
+	}
+	this.cache.Put(ip, fields)
+	return fields, nil
+}
+
+// Returns the value at the path of map keys, an array index of 0 takes the
+// first element.
+func lookupPath(record interface{}, path ...string) interface{} {
+	for _, key := range path {
+		switch v := record.(type) {
+		case map[string]interface{}:
+			record = v[key]
+		case []interface{}:
+			if key != "0" || len(v) == 0 {
+				return nil
+			}
+			record = v[0]
+		default:
+			return nil
+		}
+	}
+	return record
+}
+
+var cityPaths = []struct {
+	field string
+	path  []string
+}{
+	{"continent_code", []string{"continent", "code"}},
+	{"country_code", []string{"country", "iso_code"}},
+	{"country_name", []string{"country", "names", "en"}},
+	{"region_code", []string{"subdivisions", "0", "iso_code"}},
+	{"region_name", []string{"subdivisions", "0", "names", "en"}},
+	{"city", []string{"city", "names", "en"}},
+	{"postal_code", []string{"postal", "code"}},
+	{"latitude", []string{"location", "latitude"}},
+	{"longitude", []string{"location", "longitude"}},
+	{"timezone", []string{"location", "time_zone"}},
+}
+
+func cityFields(record interface{}, fields map[string]interface{}) {
+	for _, p := range cityPaths {
+		switch v := lookupPath(record, p.path...).(type) {
+		case string:
+			if v != "" {
+				fields[p.field] = v
+			}
+		case float64:
+			fields[p.field] = v
+		}
+	}
+	// Anycast and satellite ranges have no country, only the country their
+	// owner registered them in.
+	if _, ok := fields["country_code"]; !ok {
+		if code, ok := lookupPath(record, "registered_country", "iso_code").(string); ok {
+			fields["country_code"] = code
+		}
+	}
+}
+
+func asnFields(record interface{}, fields map[string]interface{}) {
+	if asn, ok := lookupPath(record, "autonomous_system_number").(uint64); ok {
+		fields["asn"] = int64(asn)
+	}
+	if org, ok := lookupPath(record, "autonomous_system_organization").(string); ok && org != "" {
+		fields["as_org"] = org
+	}
+}
+
+// Returns the first address of a X-Forwarded-For like list, without the port
+// of host:port and the brackets of [IPv6].
+func FirstIP(value string) string {
+	if i := strings.IndexByte(value, ','); i >= 0 {
+		value = value[:i]
+	}
+	value = strings.TrimSpace(value)
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		return host
+	}
+	return strings.Trim(value, "[]")
+}
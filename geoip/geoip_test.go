@@ -0,0 +1,390 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Writes the MaxMind DB data format.
+type encoder struct {
+	bytes.Buffer
+}
+
+func (this *encoder) control(typ, size int) {
+	first := byte(0)
+	if typ > 7 {
+		first = 0
+	} else {
+		first = byte(typ << 5)
+	}
+	switch {
+	case size < 29:
+		first |= byte(size)
+	case size < 285:
+		first |= 29
+	default:
+		first |= 30
+	}
+	this.WriteByte(first)
+	if typ > 7 {
+		this.WriteByte(byte(typ - 7))
+	}
+	switch {
+	case size < 29:
+	case size < 285:
+		this.WriteByte(byte(size - 29))
+	default:
+		this.WriteByte(byte((size - 285) >> 8))
+		this.WriteByte(byte(size - 285))
+	}
+}
+
+func (this *encoder) value(v interface{}) {
+	switch v := v.(type) {
+	case string:
+		this.control(typeString, len(v))
+		this.WriteString(v)
+	case float64:
+		this.control(typeDouble, 8)
+		binary.Write(this, binary.BigEndian, math.Float64bits(v))
+	case uint64:
+		this.control(typeUint64, 8)
+		binary.Write(this, binary.BigEndian, v)
+	case uint32:
+		this.control(typeUint32, 4)
+		binary.Write(this, binary.BigEndian, v)
+	case uint16:
+		this.control(typeUint16, 2)
+		binary.Write(this, binary.BigEndian, v)
+	case int32:
+		this.control(typeInt32, 4)
+		binary.Write(this, binary.BigEndian, v)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		this.control(typeBool, size)
+	case pointer:
+		this.WriteByte(byte(typePointer<<5 | 1<<3))
+		this.WriteByte(byte((int(v) - 2048) >> 8))
+		this.WriteByte(byte(int(v) - 2048))
+	case []interface{}:
+		this.control(typeArray, len(v))
+		for _, e := range v {
+			this.value(e)
+		}
+	case map[string]interface{}:
+		this.control(typeMap, len(v))
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			this.value(k)
+			this.value(v[k])
+		}
+	default:
+		panic(v)
+	}
+}
+
+// Encoded as a 2 byte pointer, so at least 2048.
+type pointer int
+
+type network struct {
+	cidr   string
+	record map[string]interface{}
+}
+
+// Builds a database with 24 bit records.
+func buildDB(ipVersion int, networks []network) []byte {
+	// Children of the nodes, -1 is empty and -2-i the record of network i.
+	nodes := [][2]int{{-1, -1}}
+	var data encoder
+	// Padding so the test of 2 byte pointers has room.
+	data.Write(make([]byte, 2100))
+	offsets := make([]int, len(networks))
+	for i, n := range networks {
+		offsets[i] = data.Len()
+		data.value(n.record)
+		_, ipnet, err := net.ParseCIDR(n.cidr)
+		if err != nil {
+			panic(err)
+		}
+		ip := []byte(ipnet.IP)
+		ones, _ := ipnet.Mask.Size()
+		if ipVersion == 6 && len(ip) == 4 {
+			ip = append(make([]byte, 12), ip...)
+			ones += 96
+		}
+		node := 0
+		for b := 0; b < ones; b++ {
+			bit := int(ip[b/8]>>(7-uint(b%8))) & 1
+			if b == ones-1 {
+				nodes[node][bit] = -2 - i
+				break
+			}
+			if nodes[node][bit] < 0 {
+				nodes = append(nodes, [2]int{-1, -1})
+				nodes[node][bit] = len(nodes) - 1
+			}
+			node = nodes[node][bit]
+		}
+	}
+	var buf bytes.Buffer
+	for _, n := range nodes {
+		for _, child := range n {
+			v := child
+			switch {
+			case child == -1:
+				v = len(nodes)
+			case child < -1:
+				v = len(nodes) + 16 + offsets[-2-child]
+			}
+			buf.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+		}
+	}
+	buf.Write(make([]byte, 16))
+	buf.Write(data.Bytes())
+	buf.Write(metadataMarker)
+	var meta encoder
+	meta.value(map[string]interface{}{
+		"node_count":    uint32(len(nodes)),
+		"record_size":   uint16(24),
+		"ip_version":    uint16(ipVersion),
+		"database_type": "Test",
+	})
+	buf.Write(meta.Bytes())
+	return buf.Bytes()
+}
+
+var cityRecord = map[string]interface{}{
+	"continent":    map[string]interface{}{"code": "AS"},
+	"country":      map[string]interface{}{"iso_code": "CN", "names": map[string]interface{}{"en": "China"}},
+	"subdivisions": []interface{}{map[string]interface{}{"iso_code": "GD", "names": map[string]interface{}{"en": "Guangdong"}}},
+	"city":         map[string]interface{}{"names": map[string]interface{}{"en": "Shenzhen"}},
+	"location":     map[string]interface{}{"latitude": 22.5431, "longitude": 114.0579, "time_zone": "Asia/Shanghai"},
+	"postal":       map[string]interface{}{"code": "518000"},
+	"is_anycast":   true,
+	"offset":       int32(-5),
+}
+
+func TestReader(t *testing.T) {
+	for _, ipVersion := range []int{4, 6} {
+		db := buildDB(ipVersion, []network{
+			{"1.2.3.0/24", cityRecord},
+			{"5.6.0.0/16", map[string]interface{}{"a": "b"}},
+		})
+		r, err := NewReader(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.DatabaseType != "Test" {
+			t.Errorf("database type %q", r.DatabaseType)
+		}
+		record, err := r.Lookup(net.ParseIP("1.2.3.200"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]interface{}{}
+		for k, v := range cityRecord {
+			want[k] = v
+		}
+		want["offset"] = int64(-5)
+		if !reflect.DeepEqual(normalize(record), normalize(want)) {
+			t.Errorf("IPv%d: got %v", ipVersion, record)
+		}
+		if record, err = r.Lookup(net.ParseIP("5.6.255.1")); err != nil || !reflect.DeepEqual(record, map[string]interface{}{"a": "b"}) {
+			t.Errorf("IPv%d: got %v %v", ipVersion, record, err)
+		}
+		if record, err = r.Lookup(net.ParseIP("1.2.4.1")); err != nil || record != nil {
+			t.Errorf("IPv%d: unknown IP got %v %v", ipVersion, record, err)
+		}
+	}
+	if _, err := NewReader([]byte("garbage")); err == nil {
+		t.Error("garbage read")
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	db := buildDB(4, []network{{"1.2.3.0/24", cityRecord}})
+	r, err := NewReader(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.record(r.nodeCount, 0); err == nil {
+		t.Error("node outside the tree read")
+	}
+	// The root's left record leads to 1.2.3.0, pointed at the separator and
+	// past the data.
+	for _, v := range []uint{r.nodeCount + 3, 0xFFFFFF} {
+		corrupt := append([]byte(nil), db...)
+		corrupt[0], corrupt[1], corrupt[2] = byte(v>>16), byte(v>>8), byte(v)
+		if r, err = NewReader(corrupt); err != nil {
+			t.Fatal(err)
+		}
+		if record, err := r.Lookup(net.ParseIP("1.2.3.4")); err == nil {
+			t.Errorf("record %d: got %v", v, record)
+		}
+	}
+
+	// Node counts larger than the file, the last one overflowing the size
+	// of the tree.
+	end := bytes.LastIndex(db, metadataMarker) + len(metadataMarker)
+	for _, n := range []uint64{uint64(end), 1 << 62} {
+		var meta encoder
+		meta.value(map[string]interface{}{
+			"node_count":  n,
+			"record_size": uint16(24),
+			"ip_version":  uint16(6),
+		})
+		if _, err = NewReader(append(append([]byte(nil), db[:end]...), meta.Bytes()...)); err == nil {
+			t.Errorf("node count %d read", n)
+		}
+	}
+}
+
+// Turns the nested []interface{} and maps of the test records into what the
+// reader returns.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{})
+		for k, e := range v {
+			m[k] = normalize(e)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, e := range v {
+			a[i] = normalize(e)
+		}
+		return a
+	}
+	return v
+}
+
+func TestPointers(t *testing.T) {
+	var data encoder
+	data.Write(make([]byte, 2048))
+	data.value("shared")
+	data.value(map[string]interface{}{"a": pointer(2048), "b": pointer(2048)})
+	v, _, err := decoder{data.Bytes()}.decode(2048+7, 0)
+	if err != nil || !reflect.DeepEqual(v, map[string]interface{}{"a": "shared", "b": "shared"}) {
+		t.Errorf("got %v %v", v, err)
+	}
+	// A map holding a pointer to itself.
+	data.Reset()
+	data.Write(make([]byte, 2048))
+	data.value(map[string]interface{}{"a": pointer(2048)})
+	if _, _, err = (decoder{data.Bytes()}).decode(2048, 0); err == nil {
+		t.Error("self reference decoded")
+	}
+	if _, _, err = (decoder{[]byte{typeMap<<5 | 28}}).decode(0, 0); err == nil {
+		t.Error("truncated map decoded")
+	}
+}
+
+func TestLocator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geoip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cityPath := filepath.Join(dir, "city.mmdb")
+	asnPath := filepath.Join(dir, "asn.mmdb")
+	ioutil.WriteFile(cityPath, buildDB(6, []network{{"1.2.3.0/24", cityRecord}}), 0644)
+	ioutil.WriteFile(asnPath, buildDB(6, []network{{"1.2.0.0/16", map[string]interface{}{
+		"autonomous_system_number":       uint32(4134),
+		"autonomous_system_organization": "Chinanet",
+	}}}), 0644)
+
+	l, err := NewLocator(cityPath, asnPath, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := l.Lookup("1.2.3.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"continent_code": "AS",
+		"country_code":   "CN",
+		"country_name":   "China",
+		"region_code":    "GD",
+		"region_name":    "Guangdong",
+		"city":           "Shenzhen",
+		"postal_code":    "518000",
+		"latitude":       22.5431,
+		"longitude":      114.0579,
+		"timezone":       "Asia/Shanghai",
+		"asn":            int64(4134),
+		"as_org":         "Chinanet",
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("got %v\nwant %v", fields, want)
+	}
+	if fields, err = l.Lookup("10.0.0.1"); err != nil || len(fields) != 0 {
+		t.Errorf("private IP: got %v %v", fields, err)
+	}
+	if _, err = l.Lookup("not an ip"); err == nil {
+		t.Error("invalid IP looked up")
+	}
+	l.Lookup("1.2.9.9")
+	if n := l.cache.Len(); n != 2 {
+		t.Errorf("cache holds %d", n)
+	}
+
+	// Nothing changed.
+	if reloaded, err := l.Reload(); reloaded || err != nil {
+		t.Errorf("reloaded %v %v", reloaded, err)
+	}
+	// Replaced by one with another country, renamed over the old file.
+	tmp := cityPath + ".tmp"
+	ioutil.WriteFile(tmp, buildDB(6, []network{{"1.2.3.0/24", map[string]interface{}{
+		"registered_country": map[string]interface{}{"iso_code": "HK"},
+	}}}), 0644)
+	os.Chtimes(tmp, time.Now(), time.Now().Add(time.Minute))
+	os.Rename(tmp, cityPath)
+	if reloaded, err := l.Reload(); !reloaded || err != nil {
+		t.Fatalf("not reloaded: %v", err)
+	}
+	fields, _ = l.Lookup("1.2.3.4")
+	if fields["country_code"] != "HK" || fields["city"] != nil || fields["asn"] != int64(4134) {
+		t.Errorf("after reload got %v", fields)
+	}
+	// A broken file keeps the loaded database.
+	ioutil.WriteFile(cityPath, []byte("broken"), 0644)
+	if _, err = l.Reload(); err == nil {
+		t.Error("broken database loaded")
+	}
+	if fields, _ = l.Lookup("1.2.3.4"); fields["country_code"] != "HK" {
+		t.Errorf("after a failed reload got %v", fields)
+	}
+}
+
+func TestFirstIP(t *testing.T) {
+	for in, want := range map[string]string{
+		"1.2.3.4":                "1.2.3.4",
+		" 1.2.3.4, 10.0.0.1":     "1.2.3.4",
+		"1.2.3.4:8080":           "1.2.3.4",
+		"[2001:db8::1]:443":      "2001:db8::1",
+		"2001:db8::1":            "2001:db8::1",
+		"[2001:db8::1], 1.2.3.4": "2001:db8::1",
+	} {
+		if got := FirstIP(in); got != want {
+			t.Errorf("%s: got %s", in, got)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"net"
+)
+
+// Starts the metadata at the end of a MaxMind DB file.
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// A Reader looks up IPs in a MaxMind DB (.mmdb) file, see
+// https://maxmind.github.io/MaxMind-DB/ for the format.
+type Reader struct {
+	buf        []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	// Bytes of the search tree at the start of buf.
+	treeSize uint
+	data     decoder
+	// Node of ::/96 where IPv4 addresses start in IPv6 trees.
+	ipv4Start uint
+	// Like GeoLite2-City or GeoLite2-ASN.
+	DatabaseType string
+}
+
+func Open(path string) (*Reader, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReader(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return r, nil
+}
+
+func NewReader(buf []byte) (*Reader, error) {
+	end := bytes.LastIndex(buf, metadataMarker)
+	if end < 0 {
+		return nil, fmt.Errorf("not a MaxMind DB")
+	}
+	meta, _, err := decoder{buf[end+len(metadataMarker):]}.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("metadata: %s", err)
+	}
+	m, ok := meta.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("metadata is not a map")
+	}
+	r := &Reader{buf: buf}
+	r.nodeCount, _ = toUint(m["node_count"])
+	r.recordSize, _ = toUint(m["record_size"])
+	r.ipVersion, _ = toUint(m["ip_version"])
+	r.DatabaseType, _ = m["database_type"].(string)
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, fmt.Errorf("unsupported record size %d", r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("unsupported IP version %d", r.ipVersion)
+	}
+	// Nodes take 6 bytes at least, checked first so the size can't overflow.
+	if r.nodeCount > uint(end) {
+		return nil, fmt.Errorf("search tree larger than the file")
+	}
+	r.treeSize = r.nodeCount * r.recordSize / 4
+	if r.treeSize+16 > uint(end) {
+		return nil, fmt.Errorf("search tree larger than the file")
+	}
+	r.data = decoder{buf[r.treeSize+16 : end]}
+	if r.ipVersion == 6 {
+		for i := 0; i < 96 && r.ipv4Start < r.nodeCount; i++ {
+			if r.ipv4Start, err = r.record(r.ipv4Start, 0); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return r, nil
+}
+
+func toUint(v interface{}) (uint, bool) {
+	n, ok := v.(uint64)
+	return uint(n), ok
+}
+
+// Returns the left (bit 0) or right record of the node.
+func (this *Reader) record(node, bit uint) (uint, error) {
+	nodeSize := this.recordSize / 4
+	if node >= this.nodeCount || (node+1)*nodeSize > this.treeSize {
+		return 0, fmt.Errorf("node %d outside the search tree", node)
+	}
+	b := this.buf
+	switch this.recordSize {
+	case 24:
+		off := node*6 + bit*3
+		return uint(b[off])<<16 | uint(b[off+1])<<8 | uint(b[off+2]), nil
+	case 28:
+		off := node * 7
+		if bit == 0 {
+			return uint(b[off+3]&0xF0)<<20 | uint(b[off])<<16 | uint(b[off+1])<<8 | uint(b[off+2]), nil
+		}
+		return uint(b[off+3]&0x0F)<<24 | uint(b[off+4])<<16 | uint(b[off+5])<<8 | uint(b[off+6]), nil
+	default:
+		off := node*8 + bit*4
+		return uint(binary.BigEndian.Uint32(b[off:])), nil
+	}
+}
+
+// Returns the record of the IP, nil when the database has none.
+func (this *Reader) Lookup(ip net.IP) (interface{}, error) {
+	addr := ip.To4()
+	node := uint(0)
+	if addr != nil {
+		if this.ipVersion == 6 {
+			node = this.ipv4Start
+		}
+	} else {
+		if this.ipVersion == 4 {
+			return nil, nil
+		}
+		if addr = ip.To16(); addr == nil {
+			return nil, fmt.Errorf("invalid IP %v", ip)
+		}
+	}
+	var err error
+	for i := uint(0); i < uint(len(addr))*8 && node < this.nodeCount; i++ {
+		if node, err = this.record(node, uint(addr[i>>3]>>(7-i&7))&1); err != nil {
+			return nil, err
+		}
+	}
+	if node == this.nodeCount {
+		return nil, nil
+	}
+	if node < this.nodeCount {
+		return nil, fmt.Errorf("search tree deeper than the IP")
+	}
+	// The 16 bytes between the tree and the data are zeros.
+	if node-this.nodeCount < 16 {
+		return nil, fmt.Errorf("record %d points between the search tree and the data", node)
+	}
+	value, _, err := this.data.decode(node-this.nodeCount-16, 0)
+	return value, err
+}
+
+// Decodes the data section and the metadata.
+type decoder struct {
+	buf []byte
+}
+
+// Maps and arrays can point at themselves in a corrupt file.
+const maxDepth = 32
+
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// Returns the value at offset and the offset after it.
+func (this decoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxDepth {
+		return nil, 0, fmt.Errorf("data nested too deep")
+	}
+	if offset >= uint(len(this.buf)) {
+		return nil, 0, fmt.Errorf("data offset %d out of range", offset)
+	}
+	ctrl := this.buf[offset]
+	offset++
+	typ := uint(ctrl >> 5)
+	if typ == typePointer {
+		target, next, err := this.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		if target < uint(len(this.buf)) && this.buf[target]>>5 == typePointer {
+			return nil, 0, fmt.Errorf("pointer to a pointer")
+		}
+		value, _, err := this.decode(target, depth+1)
+		return value, next, err
+	}
+	if typ == typeExtended {
+		if offset >= uint(len(this.buf)) {
+			return nil, 0, fmt.Errorf("truncated data")
+		}
+		typ = 7 + uint(this.buf[offset])
+		offset++
+	}
+	size, offset, err := this.size(ctrl, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Every element takes a byte at least, larger sizes are corrupt rather
+	// than allocated.
+	if (typ == typeMap || typ == typeArray) && size > uint(len(this.buf))-offset {
+		return nil, 0, fmt.Errorf("truncated data")
+	}
+	switch typ {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			var key, value interface{}
+			if key, offset, err = this.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("map key %v is not a string", key)
+			}
+			if value, offset, err = this.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			m[k] = value
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]interface{}, size)
+		for i := range a {
+			if a[i], offset, err = this.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(this.buf)) {
+		return nil, 0, fmt.Errorf("truncated data")
+	}
+	b := this.buf[offset : offset+size]
+	offset += size
+	switch typ {
+	case typeString:
+		return string(b), offset, nil
+	case typeBytes:
+		return append([]byte(nil), b...), offset, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("double of %d bytes", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("float of %d bytes", size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), offset, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("unsigned integer of %d bytes", size)
+		}
+		return bigEndian(b), offset, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("int32 of %d bytes", size)
+		}
+		return int64(int32(uint32(bigEndian(b)))), offset, nil
+	case typeUint128:
+		return new(big.Int).SetBytes(b), offset, nil
+	}
+	return nil, 0, fmt.Errorf("unexpected data type %d", typ)
+}
+
+func (this decoder) size(ctrl byte, offset uint) (uint, uint, error) {
+	size := uint(ctrl & 0x1f)
+	if size < 29 {
+		return size, offset, nil
+	}
+	n := size - 28
+	if offset+n > uint(len(this.buf)) {
+		return 0, 0, fmt.Errorf("truncated data")
+	}
+	v := uint(bigEndian(this.buf[offset : offset+n]))
+	switch size {
+	case 29:
+		size = 29 + v
+	case 30:
+		size = 285 + v
+	default:
+		size = 65821 + v
+	}
+	return size, offset + n, nil
+}
+
+func (this decoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	ss := uint(ctrl>>3) & 3
+	n := ss + 1
+	if offset+n > uint(len(this.buf)) {
+		return 0, 0, fmt.Errorf("truncated pointer")
+	}
+	v := uint(bigEndian(this.buf[offset : offset+n]))
+	vvv := uint(ctrl & 7)
+	switch ss {
+	case 0:
+		v |= vvv << 8
+	case 1:
+		v = (v | vvv<<16) + 2048
+	case 2:
+		v = (v | vvv<<24) + 526336
+	}
+	return v, offset + n, nil
+}
+
+func bigEndian(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
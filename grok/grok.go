@@ -0,0 +1,191 @@
+// Package grok compiles grok expressions, regular expressions with
+// %{PATTERN:field:type} references to named patterns, as used by logstash.
+package grok
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// %{NAME}, %{NAME:field} or %{NAME:field:type}.
+var reference = regexp.MustCompile(`%{(\w+)(?::([^:}]+))?(?::(\w+))?}`)
+
+// A Grok holds named patterns, the built-in ones and those added.
+type Grok struct {
+	patterns map[string]string
+}
+
+// Returns a Grok with the built-in patterns.
+func New() *Grok {
+	g := &Grok{patterns: make(map[string]string)}
+	if err := g.AddPatterns(strings.NewReader(builtinPatterns)); err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// Adds or replaces a pattern.
+func (this *Grok) AddPattern(name, pattern string) {
+	this.patterns[name] = pattern
+}
+
+// Reads "NAME pattern" lines, blank lines and lines starting with # are
+// skipped.
+func (this *Grok) AddPatterns(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.IndexAny(line, " \t")
+		if i < 0 {
+			return fmt.Errorf("line %d: no pattern for %s", n, line)
+		}
+		this.AddPattern(line[:i], strings.TrimSpace(line[i:]))
+	}
+	return scanner.Err()
+}
+
+func (this *Grok) AddPatternsFromFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err = this.AddPatterns(f); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	return nil
+}
+
+// Prefixes the names of the groups fields are captured by.
+const groupPrefix = "__grok"
+
+// A captured field and the type its values are converted to.
+type field struct {
+	name string
+	typ  string
+}
+
+// A compiled grok expression.
+type Pattern struct {
+	re *regexp.Regexp
+	// By regexp group, nil for the groups that aren't fields.
+	fields []*field
+}
+
+func (this *Grok) Compile(expr string) (*Pattern, error) {
+	p := &Pattern{}
+	// Field names may contain characters Go group names can't, the groups
+	// are named __grok<n> and looked up in groups. Groups the expression
+	// names itself, (?P<name>...), are string fields.
+	groups := make(map[string]*field)
+	expanded, err := this.expand(expr, groups, nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.re, err = regexp.Compile(expanded); err != nil {
+		return nil, fmt.Errorf("%s: %s", expr, err)
+	}
+	names := p.re.SubexpNames()
+	p.fields = make([]*field, len(names))
+	prefixed := 0
+	for i, name := range names {
+		if strings.HasPrefix(name, groupPrefix) {
+			prefixed++
+			p.fields[i] = groups[name]
+		} else if name != "" {
+			p.fields[i] = &field{name: name}
+		}
+	}
+	if prefixed != len(groups) {
+		return nil, fmt.Errorf("%s: group names starting with %s are reserved", expr, groupPrefix)
+	}
+	return p, nil
+}
+
+func (this *Grok) expand(expr string, groups map[string]*field, stack []string) (string, error) {
+	var err error
+	expanded := reference.ReplaceAllStringFunc(expr, func(ref string) string {
+		if err != nil {
+			return ""
+		}
+		m := reference.FindStringSubmatch(ref)
+		name, fieldName, typ := m[1], m[2], m[3]
+		for _, s := range stack {
+			if s == name {
+				err = fmt.Errorf("pattern %s references itself", name)
+				return ""
+			}
+		}
+		pattern, ok := this.patterns[name]
+		if !ok {
+			err = fmt.Errorf("unknown pattern %s", name)
+			return ""
+		}
+		switch typ {
+		case "", "string", "int", "float":
+		default:
+			err = fmt.Errorf("unknown type %s of %s", typ, fieldName)
+			return ""
+		}
+		var sub string
+		if sub, err = this.expand(pattern, groups, append(stack, name)); err != nil {
+			return ""
+		}
+		if fieldName == "" {
+			return "(?:" + sub + ")"
+		}
+		group := fmt.Sprintf("%s%d", groupPrefix, len(groups))
+		groups[group] = &field{name: fieldName, typ: typ}
+		return "(?P<" + group + ">" + sub + ")"
+	})
+	return expanded, err
+}
+
+// Adds the fields to data and reports whether the text matched. Fields
+// that didn't participate in the match are left out.
+func (this *Pattern) Match(text string, data map[string]interface{}) bool {
+	m := this.re.FindStringSubmatchIndex(text)
+	if m == nil {
+		return false
+	}
+	for i, f := range this.fields {
+		if f == nil || m[2*i] < 0 {
+			continue
+		}
+		data[f.name] = convert(text[m[2*i]:m[2*i+1]], f.typ)
+	}
+	return true
+}
+
+// Returns the regexp the expression expanded to.
+func (this *Pattern) String() string {
+	return this.re.String()
+}
+
+// Values that don't convert stay strings.
+func convert(value, typ string) interface{} {
+	switch typ {
+	case "int":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return int64(f)
+		}
+	case "float":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return value
+}
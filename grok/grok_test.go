@@ -0,0 +1,82 @@
+package grok
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCombinedApacheLog(t *testing.T) {
+	p, err := New().Compile("%{COMBINEDAPACHELOG}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{}
+	line := `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08"`
+	if !p.Match(line, data) {
+		t.Fatalf("no match: %s", p)
+	}
+	want := map[string]interface{}{
+		"clientip":    "127.0.0.1",
+		"ident":       "-",
+		"auth":        "frank",
+		"timestamp":   "10/Oct/2000:13:55:36 -0700",
+		"verb":        "GET",
+		"request":     "/apache_pb.gif",
+		"httpversion": "1.0",
+		"response":    int64(200),
+		"bytes":       int64(2326),
+		"referrer":    `"http://www.example.com/start.html"`,
+		"agent":       `"Mozilla/4.08"`,
+	}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("got %v\nwant %v", data, want)
+	}
+}
+
+func TestTypesAndCustomPatterns(t *testing.T) {
+	g := New()
+	if err := g.AddPatterns(strings.NewReader("# comment\nDURATION %{NUMBER}ms\n")); err != nil {
+		t.Fatal(err)
+	}
+	p, err := g.Compile(`%{IPORHOST:client.ip} took %{DURATION:took:float} size %{NUMBER:size:int} %{WORD}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{}
+	if !p.Match("fe80::1 took 1.5ms size 3.0 done", data) {
+		t.Fatalf("no match: %s", p)
+	}
+	want := map[string]interface{}{"client.ip": "fe80::1", "took": "1.5ms", "size": int64(3)}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("got %v, want %v", data, want)
+	}
+	if p.Match("nothing here", data) {
+		t.Error("matched")
+	}
+}
+
+func TestNamedGroups(t *testing.T) {
+	p, err := New().Compile(`(?P<g0>\w+) %{INT:g1:int} (?P<status>\d+)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{}
+	if !p.Match("GET 12 200", data) {
+		t.Fatalf("no match: %s", p)
+	}
+	want := map[string]interface{}{"g0": "GET", "g1": int64(12), "status": "200"}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("got %v, want %v", data, want)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	g := New()
+	g.AddPattern("LOOP", "a%{LOOP}")
+	for _, expr := range []string{"%{NOPE}", "%{LOOP}", "%{INT:n:bogus}", "%{INT:n}(", `%{INT:n} (?P<__grok0>\d+)`} {
+		if _, err := g.Compile(expr); err == nil {
+			t.Errorf("%s compiled", expr)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package grok
+
+// The built-in patterns, in the format of pattern files. They follow the
+// logstash library, rewritten without the lookarounds and atomic groups Go
+// regexps lack.
+const builtinPatterns = `
+USERNAME [a-zA-Z0-9._-]+
+USER %{USERNAME}
+EMAILLOCALPART [a-zA-Z][a-zA-Z0-9_.+=:-]+
+EMAILADDRESS %{EMAILLOCALPART}@%{HOSTNAME}
+HTTPDUSER %{EMAILADDRESS}|%{USER}
+INT [+-]?[0-9]+
+BASE10NUM [+-]?(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+)
+NUMBER %{BASE10NUM}
+BASE16NUM [+-]?(?:0[xX])?[0-9A-Fa-f]+
+POSINT \b[1-9][0-9]*\b
+NONNEGINT \b[0-9]+\b
+WORD \b\w+\b
+NOTSPACE \S+
+SPACE \s*
+DATA .*?
+GREEDYDATA .*
+QUOTEDSTRING "(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|` + "`(?:[^`\\\\]|\\\\.)*`" + `
+QS %{QUOTEDSTRING}
+UUID [A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}
+
+CISCOMAC (?:[A-Fa-f0-9]{4}\.){2}[A-Fa-f0-9]{4}
+WINDOWSMAC (?:[A-Fa-f0-9]{2}-){5}[A-Fa-f0-9]{2}
+COMMONMAC (?:[A-Fa-f0-9]{2}:){5}[A-Fa-f0-9]{2}
+MAC %{CISCOMAC}|%{WINDOWSMAC}|%{COMMONMAC}
+IPV6HEX [0-9A-Fa-f]{1,4}
+IPV6 (?:%{IPV6HEX}:){7}%{IPV6HEX}|(?:%{IPV6HEX}:){6}%{IPV4}|::(?:[fF]{4}:)?%{IPV4}|%{IPV6HEX}:(?::%{IPV6HEX}){1,6}|(?:%{IPV6HEX}:){1,2}(?::%{IPV6HEX}){1,5}|(?:%{IPV6HEX}:){1,3}(?::%{IPV6HEX}){1,4}|(?:%{IPV6HEX}:){1,4}(?::%{IPV6HEX}){1,3}|(?:%{IPV6HEX}:){1,5}(?::%{IPV6HEX}){1,2}|(?:%{IPV6HEX}:){1,6}:%{IPV6HEX}|:(?::%{IPV6HEX}){1,7}|(?:%{IPV6HEX}:){1,7}:|::
+IPV4OCTET 25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9]
+IPV4 (?:%{IPV4OCTET}\.){3}%{IPV4OCTET}
+IP %{IPV6}|%{IPV4}
+HOSTNAME \b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?
+IPORHOST %{IP}|%{HOSTNAME}
+HOSTPORT %{IPORHOST}:%{POSINT}
+
+PATH %{UNIXPATH}|%{WINPATH}
+UNIXPATH (?:/[\w_%!$@:.,+~-]*)+
+WINPATH (?:[A-Za-z]+:|\\)(?:\\[^\\?*]*)+
+URIPROTO [A-Za-z][A-Za-z0-9+.-]*
+URIHOST %{IPORHOST}(?::%{POSINT})?
+URIPATH (?:/[A-Za-z0-9$.+!*'(){},~:;=@#%&_-]*)+
+URIPARAM \?[A-Za-z0-9$.+!*'|(){},~@#%&/=:;_?\[\]<>-]*
+URIPATHPARAM %{URIPATH}(?:%{URIPARAM})?
+URI %{URIPROTO}://(?:%{USER}(?::[^@]*)?@)?(?:%{URIHOST})?(?:%{URIPATHPARAM})?
+
+MONTH \b(?:Jan(?:uary)?|Feb(?:ruary)?|Mar(?:ch)?|Apr(?:il)?|May|Jun(?:e)?|Jul(?:y)?|Aug(?:ust)?|Sep(?:tember)?|Oct(?:ober)?|Nov(?:ember)?|Dec(?:ember)?)\b
+MONTHNUM 0?[1-9]|1[0-2]
+MONTHNUM2 0[1-9]|1[0-2]
+MONTHDAY 0[1-9]|[12][0-9]|3[01]|[1-9]
+DAY \b(?:Mon(?:day)?|Tue(?:sday)?|Wed(?:nesday)?|Thu(?:rsday)?|Fri(?:day)?|Sat(?:urday)?|Sun(?:day)?)\b
+YEAR (?:\d\d){1,2}
+HOUR 2[0123]|[01]?[0-9]
+MINUTE [0-5][0-9]
+SECOND (?:[0-5]?[0-9]|60)(?:[:.,][0-9]+)?
+TIME %{HOUR}:%{MINUTE}(?::%{SECOND})?
+DATE_US %{MONTHNUM}[/-]%{MONTHDAY}[/-]%{YEAR}
+DATE_EU %{MONTHDAY}[./-]%{MONTHNUM}[./-]%{YEAR}
+ISO8601_TIMEZONE Z|[+-]%{HOUR}(?::?%{MINUTE})
+ISO8601_SECOND %{SECOND}|60
+TIMESTAMP_ISO8601 %{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?(?:%{ISO8601_TIMEZONE})?
+DATE %{DATE_US}|%{DATE_EU}
+DATESTAMP %{DATE}[- ]%{TIME}
+TZ [APMCE][SD]T|UTC
+DATESTAMP_RFC822 %{DAY} %{MONTH} %{MONTHDAY} %{YEAR} %{TIME} %{TZ}
+DATESTAMP_RFC2822 %{DAY}, %{MONTHDAY} %{MONTH} %{YEAR} %{TIME} %{ISO8601_TIMEZONE}
+DATESTAMP_OTHER %{DAY} %{MONTH} %{MONTHDAY} %{TIME} %{TZ} %{YEAR}
+HTTPDATE %{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} %{INT}
+
+SYSLOGTIMESTAMP %{MONTH} +%{MONTHDAY} %{TIME}
+PROG [\x21-\x5a\x5c\x5e-\x7e]+
+SYSLOGPROG %{PROG:program}(?:\[%{POSINT:pid:int}\])?
+SYSLOGHOST %{IPORHOST}
+SYSLOGFACILITY <%{NONNEGINT:facility:int}.%{NONNEGINT:priority:int}>
+SYSLOGBASE %{SYSLOGTIMESTAMP:timestamp} (?:%{SYSLOGFACILITY} )?%{SYSLOGHOST:logsource} %{SYSLOGPROG}:
+SYSLOGLINE %{SYSLOGBASE} %{GREEDYDATA:message}
+LOGLEVEL [Aa]lert|ALERT|[Tt]race|TRACE|[Dd]ebug|DEBUG|[Nn]otice|NOTICE|[Ii]nfo|INFO|[Ww]arn(?:ing)?|WARN(?:ING)?|[Ee]rr(?:or)?|ERR(?:OR)?|[Cc]rit(?:ical)?|CRIT(?:ICAL)?|[Ff]atal|FATAL|[Ss]evere|SEVERE|[Ee]merg(?:ency)?|EMERG(?:ENCY)?
+
+COMMONAPACHELOG %{IPORHOST:clientip} %{HTTPDUSER:ident} %{HTTPDUSER:auth} \[%{HTTPDATE:timestamp}\] "(?:%{WORD:verb} %{NOTSPACE:request}(?: HTTP/%{NUMBER:httpversion})?|%{DATA:rawrequest})" %{NUMBER:response:int} (?:%{NUMBER:bytes:int}|-)
+COMBINEDAPACHELOG %{COMMONAPACHELOG} %{QS:referrer} %{QS:agent}
+`
@@ -0,0 +1,176 @@
+// Package jsonline turns JSON objects, as written by structured loggers,
+// into fields, and maps fields onto the tag, timestamp and bytes of a
+// message.
+package jsonline
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// A Parser copies the members of decoded JSON objects into the data, its
+// settings must not change while it's in use.
+type Parser struct {
+	// Flatten nested objects into keys joined by the Separator,
+	// {"meta":{"host":"a"}} becomes meta.host.
+	Flatten   bool
+	Separator string
+}
+
+// Returns a parser flattening with ".".
+func New() *Parser {
+	return &Parser{Flatten: true, Separator: "."}
+}
+
+// Adds the members of value, a JSON object decoded into a
+// map[string]interface{} by ugorji/go/codec, to data. Integers become int64,
+// or float64 when too large, other numbers float64; strings, booleans, nulls
+// and arrays keep their type. Values other than objects are errors.
+func (this *Parser) Add(value interface{}, data map[string]interface{}) error {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("not a JSON object")
+	}
+	object = convert(object).(map[string]interface{})
+	if this.Flatten {
+		flatten(data, "", this.Separator, object)
+		return nil
+	}
+	for k, v := range object {
+		data[k] = v
+	}
+	return nil
+}
+
+// Replaces the unsigned integers of the value by int64, or float64 when
+// they don't fit.
+func convert(value interface{}) interface{} {
+	switch v := value.(type) {
+	case uint64:
+		if v > math.MaxInt64 {
+			return float64(v)
+		}
+		return int64(v)
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = convert(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = convert(e)
+		}
+	}
+	return value
+}
+
+// Copies the object into data, the keys of nested objects joined to the
+// keys of their parents by sep. Empty objects are kept as they are.
+func flatten(data map[string]interface{}, prefix, sep string, object map[string]interface{}) {
+	for k, v := range object {
+		if prefix != "" {
+			k = prefix + sep + k
+		}
+		if nested, ok := v.(map[string]interface{}); ok && len(nested) > 0 {
+			flatten(data, k, sep, nested)
+			continue
+		}
+		data[k] = v
+	}
+}
+
+// Fields of the data that become parts of the message.
+type Mapping struct {
+	TagField       string
+	TimestampField string
+	// Go time layout of string timestamps, numbers are unix seconds or
+	// milliseconds.
+	TimestampLayout string
+	BytesField      string
+	// Remove the mapped fields from the data.
+	RemoveMapped bool
+}
+
+// What a Mapping took from the data.
+type Envelope struct {
+	// Empty without the tag field.
+	Tag string
+	// Unix seconds, 0 without the timestamp field.
+	Timestamp int64
+	// Nil without the bytes field.
+	Bytes []byte
+}
+
+// Takes the mapped fields out of data.
+func (this *Mapping) Apply(data map[string]interface{}) (*Envelope, error) {
+	env := &Envelope{}
+	if field := this.TagField; field != "" {
+		if v, ok := data[field]; ok {
+			env.Tag = fmt.Sprint(v)
+			this.remove(data, field)
+		}
+	}
+	if field := this.TimestampField; field != "" {
+		if v, ok := data[field]; ok {
+			ts, err := ParseTimestamp(v, this.TimestampLayout)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", field, err)
+			}
+			env.Timestamp = ts
+			this.remove(data, field)
+		}
+	}
+	if field := this.BytesField; field != "" {
+		if v, ok := data[field]; ok {
+			env.Bytes = []byte(fmt.Sprint(v))
+			this.remove(data, field)
+		}
+	}
+	return env, nil
+}
+
+func (this *Mapping) remove(data map[string]interface{}, field string) {
+	if this.RemoveMapped {
+		delete(data, field)
+	}
+}
+
+// Returns unix seconds. Numbers, or strings of them, are unix seconds, or
+// milliseconds when too large for seconds (after 2001-09-09 in
+// milliseconds, the year 33658 in seconds); other strings use the layout.
+func ParseTimestamp(v interface{}, layout string) (int64, error) {
+	var n float64
+	switch t := v.(type) {
+	case int64:
+		n = float64(t)
+	case uint64:
+		n = float64(t)
+	case float64:
+		n = t
+	case string:
+		f, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			ts, err := time.Parse(layout, t)
+			if err != nil {
+				return 0, err
+			}
+			return ts.Unix(), nil
+		}
+		n = f
+	default:
+		return 0, fmt.Errorf("unsupported timestamp %v", v)
+	}
+	if n > 1e12 {
+		n /= 1000
+	}
+	return int64(n), nil
+}
+
+// The input for error messages, cut to its first bytes.
+func Quote(b []byte) string {
+	if len(b) > 64 {
+		return strconv.Quote(string(b[:64])) + "..."
+	}
+	return strconv.Quote(string(b))
+}
@@ -0,0 +1,148 @@
+package jsonline
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The values are shaped like ugorji/go/codec decodes them: positive
+// integers are uint64, negative ones int64.
+func TestAdd(t *testing.T) {
+	for i, test := range []struct {
+		parser *Parser
+		value  map[string]interface{}
+		want   map[string]interface{}
+	}{
+		{New(), map[string]interface{}{
+			"a": uint64(1),
+			"b": 1.5,
+			"c": "x",
+			"d": true,
+			"e": nil,
+			"f": []interface{}{uint64(1), "y", map[string]interface{}{"g": uint64(2)}},
+		}, map[string]interface{}{
+			"a": int64(1),
+			"b": 1.5,
+			"c": "x",
+			"d": true,
+			"e": nil,
+			"f": []interface{}{int64(1), "y", map[string]interface{}{"g": int64(2)}},
+		}},
+		{New(), map[string]interface{}{
+			"big": uint64(9223372036854775808),
+			"max": uint64(9223372036854775807),
+			"neg": int64(-7),
+		}, map[string]interface{}{
+			"big": 9223372036854775808.0,
+			"max": int64(9223372036854775807),
+			"neg": int64(-7),
+		}},
+		{New(), map[string]interface{}{
+			"meta":  map[string]interface{}{"host": "a", "geo": map[string]interface{}{"cc": "CN"}},
+			"empty": map[string]interface{}{},
+		}, map[string]interface{}{
+			"meta.host":   "a",
+			"meta.geo.cc": "CN",
+			"empty":       map[string]interface{}{},
+		}},
+		{&Parser{Flatten: true, Separator: "__"}, map[string]interface{}{
+			"meta": map[string]interface{}{"host": "a"},
+		}, map[string]interface{}{
+			"meta__host": "a",
+		}},
+		{&Parser{}, map[string]interface{}{
+			"meta": map[string]interface{}{"port": uint64(80)},
+		}, map[string]interface{}{
+			"meta": map[string]interface{}{"port": int64(80)},
+		}},
+	} {
+		data := map[string]interface{}{}
+		if err := test.parser.Add(test.value, data); err != nil {
+			t.Errorf("%d: %s", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(data, test.want) {
+			t.Errorf("%d:\ngot  %#v\nwant %#v", i, data, test.want)
+		}
+	}
+}
+
+func TestAddErrors(t *testing.T) {
+	for _, value := range []interface{}{
+		nil,
+		[]interface{}{uint64(1), uint64(2)},
+		"text",
+		uint64(1),
+		map[interface{}]interface{}{"a": uint64(1)},
+	} {
+		data := map[string]interface{}{}
+		if err := New().Add(value, data); err == nil {
+			t.Errorf("%#v added as %v", value, data)
+		} else if len(data) != 0 {
+			t.Errorf("%#v left %v", value, data)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	if got := Quote([]byte("a\"b")); got != `"a\"b"` {
+		t.Errorf("got %s", got)
+	}
+	if got := Quote([]byte(strings.Repeat("x", 100))); got != `"`+strings.Repeat("x", 64)+`"...` {
+		t.Errorf("long input: %s", got)
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	for _, test := range []struct {
+		value  interface{}
+		layout string
+		want   int64
+	}{
+		{int64(1700000000), "", 1700000000},
+		{int64(1700000000123), "", 1700000000},
+		{uint64(1700000000123), "", 1700000000},
+		{1700000000.9, "", 1700000000},
+		{"1700000000", "", 1700000000},
+		{"1700000000123", "", 1700000000},
+		// The largest seconds and the smallest milliseconds told apart.
+		{int64(1e12), "", 1e12},
+		{int64(1e12 + 1), "", 1e9},
+		{"2023-11-14T22:13:20Z", time.RFC3339, 1700000000},
+		{"14/Nov/2023:22:13:20 +0000", "02/Jan/2006:15:04:05 -0700", 1700000000},
+	} {
+		got, err := ParseTimestamp(test.value, test.layout)
+		if err != nil || got != test.want {
+			t.Errorf("%v: got %d %v, want %d", test.value, got, err, test.want)
+		}
+	}
+	for _, value := range []interface{}{"yesterday", true, nil, []interface{}{}} {
+		if _, err := ParseTimestamp(value, time.RFC3339); err == nil {
+			t.Errorf("%v parsed", value)
+		}
+	}
+}
+
+func TestMapping(t *testing.T) {
+	data := map[string]interface{}{"app": "nginx", "time": "2023-11-14T22:13:20Z", "message": "hello", "level": "info"}
+	m := &Mapping{TagField: "app", TimestampField: "time", TimestampLayout: time.RFC3339, BytesField: "message"}
+	env, err := m.Apply(data)
+	if err != nil || env.Tag != "nginx" || env.Timestamp != 1700000000 || string(env.Bytes) != "hello" || len(data) != 4 {
+		t.Errorf("got %+v %v, data %v", env, err, data)
+	}
+
+	m.RemoveMapped = true
+	env, err = m.Apply(data)
+	if err != nil || !reflect.DeepEqual(data, map[string]interface{}{"level": "info"}) {
+		t.Errorf("remove mapped: got %+v %v, data %v", env, err, data)
+	}
+	// Fields not present map nothing.
+	if env, err = m.Apply(data); err != nil || env.Tag != "" || env.Timestamp != 0 || env.Bytes != nil {
+		t.Errorf("without the fields: got %+v %v", env, err)
+	}
+	if _, err = m.Apply(map[string]interface{}{"time": "yesterday"}); err == nil || !strings.HasPrefix(err.Error(), "time: ") {
+		t.Errorf("invalid timestamp: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+// Package kv splits lines of key-value pairs, logfmt like
+// `level=info msg="started" took=12ms` or CDN logs like `k:v|k:v`.
+package kv
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A Parser splits lines into fields, its settings must not change while it's
+// in use.
+type Parser struct {
+	// Separates the pairs, " " by default.
+	PairSeparator string
+	// Separates the key from the value, "=" by default.
+	KeySeparator string
+	// Characters values may be quoted with, a backslash escapes the quote
+	// inside. Empty disables quoting.
+	Quotes string
+	// Characters trimmed from both ends of keys and unquoted values.
+	Trim string
+	// Prepended to the keys.
+	Prefix string
+	// When not empty only these keys are kept.
+	Include map[string]bool
+	// Keys left out.
+	Exclude map[string]bool
+	// Turns values into int64, float64 or bool when they look like one.
+	InferTypes bool
+}
+
+// Returns a logfmt parser.
+func New() *Parser {
+	return &Parser{
+		PairSeparator: " ",
+		KeySeparator:  "=",
+		Quotes:        `"`,
+	}
+}
+
+// Adds the pairs of the line to data and returns how many there were. Words
+// without the key separator are skipped, unterminated quotes are an error.
+func (this *Parser) Parse(line string, data map[string]interface{}) (int, error) {
+	if this.PairSeparator == "" || this.KeySeparator == "" {
+		return 0, fmt.Errorf("empty separator")
+	}
+	n := 0
+	s := line
+	for s != "" {
+		if strings.HasPrefix(s, this.PairSeparator) {
+			s = s[len(this.PairSeparator):]
+			continue
+		}
+		end := strings.Index(s, this.PairSeparator)
+		if end < 0 {
+			end = len(s)
+		}
+		sep := strings.Index(s[:end], this.KeySeparator)
+		if sep < 0 {
+			s = s[end:]
+			continue
+		}
+		key := strings.Trim(s[:sep], this.Trim)
+		s = s[sep+len(this.KeySeparator):]
+
+		var value string
+		quoted := false
+		if s != "" && strings.IndexByte(this.Quotes, s[0]) >= 0 {
+			var err error
+			if value, s, err = unquote(s); err != nil {
+				return n, fmt.Errorf("%s of %s", err, key)
+			}
+			quoted = true
+		} else {
+			end = strings.Index(s, this.PairSeparator)
+			if end < 0 {
+				end = len(s)
+			}
+			value = strings.Trim(s[:end], this.Trim)
+			s = s[end:]
+		}
+		if key == "" || !this.keep(key) {
+			continue
+		}
+		n++
+		if this.InferTypes && !quoted {
+			data[this.Prefix+key] = infer(value)
+		} else {
+			data[this.Prefix+key] = value
+		}
+	}
+	return n, nil
+}
+
+func (this *Parser) keep(key string) bool {
+	if len(this.Include) > 0 && !this.Include[key] {
+		return false
+	}
+	return !this.Exclude[key]
+}
+
+// Returns the value in the quotes s starts with and what follows them.
+func unquote(s string) (string, string, error) {
+	quote := s[0]
+	var value []byte
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) && (s[i+1] == quote || s[i+1] == '\\') {
+			value = append(value, s[i+1])
+			i++
+			continue
+		}
+		if c == quote {
+			return string(value), s[i+1:], nil
+		}
+		value = append(value, c)
+	}
+	return "", "", fmt.Errorf("unterminated quote in value")
+}
+
+func infer(value string) interface{} {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n
+	}
+	// "inf" and "nan" stay strings.
+	if f, err := strconv.ParseFloat(value, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return f
+	}
+	if b, err := strconv.ParseBool(value); err == nil && len(value) > 1 {
+		return b
+	}
+	return value
+}
@@ -0,0 +1,64 @@
+package kv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLogfmt(t *testing.T) {
+	p := New()
+	p.InferTypes = true
+	data := map[string]interface{}{}
+	n, err := p.Parse(`ts=2026-10-19T13:55:36Z level=info  msg="user \"bob\" logged in" took=12 ratio=0.5 ok=true n="42" bare empty= inf=inf`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"ts":    "2026-10-19T13:55:36Z",
+		"level": "info",
+		"msg":   `user "bob" logged in`,
+		"took":  int64(12),
+		"ratio": 0.5,
+		"ok":    true,
+		"n":     "42",
+		"empty": "",
+		"inf":   "inf",
+	}
+	if n != len(want) || !reflect.DeepEqual(data, want) {
+		t.Errorf("got %d %v\nwant %v", n, data, want)
+	}
+	if _, err = p.Parse(`msg="unterminated`, data); err == nil {
+		t.Error("unterminated quote parsed")
+	}
+}
+
+func TestSeparators(t *testing.T) {
+	p := &Parser{
+		PairSeparator: "|",
+		KeySeparator:  ":",
+		Quotes:        `"'`,
+		Trim:          " ",
+		Prefix:        "cdn_",
+		Exclude:       map[string]bool{"secret": true},
+	}
+	data := map[string]interface{}{}
+	if _, err := p.Parse(` host : a.example.com |status:200|path:'/a|b'|secret:x|time:13:55:36`, data); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"cdn_host":   "a.example.com",
+		"cdn_status": "200",
+		"cdn_path":   "/a|b",
+		"cdn_time":   "13:55:36",
+	}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("got %v\nwant %v", data, want)
+	}
+
+	p.Include = map[string]bool{"host": true, "secret": true}
+	data = map[string]interface{}{}
+	p.Parse("host:a|status:200|secret:x", data)
+	if !reflect.DeepEqual(data, map[string]interface{}{"cdn_host": "a"}) {
+		t.Errorf("include: got %v", data)
+	}
+}
@@ -0,0 +1,267 @@
+// Package logger is kaman's leveled logger for its own diagnostics. Every
+// entry has a level, a message and optional key/value context, and is written
+// as a text line or a JSON object.
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelFatal
+)
+
+var levelNames = []string{"debug", "info", "warn", "error", "fatal"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelFatal {
+		return "level(" + strconv.Itoa(int(l)) + ")"
+	}
+	return levelNames[l]
+}
+
+func ParseLevel(s string) (Level, error) {
+	s = strings.ToLower(s)
+	if s == "warning" {
+		s = "warn"
+	}
+	for i, name := range levelNames {
+		if name == s {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level: %s", s)
+}
+
+type Format int32
+
+const (
+	FormatText Format = iota
+	FormatJson
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "text":
+		return FormatText, nil
+	case "json":
+		return FormatJson, nil
+	}
+	return FormatText, fmt.Errorf("unknown log format: %s", s)
+}
+
+// Shared by a logger and all loggers derived from it with With.
+type core struct {
+	mu     sync.Mutex
+	out    io.Writer
+	level  int32
+	format int32
+	buf    bytes.Buffer
+}
+
+type Logger struct {
+	core   *core
+	fields []interface{}
+}
+
+func New(out io.Writer) *Logger {
+	return &Logger{
+		core: &core{
+			out:   out,
+			level: int32(LevelInfo),
+		},
+	}
+}
+
+// Returns a logger that adds the key/value pairs to every entry.
+func (l *Logger) With(kv ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(kv))
+	fields = append(fields, l.fields...)
+	fields = append(fields, kv...)
+	return &Logger{core: l.core, fields: fields}
+}
+
+func (l *Logger) SetOutput(out io.Writer) {
+	l.core.mu.Lock()
+	l.core.out = out
+	l.core.mu.Unlock()
+}
+
+// The level can be changed at any time, it applies to all loggers derived
+// from the same root.
+func (l *Logger) SetLevel(level Level) {
+	atomic.StoreInt32(&l.core.level, int32(level))
+}
+
+func (l *Logger) Level() Level {
+	return Level(atomic.LoadInt32(&l.core.level))
+}
+
+func (l *Logger) SetFormat(format Format) {
+	atomic.StoreInt32(&l.core.format, int32(format))
+}
+
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.Level()
+}
+
+func (l *Logger) Debug(msg string, kv ...interface{}) { l.Log(LevelDebug, msg, kv...) }
+func (l *Logger) Info(msg string, kv ...interface{})  { l.Log(LevelInfo, msg, kv...) }
+func (l *Logger) Warn(msg string, kv ...interface{})  { l.Log(LevelWarn, msg, kv...) }
+func (l *Logger) Error(msg string, kv ...interface{}) { l.Log(LevelError, msg, kv...) }
+
+// Logs at fatal level and exits the process.
+func (l *Logger) Fatal(msg string, kv ...interface{}) {
+	l.Log(LevelFatal, msg, kv...)
+	os.Exit(1)
+}
+
+func (l *Logger) Log(level Level, msg string, kv ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	now := time.Now()
+	c := l.core
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buf.Reset()
+	if Format(atomic.LoadInt32(&c.format)) == FormatJson {
+		writeJson(&c.buf, now, level, msg, l.fields, kv)
+	} else {
+		writeText(&c.buf, now, level, msg, l.fields, kv)
+	}
+	c.out.Write(c.buf.Bytes())
+}
+
+func writeText(buf *bytes.Buffer, now time.Time, level Level, msg string, fields, kv []interface{}) {
+	buf.WriteString(now.Format("2006/01/02 15:04:05.000"))
+	buf.WriteByte(' ')
+	buf.WriteString(strings.ToUpper(level.String()))
+	buf.WriteByte(' ')
+	buf.WriteString(msg)
+	for _, pairs := range [][]interface{}{fields, kv} {
+		for i := 0; i < len(pairs); i += 2 {
+			buf.WriteByte(' ')
+			buf.WriteString(keyString(pairs[i]))
+			buf.WriteByte('=')
+			buf.WriteString(textValue(pairValue(pairs, i)))
+		}
+	}
+	buf.WriteByte('\n')
+}
+
+func writeJson(buf *bytes.Buffer, now time.Time, level Level, msg string, fields, kv []interface{}) {
+	buf.WriteString(`{"time":`)
+	writeJsonValue(buf, now.Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJsonValue(buf, level.String())
+	buf.WriteString(`,"msg":`)
+	writeJsonValue(buf, msg)
+	for _, pairs := range [][]interface{}{fields, kv} {
+		for i := 0; i < len(pairs); i += 2 {
+			buf.WriteByte(',')
+			writeJsonValue(buf, keyString(pairs[i]))
+			buf.WriteByte(':')
+			writeJsonValue(buf, pairValue(pairs, i))
+		}
+	}
+	buf.WriteString("}\n")
+}
+
+func writeJsonValue(buf *bytes.Buffer, v interface{}) {
+	switch value := v.(type) {
+	case error:
+		v = value.Error()
+	case fmt.Stringer:
+		v = value.String()
+	}
+	js, err := json.Marshal(v)
+	if err != nil {
+		js, _ = json.Marshal(fmt.Sprintf("%+v", v))
+	}
+	buf.Write(js)
+}
+
+func keyString(k interface{}) string {
+	if s, ok := k.(string); ok {
+		return s
+	}
+	return fmt.Sprint(k)
+}
+
+func pairValue(pairs []interface{}, i int) interface{} {
+	if i+1 < len(pairs) {
+		return pairs[i+1]
+	}
+	return "(MISSING)"
+}
+
+func textValue(v interface{}) string {
+	var s string
+	switch value := v.(type) {
+	case string:
+		s = value
+	case []byte:
+		s = string(value)
+	case error:
+		s = value.Error()
+	default:
+		s = fmt.Sprintf("%+v", v)
+	}
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// Returns a writer that logs every line written to it at the given level,
+// used to route the standard library logger through l.
+func (l *Logger) Writer(level Level) io.Writer {
+	return &lineWriter{logger: l, level: level}
+}
+
+type lineWriter struct {
+	logger *Logger
+	level  Level
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		w.logger.Log(w.level, line)
+	}
+	return len(p), nil
+}
+
+var std = New(os.Stderr)
+
+// Returns the process wide logger.
+func Default() *Logger { return std }
+
+func With(kv ...interface{}) *Logger { return std.With(kv...) }
+
+func SetOutput(out io.Writer)      { std.SetOutput(out) }
+func SetLevel(level Level)         { std.SetLevel(level) }
+func GetLevel() Level              { return std.Level() }
+func SetFormat(format Format)      { std.SetFormat(format) }
+func Writer(level Level) io.Writer { return std.Writer(level) }
+
+func Debug(msg string, kv ...interface{}) { std.Log(LevelDebug, msg, kv...) }
+func Info(msg string, kv ...interface{})  { std.Log(LevelInfo, msg, kv...) }
+func Warn(msg string, kv ...interface{})  { std.Log(LevelWarn, msg, kv...) }
+func Error(msg string, kv ...interface{}) { std.Log(LevelError, msg, kv...) }
+func Fatal(msg string, kv ...interface{}) { std.Fatal(msg, kv...) }
@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoggerText(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(&buf).With("plugin", "in1")
+	log.Debug("hidden")
+	log.Info("started", "address", "127.0.0.1:80", "err", "a b")
+	out := buf.String()
+	if strings.Contains(out, "hidden") {
+		t.Fatalf("debug entry written at info level: %s", out)
+	}
+	if !strings.Contains(out, `INFO started plugin=in1 address=127.0.0.1:80 err="a b"`) {
+		t.Fatalf("got %s", out)
+	}
+}
+
+func TestLoggerJson(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(&buf)
+	log.SetFormat(FormatJson)
+	log.SetLevel(LevelDebug)
+	log.With("tag", "t3").Debug("pack", "size", 12)
+	entry := make(map[string]interface{})
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("got %v", err)
+	}
+	if entry["level"] != "debug" || entry["tag"] != "t3" || entry["size"] != float64(12) {
+		t.Fatalf("got %v", entry)
+	}
+}
+
+func TestRotateWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kamanlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "error.log")
+	w, err := NewRotateWriter(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"} {
+		w.Write([]byte(line))
+	}
+	w.Close()
+	for file, want := range map[string]string{path: "dddddddd\n", path + ".1": "cccccccc\n", path + ".2": "bbbbbbbb\n"} {
+		got, _ := ioutil.ReadFile(file)
+		if string(got) != want {
+			t.Fatalf("%s: got %q, want %q", file, got, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Fatalf("more than 2 backups kept")
+	}
+}
+
+func TestRotateWriterFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kamanlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "error.log")
+	// A directory in the way of the backup fails the rename.
+	if err = os.MkdirAll(filepath.Join(path+".1", "x"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewRotateWriter(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("aaaaaaaa\n"))
+	if n, err := w.Write([]byte("bbbbbbbb\n")); n != 9 || err == nil {
+		t.Fatalf("got %d %v, want the bytes written and the rotation error", n, err)
+	}
+	if _, err = w.Write([]byte("cccccccc\n")); err == nil {
+		t.Fatalf("rotation retried without error")
+	}
+	w.Close()
+	if got, _ := ioutil.ReadFile(path); string(got) != "aaaaaaaa\nbbbbbbbb\ncccccccc\n" {
+		t.Fatalf("got %q", got)
+	}
+}
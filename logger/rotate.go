@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotateWriter appends to a file and rotates it once it grows past MaxSize
+// bytes. Rotated files are renamed to path.1, path.2, ... and only the last
+// MaxBackups of them are kept.
+type RotateWriter struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+	file       *os.File
+	size       int64
+	mu         sync.Mutex
+}
+
+func NewRotateWriter(path string, maxSize int64, maxBackups int) (*RotateWriter, error) {
+	w := &RotateWriter{
+		Path:       path,
+		MaxSize:    maxSize,
+		MaxBackups: maxBackups,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *RotateWriter) open() (err error) {
+	w.file, err = os.OpenFile(w.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return
+	}
+	fi, err := w.file.Stat()
+	if err != nil {
+		w.file.Close()
+		return
+	}
+	w.size = fi.Size()
+	return nil
+}
+
+// A failed rotation keeps writing to the current file and returns the
+// error along with the written bytes.
+func (w *RotateWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var rerr error
+	if w.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.MaxSize {
+		rerr = w.rotate()
+	}
+	n, err = w.file.Write(p)
+	w.size += int64(n)
+	if err == nil {
+		err = rerr
+	}
+	return
+}
+
+// Rotates with the file still open, it's only replaced once the new one is
+// open.
+func (w *RotateWriter) rotate() error {
+	if w.MaxBackups == 0 {
+		if err := w.file.Truncate(0); err != nil {
+			return err
+		}
+		w.size = 0
+		return nil
+	}
+	os.Remove(fmt.Sprintf("%s.%d", w.Path, w.MaxBackups))
+	for i := w.MaxBackups - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", w.Path, i), fmt.Sprintf("%s.%d", w.Path, i+1))
+	}
+	if err := os.Rename(w.Path, w.Path+".1"); err != nil {
+		return err
+	}
+	file, size := w.file, w.size
+	if err := w.open(); err != nil {
+		// Keep appending to the renamed file rather than losing the log.
+		w.file, w.size = file, size
+		return err
+	}
+	return file.Close()
+}
+
+func (w *RotateWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
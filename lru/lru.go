@@ -0,0 +1,70 @@
+// Package lru is a least recently used cache of lookups, like those of IPs
+// and User-Agents, that is safe for concurrent use.
+package lru
+
+import (
+	"container/list"
+	"sync"
+)
+
+type Cache struct {
+	mutex sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+type item struct {
+	key   string
+	value interface{}
+}
+
+// Returns a cache of size entries, a size of 0 or less caches nothing.
+func New(size int) *Cache {
+	return &Cache{size: size, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (this *Cache) Get(key string) (interface{}, bool) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	e, ok := this.items[key]
+	if !ok {
+		return nil, false
+	}
+	this.order.MoveToFront(e)
+	return e.Value.(*item).value, true
+}
+
+// Adds the value, evicting the least recently used one when the cache is
+// full.
+func (this *Cache) Put(key string, value interface{}) {
+	if this.size <= 0 {
+		return
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if e, ok := this.items[key]; ok {
+		e.Value.(*item).value = value
+		this.order.MoveToFront(e)
+		return
+	}
+	this.items[key] = this.order.PushFront(&item{key, value})
+	if this.order.Len() > this.size {
+		oldest := this.order.Back()
+		this.order.Remove(oldest)
+		delete(this.items, oldest.Value.(*item).key)
+	}
+}
+
+func (this *Cache) Clear() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.order.Init()
+	this.items = make(map[string]*list.Element)
+}
+
+func (this *Cache) Len() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.order.Len()
+}
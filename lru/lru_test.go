@@ -0,0 +1,31 @@
+package lru
+
+import "testing"
+
+func TestCache(t *testing.T) {
+	c := New(2)
+	c.Put("a", 1)
+	c.Put("b", 2)
+	// a becomes the most recently used, so b is evicted.
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("a: got %v %v", v, ok)
+	}
+	c.Put("c", 3)
+	if _, ok := c.Get("b"); ok {
+		t.Error("b not evicted")
+	}
+	c.Put("a", 4)
+	if v, _ := c.Get("a"); v != 4 || c.Len() != 2 {
+		t.Errorf("a: got %v with %d entries", v, c.Len())
+	}
+	c.Clear()
+	if _, ok := c.Get("c"); ok || c.Len() != 0 {
+		t.Error("not cleared")
+	}
+
+	c = New(0)
+	c.Put("a", 1)
+	if _, ok := c.Get("a"); ok {
+		t.Error("cached without a size")
+	}
+}
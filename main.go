@@ -6,15 +6,16 @@ import (
 	"io"
 	"log"
 	"os"
+	"regexp"
 	"runtime/debug"
+	"time"
   	"net/http"
-	_ "net/http/pprof"
 	"github.com/VividCortex/godaemon"
+	"github.com/millken/kaman/logger"
 	"github.com/millken/kaman/plugins"
 	"github.com/millken/kaman/report"
 )
 
-var logs *log.Logger
 var VERSION string = "0.4.4"
 var gitVersion string
 var buildDate string
@@ -34,11 +35,24 @@ func main() {
 		}
 	}()
 	c := flag.String("c", "kaman.conf", "config filepath")
-	p := flag.String("p", "", "pprof serves")
+	p := flag.String("p", "", "port pprof is served on at 127.0.0.1, report_pprof serves it on the report server instead")
 	d := flag.Bool("d", false, "as daemon")
-	reportaddr := flag.String("reportaddr", "", "http report addr")
+	reportaddr := flag.String("reportaddr", "", "http report addr, overrides report_addr of [master]")
+	adminToken := flag.String("admin-token", "", "token required by the admin API of the report server, which is disabled without one, overrides admin_token of [master]")
 	v := flag.String("v", "error.log", "log file path")
+	logLevel := flag.String("log-level", "info", "log level: debug, info, warn or error")
+	logFormat := flag.String("log-format", "text", "log format: text or json")
+	logMaxSize := flag.Int64("log-max-size", 100, "log file is rotated once it exceeds this many megabytes, 0 disables rotation")
+	logMaxBackups := flag.Int("log-max-backups", 5, "number of rotated log files kept")
 	showVersion := flag.Bool("version", false, "Prints version")
+	showPlugins := flag.Bool("list-plugins", false, "Prints the registered plugins")
+	describe := flag.String("describe", "", "Prints the config options of a plugin")
+	asJson := flag.Bool("json", false, "-list-plugins and -describe print JSON")
+	testCodec := flag.Bool("test-codec", false, "Runs stdin through -decoder and -encoder of the config")
+	decoderName := flag.String("decoder", "", "decoder name for -test-codec")
+	encoderName := flag.String("encoder", "", "encoder name for -test-codec")
+	expect := flag.String("expect", "", "golden file -test-codec output is compared with")
+	update := flag.Bool("update", false, "-test-codec writes its output to the -expect file")
 	flag.Parse()
 
 	if *showVersion {
@@ -47,44 +61,118 @@ func main() {
 		return
 	}
 
-	f, err := os.OpenFile(*v, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if *showPlugins {
+		if err := listPlugins(*asJson); err != nil {
+			logger.Fatal("list plugins failed", "err", err)
+		}
+		return
+	}
+
+	if *testCodec {
+		ok, err := runCodecCheck(&codecCheckConfig{
+			ConfigPath: *c,
+			Decoder:    *decoderName,
+			Encoder:    *encoderName,
+			Expect:     *expect,
+			Update:     *update,
+		})
+		if err != nil {
+			logger.Fatal("test codec failed", "err", err)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *describe != "" {
+		if err := describePlugin(*describe, *asJson); err != nil {
+			logger.Fatal("describe failed", "err", err)
+		}
+		return
+	}
+
+	level, err := logger.ParseLevel(*logLevel)
+	if err != nil {
+		logger.Fatal("invalid -log-level", "err", err)
+	}
+	format, err := logger.ParseFormat(*logFormat)
 	if err != nil {
-		log.Fatalln("os.Open failed, err:", err)
+		logger.Fatal("invalid -log-format", "err", err)
+	}
+	f, err := logger.NewRotateWriter(*v, *logMaxSize*1024*1024, *logMaxBackups)
+	if err != nil {
+		logger.Fatal("os.Open failed", "err", err)
 	}
 	defer f.Close()
 
-	w := io.MultiWriter(f, os.Stdout)
-	logs = log.New(w, "", log.Ldate|log.Ltime|log.Lshortfile)
-	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
-	log.SetOutput(w)
+	logger.SetOutput(io.MultiWriter(f, os.Stdout))
+	logger.SetLevel(level)
+	logger.SetFormat(format)
+	// Whatever still uses the standard logger ends up in the same place.
+	log.SetFlags(0)
+	log.SetOutput(logger.Writer(logger.LevelInfo))
 
 	if *p != "" {
 		go func() {
-			log.Println(http.ListenAndServe("0.0.0.0:"+*p, nil))
+			logger.Error("pprof server stopped", "err", http.ListenAndServe("127.0.0.1:"+*p, report.NewPprof()))
 		}()
 	}
 
+	masterConf, plugConf, err := LoadConfig(*c)
+	if err != nil {
+		logger.Fatal("read config failed", "err", err)
+	}
+
 	if *reportaddr != "" {
+		masterConf.ReportAddr = *reportaddr
+	}
+	if *adminToken != "" {
+		masterConf.AdminToken = *adminToken
+	}
+	if masterConf.ReportAddr != "" {
+		report.Version = VERSION
+		reporter := report.NewServer(masterConf.ReportAddr)
+		reporter.AdminToken = masterConf.AdminToken
+		reporter.TLSCert = masterConf.ReportTLSCert
+		reporter.TLSKey = masterConf.ReportTLSKey
+		reporter.TLSClientCA = masterConf.ReportTLSClientCA
+		reporter.AuthUser = masterConf.ReportUser
+		reporter.AuthPassword = masterConf.ReportPassword
+		reporter.AuthToken = masterConf.ReportToken
+		reporter.Pprof = masterConf.ReportPprof
+		reporter.HistoryResolution = time.Duration(masterConf.HistoryResolution) * time.Second
+		reporter.HistorySize = masterConf.HistorySize
 		go func() {
-			reporter := report.NewServer(*reportaddr)
 			if err := reporter.Run(); err != nil {
-				log.Fatalln("report run err:", err)
+				logger.Fatal("report run failed", "err", err)
 			}
 		}()
 	}
-
-	masterConf, plugConf, err := LoadConfig(*c)
-	if err != nil {
-		log.Fatalln("read config failed, err:", err)
+	logger.Debug("config loaded", "master", masterConf, "plugins", plugConf)
+	if masterConf.TraceSample > 0 || masterConf.TraceField != "" {
+		filter := &plugins.TraceFilter{
+			Sample: masterConf.TraceSample,
+			Field:  masterConf.TraceField,
+			Size:   masterConf.TraceSize,
+		}
+		if masterConf.TraceValue != "" {
+			if filter.Value, err = regexp.Compile(masterConf.TraceValue); err != nil {
+				logger.Fatal("invalid trace_value", "err", err)
+			}
+		}
+		if err = plugins.SetTraceFilter(filter); err != nil {
+			logger.Fatal("invalid trace config", "err", err)
+		}
 	}
-	log.Printf("masterConfig: %v\npulgConf : %v", masterConf, plugConf)
+	plugins.ShareDir = masterConf.ShareDir
 	pipeline := plugins.NewPipeLine()
 	if err := pipeline.LoadConfig(plugConf); err != nil {
-		log.Fatalln("load config failed, err:", err)
+		logger.Fatal("load config failed", "err", err)
 	}
 	plugMasterConf := plugins.DefaultMasterConfig()
 	if *d {
-		log.Println("as daemon run")
+		logger.Info("as daemon run")
 		godaemon.Daemonize()
 	} 
 	pipeline.Run(plugMasterConf)
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"sync/atomic"
+)
+
+// A Gauge holds an instantaneous value, e.g. the number of free packs in a
+// pool.
+type Gauge struct {
+	value int64
+}
+
+func (g *Gauge) Set(val int64) {
+	atomic.StoreInt64(&g.value, val)
+}
+
+func (g *Gauge) Add(val int64) {
+	atomic.AddInt64(&g.value, val)
+}
+
+func (g *Gauge) Value() int64 {
+	return atomic.LoadInt64(&g.value)
+}
+
+// A GaugeFunc reports the value returned by a function each time it is read,
+// e.g. the length of a channel.
+type GaugeFunc struct {
+	f func() int64
+}
+
+func NewGaugeFunc(f func() int64) *GaugeFunc {
+	return &GaugeFunc{f: f}
+}
+
+func (g *GaugeFunc) Value() int64 {
+	return g.f()
+}
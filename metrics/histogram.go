@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+const reservoirSize = 1028
+
+// A Histogram tracks the distribution of values, e.g. batch sizes. Count, min,
+// max and mean are exact, the standard deviation and percentiles come from a
+// uniform reservoir sample of the values.
+type Histogram struct {
+	count  int64
+	sum    int64
+	min    int64
+	max    int64
+	sample []int64
+	rand   *rand.Rand
+	mutex  sync.Mutex
+}
+
+type HistogramSnapshot struct {
+	Count  int64
+	Min    int64
+	Max    int64
+	Mean   float64
+	StdDev float64
+	Pct50  float64
+	Pct75  float64
+	Pct90  float64
+	Pct95  float64
+	Pct99  float64
+	Pct999 float64
+}
+
+func NewHistogram() *Histogram {
+	return &Histogram{
+		sample: make([]int64, 0, reservoirSize),
+		rand:   rand.New(rand.NewSource(rand.Int63())),
+	}
+}
+
+func (h *Histogram) Update(val int64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.count++
+	h.sum += val
+	if h.count == 1 || val < h.min {
+		h.min = val
+	}
+	if h.count == 1 || val > h.max {
+		h.max = val
+	}
+	if len(h.sample) < reservoirSize {
+		h.sample = append(h.sample, val)
+	} else if r := h.rand.Int63n(h.count); r < reservoirSize {
+		h.sample[r] = val
+	}
+}
+
+func (h *Histogram) Count() int64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.count
+}
+
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.mutex.Lock()
+	snap := HistogramSnapshot{
+		Count: h.count,
+		Min:   h.min,
+		Max:   h.max,
+	}
+	sample := make([]int64, len(h.sample))
+	copy(sample, h.sample)
+	sum := h.sum
+	h.mutex.Unlock()
+
+	if snap.Count == 0 {
+		return snap
+	}
+	snap.Mean = float64(sum) / float64(snap.Count)
+	snap.StdDev = stdDev(sample)
+	sort.Sort(int64Slice(sample))
+	snap.Pct50 = percentile(sample, 0.5)
+	snap.Pct75 = percentile(sample, 0.75)
+	snap.Pct90 = percentile(sample, 0.9)
+	snap.Pct95 = percentile(sample, 0.95)
+	snap.Pct99 = percentile(sample, 0.99)
+	snap.Pct999 = percentile(sample, 0.999)
+	return snap
+}
+
+func stdDev(values []int64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += float64(v)
+	}
+	mean := sum / float64(len(values))
+	var variance float64
+	for _, v := range values {
+		d := float64(v) - mean
+		variance += d * d
+	}
+	return math.Sqrt(variance / float64(len(values)))
+}
+
+// Returns the p-th percentile of the sorted values, interpolating between the
+// closest ranks.
+func percentile(sorted []int64, p float64) float64 {
+	size := len(sorted)
+	if size == 0 {
+		return 0
+	}
+	pos := p * float64(size+1)
+	switch {
+	case pos < 1:
+		return float64(sorted[0])
+	case pos >= float64(size):
+		return float64(sorted[size-1])
+	}
+	lower := float64(sorted[int(pos)-1])
+	upper := float64(sorted[int(pos)])
+	return lower + (pos-math.Floor(pos))*(upper-lower)
+}
+
+type int64Slice []int64
+
+func (s int64Slice) Len() int           { return len(s) }
+func (s int64Slice) Less(i, j int) bool { return s[i] < s[j] }
+func (s int64Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const tickInterval = 5 * time.Second
+
+// An ewma is an exponentially weighted moving average of a per second rate,
+// updated every tickInterval.
+type ewma struct {
+	alpha     float64
+	rate      float64
+	init      bool
+	uncounted int64
+}
+
+func newEWMA(minutes float64) *ewma {
+	return &ewma{
+		alpha: 1 - math.Exp(-tickInterval.Seconds()/60/minutes),
+	}
+}
+
+func (a *ewma) tick() {
+	count := atomic.SwapInt64(&a.uncounted, 0)
+	instantRate := float64(count) / tickInterval.Seconds()
+	if a.init {
+		a.rate += a.alpha * (instantRate - a.rate)
+	} else {
+		a.init = true
+		a.rate = instantRate
+	}
+}
+
+// A Meter counts events and their 1, 5 and 15 minute moving average rates.
+type Meter struct {
+	count   int64
+	rates   [3]*ewma
+	started time.Time
+	mutex   sync.RWMutex
+}
+
+type MeterSnapshot struct {
+	Count    int64
+	Rate1    float64
+	Rate5    float64
+	Rate15   float64
+	RateMean float64
+}
+
+func NewMeter() *Meter {
+	m := &Meter{
+		rates:   [3]*ewma{newEWMA(1), newEWMA(5), newEWMA(15)},
+		started: time.Now(),
+	}
+	meterTicker.add(m)
+	return m
+}
+
+// Stops updating the rates, meters that are no longer used must be stopped
+// to be garbage collected.
+func (m *Meter) Stop() {
+	meterTicker.remove(m)
+}
+
+// Records n events.
+func (m *Meter) Mark(n int64) {
+	atomic.AddInt64(&m.count, n)
+	for _, rate := range m.rates {
+		atomic.AddInt64(&rate.uncounted, n)
+	}
+}
+
+func (m *Meter) Count() int64 {
+	return atomic.LoadInt64(&m.count)
+}
+
+func (m *Meter) Snapshot() MeterSnapshot {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	count := m.Count()
+	return MeterSnapshot{
+		Count:    count,
+		Rate1:    m.rates[0].rate,
+		Rate5:    m.rates[1].rate,
+		Rate15:   m.rates[2].rate,
+		RateMean: float64(count) / time.Since(m.started).Seconds(),
+	}
+}
+
+func (m *Meter) tick() {
+	m.mutex.Lock()
+	for _, rate := range m.rates {
+		rate.tick()
+	}
+	m.mutex.Unlock()
+}
+
+// Ticks all meters from a single goroutine, started with the first meter.
+type meterTick struct {
+	meters map[*Meter]struct{}
+	once   sync.Once
+	mutex  sync.Mutex
+}
+
+var meterTicker = &meterTick{meters: make(map[*Meter]struct{})}
+
+func (t *meterTick) add(m *Meter) {
+	t.mutex.Lock()
+	t.meters[m] = struct{}{}
+	t.mutex.Unlock()
+	t.once.Do(func() {
+		go t.run()
+	})
+}
+
+func (t *meterTick) remove(m *Meter) {
+	t.mutex.Lock()
+	delete(t.meters, m)
+	t.mutex.Unlock()
+}
+
+func (t *meterTick) run() {
+	for range time.Tick(tickInterval) {
+		t.mutex.Lock()
+		meters := make([]*Meter, 0, len(t.meters))
+		for m := range t.meters {
+			meters = append(meters, m)
+		}
+		t.mutex.Unlock()
+		for _, m := range meters {
+			m.tick()
+		}
+	}
+}
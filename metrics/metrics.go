@@ -3,7 +3,6 @@ package metrics
 import (
 	"expvar"
 	"strconv"
-	"sync"
 	"sync/atomic"
 )
 
@@ -30,40 +29,26 @@ func (c *Counter) String() string {
 	return strconv.FormatInt(c.Value(), 10)
 }
 
-var (
-	counters = make(map[string]*Counter)
-	cm       sync.Mutex
-)
-
+// Returns the counter registered under key in the DefaultRegistry, creating
+// it on first use.
 func NewCounter(key string) *Counter {
-	cm.Lock()
-	defer cm.Unlock()
-	if c, ok := counters[key]; ok {
-		return c
-	}
-	c := new(Counter)
-	counters[key] = c
-	return c
+	return DefaultRegistry.Counter(key)
 }
 
-func Snapshot() (c map[string]int64) {
-	cm.Lock()
-	defer cm.Unlock()
-
-	c = make(map[string]int64, len(counters))
-	for n, v := range counters {
-		c[n] = v.Value()
+// Returns a snapshot of the DefaultRegistry and of every plugin registry,
+// keyed by registry name.
+func Snapshot() map[string]*RegistrySnapshot {
+	regs := Registries()
+	snap := make(map[string]*RegistrySnapshot, len(regs)+1)
+	snap[DefaultRegistry.Name] = DefaultRegistry.Snapshot()
+	for _, r := range regs {
+		snap[r.Name] = r.Snapshot()
 	}
-	return
+	return snap
 }
 
-
 func init() {
 	expvar.Publish("kaman.metrics", expvar.Func(func() interface{} {
-		counters := Snapshot()
-		return map[string]interface{}{
-			"Counters": counters,
-		}
+		return Snapshot()
 	}))
-
 }
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram()
+	for i := int64(1); i <= 100; i++ {
+		h.Update(i)
+	}
+	snap := h.Snapshot()
+	if snap.Count != 100 || snap.Min != 1 || snap.Max != 100 || snap.Mean != 50.5 {
+		t.Fatalf("got %+v", snap)
+	}
+	if snap.Pct50 != 50.5 || snap.Pct99 != 99.99 {
+		t.Fatalf("got %+v", snap)
+	}
+}
+
+func TestMeter(t *testing.T) {
+	m := NewMeter()
+	defer m.Stop()
+	m.Mark(50)
+	m.tick()
+	snap := m.Snapshot()
+	if snap.Count != 50 || snap.Rate1 != 10 || snap.Rate15 != 10 {
+		t.Fatalf("got %+v", snap)
+	}
+	m.tick()
+	if snap = m.Snapshot(); snap.Rate1 >= snap.Rate5 || snap.Rate5 >= 10 {
+		t.Fatalf("rate did not decay: %+v", snap)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := PluginRegistry("in1", "TcpInput", "t3")
+	r.Counter("messages").Add(2)
+	r.Timer("latency").Update(time.Millisecond)
+	if r != PluginRegistry("in1", "TcpInput", "t3") {
+		t.Fatalf("registry created twice")
+	}
+	snap := Snapshot()["in1"]
+	if snap.Labels["type"] != "TcpInput" || snap.Metrics["messages"] != int64(2) {
+		t.Fatalf("got %+v", snap)
+	}
+	if timer := snap.Metrics["latency"].(TimerSnapshot); timer.Count != 1 || timer.Max != int64(time.Millisecond) {
+		t.Fatalf("got %+v", timer)
+	}
+}
+
+func TestUnregister(t *testing.T) {
+	r := NewRegistry()
+	m := r.Meter("messages")
+	timer := r.Timer("latency")
+	r.Unregister("messages")
+	r.Unregister("latency")
+	r.Unregister("missing")
+	if r.Get("messages") != nil || r.Get("latency") != nil {
+		t.Fatalf("metrics still registered")
+	}
+	meterTicker.mutex.Lock()
+	_, meter := meterTicker.meters[m]
+	_, timed := meterTicker.meters[timer.meter]
+	meterTicker.mutex.Unlock()
+	if meter || timed {
+		t.Fatalf("stopped meters still ticked")
+	}
+}
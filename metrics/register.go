@@ -2,14 +2,24 @@ package metrics
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 )
 
+// A Registry holds named metrics. Every plugin instance has its own registry,
+// labelled with the plugin name, type and tag.
 type Registry struct {
+	Name    string
+	Labels  map[string]string
 	metrics map[string]interface{}
 	mutex   sync.Mutex
 }
 
+type RegistrySnapshot struct {
+	Labels  map[string]string `json:",omitempty"`
+	Metrics map[string]interface{}
+}
+
 // Create a new registry.
 func NewRegistry() *Registry {
 	return &Registry{metrics: make(map[string]interface{})}
@@ -25,6 +35,19 @@ func (r *Registry) Register(name string, i interface{}) error {
 	return nil
 }
 
+// Removes the metric, stopping meters and timers.
+func (r *Registry) Unregister(name string) {
+	r.mutex.Lock()
+	metric := r.metrics[name]
+	delete(r.metrics, name)
+	r.mutex.Unlock()
+	if stopper, ok := metric.(interface {
+		Stop()
+	}); ok {
+		stopper.Stop()
+	}
+}
+
 func (r *Registry) GetOrRegister(name string, i interface{}) interface{} {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -35,7 +58,105 @@ func (r *Registry) GetOrRegister(name string, i interface{}) interface{} {
 	return i
 }
 
-var DefaultRegistry *Registry = NewRegistry()
+// Like GetOrRegister, but only creates the metric when it is missing.
+func (r *Registry) getOrCreate(name string, create func() interface{}) interface{} {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if metric, ok := r.metrics[name]; ok {
+		return metric
+	}
+	metric := create()
+	r.metrics[name] = metric
+	return metric
+}
+
+func (r *Registry) Get(name string) interface{} {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.metrics[name]
+}
+
+// Calls f for every metric, in name order.
+func (r *Registry) Each(f func(name string, metric interface{})) {
+	r.mutex.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	metrics := make(map[string]interface{}, len(r.metrics))
+	for name, metric := range r.metrics {
+		metrics[name] = metric
+	}
+	r.mutex.Unlock()
+	sort.Strings(names)
+	for _, name := range names {
+		f(name, metrics[name])
+	}
+}
+
+// The typed getters below return the metric registered under name, creating
+// it on first use. They panic if name holds a metric of another type.
+
+func (r *Registry) Counter(name string) *Counter {
+	return r.getOrCreate(name, func() interface{} { return new(Counter) }).(*Counter)
+}
+
+func (r *Registry) Gauge(name string) *Gauge {
+	return r.getOrCreate(name, func() interface{} { return new(Gauge) }).(*Gauge)
+}
+
+func (r *Registry) GaugeFunc(name string, f func() int64) *GaugeFunc {
+	return r.getOrCreate(name, func() interface{} { return NewGaugeFunc(f) }).(*GaugeFunc)
+}
+
+func (r *Registry) Meter(name string) *Meter {
+	return r.getOrCreate(name, func() interface{} { return NewMeter() }).(*Meter)
+}
+
+func (r *Registry) Histogram(name string) *Histogram {
+	return r.getOrCreate(name, func() interface{} { return NewHistogram() }).(*Histogram)
+}
+
+func (r *Registry) Timer(name string) *Timer {
+	return r.getOrCreate(name, func() interface{} { return NewTimer() }).(*Timer)
+}
+
+// Returns the current values of all metrics.
+func (r *Registry) Snapshot() *RegistrySnapshot {
+	snap := &RegistrySnapshot{
+		Labels:  r.Labels,
+		Metrics: make(map[string]interface{}),
+	}
+	r.Each(func(name string, metric interface{}) {
+		snap.Metrics[name] = SnapshotOf(metric)
+	})
+	return snap
+}
+
+// Returns the current value of a metric: an int64 for counters and gauges,
+// a MeterSnapshot, HistogramSnapshot or TimerSnapshot otherwise.
+func SnapshotOf(metric interface{}) interface{} {
+	switch m := metric.(type) {
+	case *Counter:
+		return m.Value()
+	case *Gauge:
+		return m.Value()
+	case *GaugeFunc:
+		return m.Value()
+	case *Meter:
+		return m.Snapshot()
+	case *Histogram:
+		return m.Snapshot()
+	case *Timer:
+		return m.Snapshot()
+	}
+	return metric
+}
+
+var DefaultRegistry *Registry = &Registry{
+	Name:    "kaman",
+	metrics: make(map[string]interface{}),
+}
 
 // Gets an existing metric or creates and registers a new one. Threadsafe
 // alternative to calling Get and Register on failure.
@@ -48,3 +169,43 @@ func GetOrRegister(name string, i interface{}) interface{} {
 func Register(name string, i interface{}) error {
 	return DefaultRegistry.Register(name, i)
 }
+
+var (
+	registries = make(map[string]*Registry)
+	rm         sync.Mutex
+)
+
+// Returns the registry of the plugin instance `name`, creating it on first
+// use.
+func PluginRegistry(name, pluginType, tag string) *Registry {
+	rm.Lock()
+	defer rm.Unlock()
+	if r, ok := registries[name]; ok {
+		return r
+	}
+	r := NewRegistry()
+	r.Name = name
+	r.Labels = map[string]string{
+		"name": name,
+		"type": pluginType,
+		"tag":  tag,
+	}
+	registries[name] = r
+	return r
+}
+
+// Returns all plugin registries, in name order.
+func Registries() []*Registry {
+	rm.Lock()
+	defer rm.Unlock()
+	names := make([]string, 0, len(registries))
+	for name := range registries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	regs := make([]*Registry, len(names))
+	for i, name := range names {
+		regs[i] = registries[name]
+	}
+	return regs
+}
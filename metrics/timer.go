@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"time"
+)
+
+// A Timer is a Histogram of durations, in nanoseconds, plus a Meter of how
+// often it is updated.
+type Timer struct {
+	histogram *Histogram
+	meter     *Meter
+}
+
+type TimerSnapshot struct {
+	HistogramSnapshot
+	Rate1    float64
+	Rate5    float64
+	Rate15   float64
+	RateMean float64
+}
+
+func NewTimer() *Timer {
+	return &Timer{
+		histogram: NewHistogram(),
+		meter:     NewMeter(),
+	}
+}
+
+// Stops updating the rates, see Meter.Stop.
+func (t *Timer) Stop() {
+	t.meter.Stop()
+}
+
+func (t *Timer) Update(d time.Duration) {
+	t.histogram.Update(int64(d))
+	t.meter.Mark(1)
+}
+
+// Records the time elapsed since start.
+func (t *Timer) UpdateSince(start time.Time) {
+	t.Update(time.Since(start))
+}
+
+// Records how long f takes to run.
+func (t *Timer) Time(f func()) {
+	start := time.Now()
+	f()
+	t.UpdateSince(start)
+}
+
+func (t *Timer) Snapshot() TimerSnapshot {
+	m := t.meter.Snapshot()
+	return TimerSnapshot{
+		HistogramSnapshot: t.histogram.Snapshot(),
+		Rate1:             m.Rate1,
+		Rate5:             m.Rate5,
+		Rate15:            m.Rate15,
+		RateMean:          m.RateMean,
+	}
+}
@@ -7,6 +7,7 @@ import (
 	_ "github.com/millken/kaman/plugins/http"
 	_ "github.com/millken/kaman/plugins/kafka"
 	_ "github.com/millken/kaman/plugins/mongodb"
+	_ "github.com/millken/kaman/plugins/stats"
 	_ "github.com/millken/kaman/plugins/tcp"
 	_ "github.com/millken/kaman/plugins/udp"
 )
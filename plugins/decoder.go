@@ -1,7 +1,13 @@
 package plugins
 
 import (
-	"log"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/bbangert/toml"
+	"github.com/millken/kaman/logger"
+	"github.com/millken/kaman/metrics"
 )
 
 var decoder_plugins = make(map[string]func() interface{})
@@ -9,17 +15,77 @@ var decoders = make(map[string]Decoder)
 
 func RegisterDecoder(name string, decoder func() interface{}) {
 	if decoder == nil {
-		log.Fatalln("decoder: Register decoder is nil")
+		logger.Fatal("decoder: Register decoder is nil")
 	}
 
 	if _, ok := decoder_plugins[name]; ok {
-		log.Fatalln("decoder: Register called twice for decoder " + name)
+		logger.Fatal("decoder: Register called twice", "name", name)
 	}
-	log.Println("RegisterDecoder: ", name)
+	logger.Debug("RegisterDecoder", "name", name)
 
 	decoder_plugins[name] = decoder
 }
 
+// Reports whether a decoder with the given name has been initialized.
+func HasDecoder(name string) bool {
+	_, ok := decoders[name]
+	return ok
+}
+
+// Decoders that run other decoders by name, like ChainDecoder, implement
+// DecoderChain so InitCodecs can reject decoders running each other.
+type DecoderChain interface {
+	ChainedDecoders() []string
+}
+
+// Returns a cycle in the decoders the chains run, like [a b a], or nil.
+// chains maps decoder names to the names they run.
+func decoderCycle(chains map[string][]string) []string {
+	names := make([]string, 0, len(chains))
+	for name := range chains {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// Decoders on the current path and the ones known to be free of cycles.
+	onPath := make(map[string]bool)
+	done := make(map[string]bool)
+	var path []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		if onPath[name] {
+			for i, n := range path {
+				if n == name {
+					return append(append([]string(nil), path[i:]...), name)
+				}
+			}
+		}
+		if done[name] {
+			return nil
+		}
+		onPath[name] = true
+		path = append(path, name)
+		for _, next := range chains[name] {
+			if cycle := visit(next); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		onPath[name] = false
+		done[name] = true
+		return nil
+	}
+	for _, name := range names {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// Returned by decoders for messages that carry nothing to output, like the
+// header line of a CSV file. Outputs recycle the pack without logging it.
+var ErrDropped = errors.New("message dropped by the decoder")
+
 func PipeDecoder(name string, pack *PipelinePack) (rpack *PipelinePack, err error) {
 	if decoder, ok := decoders[name]; ok {
 
@@ -28,3 +94,53 @@ func PipeDecoder(name string, pack *PipelinePack) (rpack *PipelinePack, err erro
 	pack.Msg.MsgBytes = pack.MsgBytes
 	return pack, nil
 }
+
+// Wraps an initialized decoder to record its metrics.
+type decodeRunner struct {
+	runnerState
+	common   *PluginCommonConfig
+	decoder  Decoder
+	messages *metrics.Meter
+	errors   *metrics.Counter
+	duration *metrics.Timer
+}
+
+func newDecodeRunner(pcf *PluginCommonConfig, decoder Decoder) *decodeRunner {
+	registry := pcf.Metrics()
+	runner := &decodeRunner{
+		common:   pcf,
+		decoder:  decoder,
+		messages: registry.Meter("messages_in"),
+		errors:   registry.Counter("decode_errors"),
+		duration: registry.Timer("decode_time"),
+	}
+	runner.setState(StateRunning)
+	return runner
+}
+
+func (this *decodeRunner) Init(config toml.Primitive) error {
+	return this.decoder.Init(config)
+}
+
+func (this *decodeRunner) Decode(pack *PipelinePack) (*PipelinePack, error) {
+	start := time.Now()
+	this.messages.Mark(1)
+	rpack, err := this.decoder.Decode(pack)
+	this.duration.UpdateSince(start)
+	if err == ErrDropped {
+		pack.TraceSpan("decode", this.common.Name, start, "dropped")
+	} else if err != nil {
+		this.errors.Add(1)
+		pack.TraceSpan("decode", this.common.Name, start, err.Error())
+	} else if rpack != nil {
+		publishTap(TapDecode, this.common.Name, rpack)
+		if filter := CurrentTraceFilter(); filter != nil && filter.Field != "" && rpack.Trace() == nil {
+			// The filter field may only exist once decoded.
+			startTrace("decode", this.common.Name, rpack)
+		}
+		rpack.TraceSpan("decode", this.common.Name, start, "ok")
+	} else {
+		pack.TraceSpan("decode", this.common.Name, start, "dropped")
+	}
+	return rpack, err
+}
@@ -0,0 +1,23 @@
+package plugins
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecoderCycle(t *testing.T) {
+	for _, test := range []struct {
+		chains map[string][]string
+		want   []string
+	}{
+		{map[string][]string{"a": {"kv", "geo"}}, nil},
+		{map[string][]string{"a": {"b", "kv"}, "b": {"kv"}, "c": {"a", "b"}}, nil},
+		{map[string][]string{"a": {"a"}}, []string{"a", "a"}},
+		{map[string][]string{"a": {"b"}, "b": {"a"}}, []string{"a", "b", "a"}},
+		{map[string][]string{"a": {"kv", "b"}, "b": {"c"}, "c": {"geo", "b"}}, []string{"b", "c", "b"}},
+	} {
+		if got := decoderCycle(test.chains); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %v, want %v", test.chains, got, test.want)
+		}
+	}
+}
@@ -2,33 +2,45 @@ package elasticsearch
 
 import (
 	"fmt"
-	"log"
 
 	"github.com/bbangert/toml"
+	"github.com/millken/kaman/logger"
 	"github.com/millken/kaman/plugins"
 	mgo "gopkg.in/mgo.v2"
 )
 
 type MongodbOutputConfig struct {
-	Host, Port, Database, Collection string
-	User, Password                   string
-	Capped                           bool
-	CappedSize                       int `toml:"capped_size"`
+	Host       string `desc:"server host"`
+	Port       string `desc:"server port"`
+	Database   string `desc:"database name"`
+	Collection string `desc:"collection name"`
+	User       string `desc:"optional user name"`
+	Password   string `desc:"optional password"`
+	Capped     bool   `desc:"create a capped collection"`
+	CappedSize int    `toml:"capped_size" desc:"capped collection size in megabytes"`
 }
 
 type MongodbOutput struct {
+	// Result of the last insert, reported by Health.
+	plugins.LastError
 	config      *MongodbOutputConfig
+	log         *logger.Logger
 	FailedCount int64
 }
 
-func (self *MongodbOutput) Init(conf toml.Primitive) error {
-	log.Println("MongodbOutput Init.")
-	self.config = &MongodbOutputConfig{
+func (self *MongodbOutput) ConfigStruct() interface{} {
+	return &MongodbOutputConfig{
 		Host:       "localhost",
 		Port:       "27017",
 		Database:   "test",
 		Collection: "test",
 	}
+}
+
+func (self *MongodbOutput) Init(conf toml.Primitive) error {
+	self.log = logger.With("type", "MongodbOutput")
+	self.log.Info("MongodbOutput Init.")
+	self.config = self.ConfigStruct().(*MongodbOutputConfig)
 	if err := toml.PrimitiveDecode(conf, self.config); err != nil {
 		return fmt.Errorf("Can't unmarshal MongodbOutput config: %s", err)
 	}
@@ -43,8 +55,9 @@ func (self *MongodbOutput) Run(runner plugins.OutputRunner) error {
 	}
 	url += self.config.Host + ":" + self.config.Port + "/" + self.config.Database
 	session, err := mgo.Dial(url)
+	self.SetError(err)
 	if err != nil {
-		log.Println("mgo.Dial failed, err:", err)
+		self.log.Error("mgo.Dial failed", "err", err)
 		return err
 	}
 
@@ -64,9 +77,10 @@ func (self *MongodbOutput) Run(runner plugins.OutputRunner) error {
 		coll := session.DB(self.config.Database).C(self.config.Collection)
 		pack := <-runner.InChan()
 		err = coll.Insert(pack.Msg.Data)
+		self.SetError(err)
 		if err != nil {
 			self.FailedCount++
-			log.Println("insert failed, count=", self.FailedCount, "err:", err)
+			self.log.Error("insert failed", "count", self.FailedCount, "err", err)
 			pack.Recycle()
 			continue
 		}
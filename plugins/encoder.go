@@ -1,7 +1,11 @@
 package plugins
 
 import (
-	"log"
+	"time"
+
+	"github.com/bbangert/toml"
+	"github.com/millken/kaman/logger"
+	"github.com/millken/kaman/metrics"
 )
 
 var encoder_plugins = make(map[string]func() interface{})
@@ -9,17 +13,23 @@ var encoders = make(map[string]Encoder)
 
 func RegisterEncoder(name string, Encoder func() interface{}) {
 	if Encoder == nil {
-		log.Fatalln("encoder: Register encoder is nil")
+		logger.Fatal("encoder: Register encoder is nil")
 	}
 
 	if _, ok := encoder_plugins[name]; ok {
-		log.Fatalln("encoder: Register called twice for encoder " + name)
+		logger.Fatal("encoder: Register called twice", "name", name)
 	}
-	log.Println("RegisterEncoder: ", name)
+	logger.Debug("RegisterEncoder", "name", name)
 
 	encoder_plugins[name] = Encoder
 }
 
+// Reports whether an encoder with the given name has been initialized.
+func HasEncoder(name string) bool {
+	_, ok := encoders[name]
+	return ok
+}
+
 func PipeEncoder(name string, pack *PipelinePack) (rpack *PipelinePack, err error) {
 	if encoder, ok := encoders[name]; ok {
 
@@ -27,3 +37,47 @@ func PipeEncoder(name string, pack *PipelinePack) (rpack *PipelinePack, err erro
 	}
 	return pack, nil
 }
+
+// Wraps an initialized encoder to record its metrics.
+type encodeRunner struct {
+	runnerState
+	common   *PluginCommonConfig
+	encoder  Encoder
+	messages *metrics.Meter
+	errors   *metrics.Counter
+	duration *metrics.Timer
+}
+
+func newEncodeRunner(pcf *PluginCommonConfig, encoder Encoder) *encodeRunner {
+	registry := pcf.Metrics()
+	runner := &encodeRunner{
+		common:   pcf,
+		encoder:  encoder,
+		messages: registry.Meter("messages_in"),
+		errors:   registry.Counter("encode_errors"),
+		duration: registry.Timer("encode_time"),
+	}
+	runner.setState(StateRunning)
+	return runner
+}
+
+func (this *encodeRunner) Init(config toml.Primitive) error {
+	return this.encoder.Init(config)
+}
+
+func (this *encodeRunner) Encode(pack *PipelinePack) (*PipelinePack, error) {
+	start := time.Now()
+	this.messages.Mark(1)
+	rpack, err := this.encoder.Encode(pack)
+	this.duration.UpdateSince(start)
+	if err != nil {
+		this.errors.Add(1)
+		pack.TraceSpan("encode", this.common.Name, start, err.Error())
+	} else if rpack != nil {
+		publishTap(TapEncode, this.common.Name, rpack)
+		rpack.TraceSpan("encode", this.common.Name, start, "ok")
+	} else {
+		pack.TraceSpan("encode", this.common.Name, start, "dropped")
+	}
+	return rpack, err
+}
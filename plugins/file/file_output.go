@@ -2,7 +2,6 @@ package file
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -10,6 +9,8 @@ import (
 
 	"github.com/bbangert/toml"
 	"github.com/cactus/gostrftime"
+	"github.com/millken/kaman/logger"
+	"github.com/millken/kaman/metrics"
 	"github.com/millken/kaman/plugins"
 )
 
@@ -29,29 +30,31 @@ type FileOutputConfig struct {
 	// If date rotation is in use, then the output file name can support
 	// Go's time.Format syntax to embed timestamps in the filename:
 	// http://golang.org/pkg/time/#Time.Format
-	Path string
+	Path string `desc:"output file path, strftime syntax when rotation is enabled"`
 
 	// Output file permissions (default "644").
-	Perm string
+	Perm string `desc:"output file permissions as an octal string"`
 
 	// Interval at which the output file should be rotated, in hours.
 	// Only the following values are allowed: 0, 1, 4, 12, 24
 	// The files will be named relative to midnight of the day.
 	// (default 0, i.e. disabled). Set to 0 to disable.
-	RotationInterval uint32 `toml:"rotation_interval"`
+	RotationInterval uint32 `toml:"rotation_interval" desc:"rotation interval in hours, one of 0, 1, 4, 12, 24"`
 
 	// Interval at which accumulated file data should be written to disk, in
 	// milliseconds (default 1000, i.e. 1 second). Set to 0 to disable.
-	FlushInterval uint32 `toml:"flush_interval"`
+	FlushInterval uint32 `toml:"flush_interval" desc:"flush interval in milliseconds"`
 
 	// Permissions to apply to directories created for FileOutput's parent
 	// directory if it doesn't exist.  Must be a string representation of an
 	// octal integer. Defaults to "700".
-	FolderPerm string `toml:"folder_perm"`
+	FolderPerm string `toml:"folder_perm" desc:"permissions of created parent directories as an octal string"`
 }
 
 type FileOutput struct {
 	common     *plugins.PluginCommonConfig
+	log        *logger.Logger
+	batchSize  *metrics.Histogram
 	config     *FileOutputConfig
 	path       string
 	perm       os.FileMode
@@ -64,17 +67,23 @@ type FileOutput struct {
 	closing    chan struct{}
 }
 
-func (self *FileOutput) Init(pcf *plugins.PluginCommonConfig, conf toml.Primitive) error {
-	var err error
-	var intPerm int64
-	log.Println("FileOutput Init.")
-	self.common = pcf
-	self.config = &FileOutputConfig{
+func (self *FileOutput) ConfigStruct() interface{} {
+	return &FileOutputConfig{
 		Perm:             "644",
 		RotationInterval: 0,
 		FlushInterval:    1000,
 		FolderPerm:       "700",
 	}
+}
+
+func (self *FileOutput) Init(pcf *plugins.PluginCommonConfig, conf toml.Primitive) error {
+	var err error
+	var intPerm int64
+	self.common = pcf
+	self.log = pcf.Logger()
+	self.log.Info("FileOutput Init.")
+	self.batchSize = pcf.Metrics().Histogram("batch_size")
+	self.config = self.ConfigStruct().(*FileOutputConfig)
 	if err := toml.PrimitiveDecode(conf, self.config); err != nil {
 		return fmt.Errorf("Can't unmarshal FileOutput config: %s", err)
 	}
@@ -182,13 +191,15 @@ func (self *FileOutput) receiver(runner plugins.OutputRunner, errChan chan error
 		case pack = <-inChan:
 			pack, err = plugins.PipeDecoder(self.common.Decoder, pack)
 			if err != nil {
-				log.Printf("PipeDecoder :%s", err)
+				if err != plugins.ErrDropped {
+					self.log.Error("PipeDecoder", "err", err)
+				}
 				pack.Recycle()
 				continue
 			}
 			pack, err = plugins.PipeEncoder(self.common.Encoder, pack)
 			if err != nil {
-				log.Printf("PipeEncoder :%s", err)
+				self.log.Error("PipeEncoder", "err", err)
 				pack.Recycle()
 				continue
 			}
@@ -202,12 +213,14 @@ func (self *FileOutput) receiver(runner plugins.OutputRunner, errChan chan error
 				out.data = append(out.data, '\n')
 				msgCounter++
 			}
+			pack.TraceSpan("send", self.common.Name, time.Now(), "batched")
 			pack.Recycle()
 
 		case <-self.timerChan:
 			// This will block until the other side is ready to accept
 			// this batch, freeing us to start on the next one.
 			if msgCounter >= 0 {
+				self.batchSize.Update(int64(msgCounter))
 				self.batchChan <- out
 				out = <-self.backChan
 				msgCounter = 0
@@ -244,9 +257,9 @@ func (self *FileOutput) committer(or plugins.OutputRunner, errChan chan error) {
 
 			n, err := self.file.Write(out.data)
 			if err != nil {
-				log.Println(fmt.Errorf("Can't write to %s: %s", self.path, err))
+				self.log.Error("Can't write", "path", self.path, "err", err)
 			} else if n != len(out.data) {
-				log.Println(fmt.Errorf("data loss - truncated output for %s", self.path))
+				self.log.Error("data loss - truncated output", "path", self.path)
 			} else {
 				self.file.Sync()
 			}
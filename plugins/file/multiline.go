@@ -0,0 +1,121 @@
+package file
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Assembles lines into multiline events, like the stack trace of a Java
+// exception. An event starts with a line matching start, or continues as
+// long as the lines match continuation. Without either every line is an
+// event of its own.
+type multiline struct {
+	start        *regexp.Regexp
+	continuation *regexp.Regexp
+	maxLines     int
+	maxBytes     int
+	timeout      time.Duration
+
+	lines []string
+	// Bytes of the file the buffered lines took, newlines included.
+	pending int
+	// When the last line was added.
+	last time.Time
+}
+
+// Validates the multiline options of the tailing inputs and returns an
+// assembler with them, the inputs need one per file.
+func newMultiline(start, continuation string, maxLines, maxBytes int, timeout string) (ml *multiline, err error) {
+	ml = &multiline{maxLines: maxLines, maxBytes: maxBytes}
+	if start != "" && continuation != "" {
+		return nil, fmt.Errorf("multiline_start and multiline_continue are exclusive")
+	}
+	if start != "" {
+		if ml.start, err = regexp.Compile(start); err != nil {
+			return nil, fmt.Errorf("multiline_start: %s", err)
+		}
+	}
+	if continuation != "" {
+		if ml.continuation, err = regexp.Compile(continuation); err != nil {
+			return nil, fmt.Errorf("multiline_continue: %s", err)
+		}
+	}
+	if maxLines <= 0 || maxBytes <= 0 {
+		return nil, fmt.Errorf("multiline_max_lines and multiline_max_bytes must be positive")
+	}
+	if ml.timeout, err = time.ParseDuration(timeout); err != nil || ml.timeout <= 0 {
+		return nil, fmt.Errorf("multiline_timeout must be a positive duration, not %q", timeout)
+	}
+	return ml, nil
+}
+
+// Returns an empty assembler with the same settings.
+func (this *multiline) clone() *multiline {
+	return &multiline{
+		start:        this.start,
+		continuation: this.continuation,
+		maxLines:     this.maxLines,
+		maxBytes:     this.maxBytes,
+		timeout:      this.timeout,
+	}
+}
+
+func (this *multiline) enabled() bool {
+	return this.start != nil || this.continuation != nil
+}
+
+// Adds a line, as read without its "\n", and returns the events it
+// completed, in order. Assembled events leave out the "\r" of CRLF lines,
+// it's still counted in the bytes read.
+func (this *multiline) add(line string, now time.Time) (events []string) {
+	if !this.enabled() {
+		return []string{line}
+	}
+	read := len(line) + 1
+	line = strings.TrimSuffix(line, "\r")
+	if len(this.lines) > 0 {
+		var continues bool
+		if this.start != nil {
+			continues = !this.start.MatchString(line)
+		} else {
+			continues = this.continuation.MatchString(line)
+		}
+		if !continues || this.pending+len(line) > this.maxBytes {
+			events = append(events, this.flush())
+		}
+	}
+	this.lines = append(this.lines, line)
+	this.pending += read
+	this.last = now
+	if len(this.lines) >= this.maxLines || this.pending >= this.maxBytes {
+		events = append(events, this.flush())
+	}
+	return events
+}
+
+// Returns the buffered event and empties the buffer.
+func (this *multiline) flush() string {
+	event := strings.Join(this.lines, "\n")
+	this.lines = this.lines[:0]
+	this.pending = 0
+	return event
+}
+
+// Reports whether an event is buffered and no line was added to it for the
+// timeout, so it won't be held until the next event starts.
+func (this *multiline) expired(now time.Time) bool {
+	return len(this.lines) > 0 && now.Sub(this.last) >= this.timeout
+}
+
+// Returns the offset of the file up to which all events were emitted, given
+// the offset it was read up to.
+func (this *multiline) emittedOffset(readOffset int64) int64 {
+	offset := readOffset - int64(this.pending)
+	if offset < 0 {
+		// The file was reopened after a rotation.
+		return 0
+	}
+	return offset
+}
@@ -0,0 +1,95 @@
+package file
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func addAll(ml *multiline, lines ...string) (events []string) {
+	now := time.Now()
+	for _, line := range lines {
+		events = append(events, ml.add(line, now)...)
+	}
+	return events
+}
+
+func TestMultilineStart(t *testing.T) {
+	ml, err := newMultiline(`^\d{4}-`, "", 500, 1<<20, "2s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := addAll(ml,
+		"2026-10-19 ERROR boom",
+		"java.lang.RuntimeException: boom",
+		"\tat a.b.C.d(C.java:1)",
+		"2026-10-19 INFO next",
+	)
+	want := []string{"2026-10-19 ERROR boom\njava.lang.RuntimeException: boom\n\tat a.b.C.d(C.java:1)"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("got %q", events)
+	}
+	if ml.pending != len("2026-10-19 INFO next\n") || ml.emittedOffset(100) != 100-int64(ml.pending) {
+		t.Errorf("pending %d", ml.pending)
+	}
+	if ml.expired(time.Now()) || !ml.expired(time.Now().Add(2*time.Second)) {
+		t.Error("expired wrong")
+	}
+	if event := ml.flush(); event != "2026-10-19 INFO next" || ml.pending != 0 {
+		t.Errorf("flushed %q", event)
+	}
+	if ml.emittedOffset(100) != 100 {
+		t.Error("offset after flush")
+	}
+}
+
+func TestMultilineCRLF(t *testing.T) {
+	ml, _ := newMultiline(`^\d{4}-`, "", 500, 1<<20, "2s")
+	events := addAll(ml, "2026-10-19 ERROR boom\r", "\tat a.b.C.d(C.java:1)\r", "2026-10-19 INFO next\r")
+	if want := []string{"2026-10-19 ERROR boom\n\tat a.b.C.d(C.java:1)"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("got %q", events)
+	}
+	if ml.pending != len("2026-10-19 INFO next\r\n") {
+		t.Errorf("pending %d", ml.pending)
+	}
+}
+
+func TestMultilineContinue(t *testing.T) {
+	ml, err := newMultiline("", `^\s`, 500, 1<<20, "2s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := addAll(ml, "a", " a1", " a2", "b", "c", " c1")
+	if want := []string{"a\n a1\n a2", "b"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("got %q", events)
+	}
+}
+
+func TestMultilineLimits(t *testing.T) {
+	ml, _ := newMultiline("", `^\s`, 3, 1<<20, "2s")
+	events := addAll(ml, "a", " 1", " 2", " 3")
+	if want := []string{"a\n 1\n 2"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("max lines: got %q", events)
+	}
+
+	ml, _ = newMultiline("", `^\s`, 500, 10, "2s")
+	events = addAll(ml, "abcd", " efg", " hijklmn")
+	if want := []string{"abcd\n efg"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("max bytes: got %q", events)
+	}
+}
+
+func TestMultilineDisabled(t *testing.T) {
+	ml, err := newMultiline("", "", 500, 1<<20, "2s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events := addAll(ml, "a", " b"); !reflect.DeepEqual(events, []string{"a", " b"}) || ml.pending != 0 {
+		t.Errorf("got %q", events)
+	}
+	for _, args := range [][]string{{"a", "b", "2s"}, {"(", "", "2s"}, {"a", "", "0s"}} {
+		if _, err = newMultiline(args[0], args[1], 500, 1<<20, args[2]); err == nil {
+			t.Errorf("%q accepted", args)
+		}
+	}
+}
@@ -2,18 +2,21 @@ package file
 
 import (
 	"fmt"
-	"log"
+	"time"
 
 	"github.com/bbangert/toml"
+	"github.com/millken/kaman/logger"
 	"github.com/millken/kaman/plugins"
 )
 
 type StdoutOutput struct {
 	common *plugins.PluginCommonConfig
+	log    *logger.Logger
 }
 
 func (self *StdoutOutput) Init(pcf *plugins.PluginCommonConfig, conf toml.Primitive) error {
 	self.common = pcf
+	self.log = pcf.Logger()
 	return nil
 }
 
@@ -23,17 +26,21 @@ func (self *StdoutOutput) Run(runner plugins.OutputRunner) (err error) {
 		pack := <-runner.InChan()
 		pack, err = plugins.PipeDecoder(self.common.Decoder, pack)
 		if err != nil {
-			log.Printf("PipeDecoder :%s", err)
+			if err != plugins.ErrDropped {
+				self.log.Error("PipeDecoder", "err", err)
+			}
 			pack.Recycle()
 			continue
 		}
 		pack, err = plugins.PipeEncoder(self.common.Encoder, pack)
 		if err != nil {
-			log.Printf("PipeEncoder :%s", err)
+			self.log.Error("PipeEncoder", "err", err)
 			pack.Recycle()
 			continue
 		}
-		fmt.Printf("%s\n", pack.Msg.MsgBytes)
+		start := time.Now()
+		_, err = fmt.Printf("%s\n", pack.Msg.MsgBytes)
+		pack.TraceSend(self.common.Name, start, err)
 		pack.Recycle()
 	}
 
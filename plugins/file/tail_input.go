@@ -3,28 +3,36 @@ package file
 import (
 	"encoding/binary"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/bbangert/toml"
 	"github.com/hpcloud/tail"
+	"github.com/millken/kaman/logger"
 	"github.com/millken/kaman/plugins"
 )
 
 type TailInputConfig struct {
-	Path         string
-	PosFile      string `toml:"pos_file"`
-	SyncInterval int    `toml:"sync_interval"`
-	OffsetValue  int64
+	Path              string `desc:"file to tail"`
+	PosFile           string `toml:"pos_file" desc:"file the read offset is saved to"`
+	SyncInterval      int    `toml:"sync_interval" desc:"offset save interval in seconds"`
+	OffsetValue       int64  `toml:"-"`
+	MultilineStart    string `toml:"multiline_start" desc:"regexp matching the first line of an event, the lines up to the next match are appended to it"`
+	MultilineContinue string `toml:"multiline_continue" desc:"regexp matching the lines appended to the previous one"`
+	MultilineMaxLines int    `toml:"multiline_max_lines" desc:"lines after which an event is emitted"`
+	MultilineMaxBytes int    `toml:"multiline_max_bytes" desc:"bytes after which an event is emitted"`
+	MultilineTimeout  string `toml:"multiline_timeout" desc:"emit an event when no line was appended to it for this long"`
 }
 
 type TailInput struct {
 	config             *TailInputConfig
 	common             *plugins.PluginCommonConfig
+	log                *logger.Logger
 	checkpointFile     *os.File
 	checkpointFilename string
+	multiline          *multiline
+	stopChan           chan bool
 }
 
 func (this *TailInput) writeCheckpoint(offset int64) (err error) {
@@ -39,16 +47,29 @@ func (this *TailInput) writeCheckpoint(offset int64) (err error) {
 	return
 }
 
+func (this *TailInput) ConfigStruct() interface{} {
+	return &TailInputConfig{
+		Path:              "/var/log/messages",
+		PosFile:           "/tmp/tail.pos",
+		SyncInterval:      2,
+		MultilineMaxLines: 500,
+		MultilineMaxBytes: 1 << 20,
+		MultilineTimeout:  "2s",
+	}
+}
+
 func (this *TailInput) Init(pcf *plugins.PluginCommonConfig, conf toml.Primitive) (err error) {
 	this.common = pcf
-	this.config = &TailInputConfig{
-		Path:         "/var/log/messages",
-		PosFile:      "/tmp/tail.pos",
-		SyncInterval: 2,
-	}
+	this.log = pcf.Logger()
+	this.config = this.ConfigStruct().(*TailInputConfig)
 	if err := toml.PrimitiveDecode(conf, this.config); err != nil {
 		return fmt.Errorf("Can't unmarshal tail config: %s", err)
 	}
+	if this.multiline, err = newMultiline(this.config.MultilineStart, this.config.MultilineContinue,
+		this.config.MultilineMaxLines, this.config.MultilineMaxBytes, this.config.MultilineTimeout); err != nil {
+		return fmt.Errorf("TailInput: %s", err)
+	}
+	this.stopChan = make(chan bool)
 	this.checkpointFilename = this.config.PosFile
 	if fileExists(this.checkpointFilename) {
 		if this.config.OffsetValue, err = readCheckpoint(this.checkpointFilename); err != nil {
@@ -67,7 +88,7 @@ func (this *TailInput) Run(runner plugins.InputRunner) (err error) {
 	defer func() {
 
 		if err := recover(); err != nil {
-			log.Fatalln("recover panic at err:", err)
+			this.log.Fatal("recover panic", "err", err)
 		}
 		if this.checkpointFile != nil {
 			this.checkpointFile.Close()
@@ -90,7 +111,23 @@ func (this *TailInput) Run(runner plugins.InputRunner) (err error) {
 		return err
 	}
 	tick := time.NewTicker(time.Second * time.Duration(this.config.SyncInterval))
+	defer tick.Stop()
 	count := 0
+	ml := this.multiline
+	var flush <-chan time.Time
+	if ml.enabled() {
+		flushTick := time.NewTicker(ml.timeout / 2)
+		defer flushTick.Stop()
+		flush = flushTick.C
+	}
+	emit := func(text string) {
+		pack := <-runner.InChan()
+		pack.MsgBytes = []byte(text)
+		pack.Msg.Tag = this.common.Tag
+		pack.Msg.SetMeta(plugins.MetaFile, this.config.Path)
+		pack.Msg.Timestamp = time.Now().Unix()
+		runner.RouterChan() <- pack
+	}
 
 	for {
 		select {
@@ -99,30 +136,61 @@ func (this *TailInput) Run(runner plugins.InputRunner) (err error) {
 				if count > 0 {
 					offset, err := t.Tell()
 					if err != nil {
-						log.Println("Tell return error: ", err)
+						this.log.Error("Tell return error", "err", err)
 						continue
 					}
-					if err = this.writeCheckpoint(offset); err != nil {
+					// Lines of a buffered event are read again after a restart.
+					if err = this.writeCheckpoint(ml.emittedOffset(offset)); err != nil {
 						return err
 					}
 
 					count = 0
 				}
 			}
+		case now := <-flush:
+			if ml.expired(now) {
+				emit(ml.flush())
+				count++
+			}
 		case line := <-t.Lines:
 			{
-				pack := <-runner.InChan()
-				pack.MsgBytes = []byte(line.Text)
-				pack.Msg.Tag = this.common.Tag
-				pack.Msg.Timestamp = time.Now().Unix()
+				for _, event := range ml.add(line.Text, time.Now()) {
+					emit(event)
+				}
 				count++
-				runner.RouterChan() <- pack
 			}
+		case <-this.stopChan:
+			offset, err := stopTail(t, ml, emit)
+			if err != nil {
+				this.log.Error("Tell return error", "err", err)
+				return nil
+			}
+			return this.writeCheckpoint(offset)
 		}
 	}
-	err = t.Wait()
-	return err
+}
+
+// Stops tailing the file: the input emits the lines it read, the buffered event
+// included, and saves the offset it reached.
+func (this *TailInput) Stop() {
+	close(this.stopChan)
+}
 
+// Kills the tailer and emits the lines it had read, then the buffered event.
+// The returned offset is taken before the kill: the lines read after it are
+// emitted now and read again after a restart, none is lost.
+func stopTail(t *tail.Tail, ml *multiline, emit func(string)) (int64, error) {
+	offset, err := t.Tell()
+	t.Kill(nil)
+	for line := range t.Lines {
+		for _, event := range ml.add(line.Text, time.Now()) {
+			emit(event)
+		}
+	}
+	if len(ml.lines) > 0 {
+		emit(ml.flush())
+	}
+	return offset, err
 }
 
 func readCheckpoint(filename string) (offset int64, err error) {
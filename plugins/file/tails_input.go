@@ -6,34 +6,45 @@ import (
 	"errors"
 	"fmt"
 	"hash/fnv"
-	"log"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sync"
 	"time"
 
 	"github.com/bbangert/toml"
 	"github.com/hpcloud/tail"
+	"github.com/millken/kaman/logger"
 	"github.com/millken/kaman/plugins"
 )
 
 type TailsInputConfig struct {
 	// Log base directory to run log regex under
-	LogDirectory string `toml:"log_directory"`
+	LogDirectory string `toml:"log_directory" desc:"base directory scanned for log files"`
 	// Journal base directory for saving journal files
-	JournalDirectory string `toml:"journal_directory"`
+	JournalDirectory string `toml:"journal_directory" desc:"directory the per file read offsets are saved to"`
 	// File match for regular expression
-	FileMatch      string `toml:"file_match"`
-	SyncInterval   int    `toml:"sync_interval"`
-	RescanInterval string `toml:"rescan_interval"`
+	FileMatch         string `toml:"file_match" desc:"regular expression matched against paths below log_directory, required"`
+	SyncInterval      int    `toml:"sync_interval" desc:"offset save interval in seconds"`
+	RescanInterval    string `toml:"rescan_interval" desc:"interval between directory scans for new files"`
+	MultilineStart    string `toml:"multiline_start" desc:"regexp matching the first line of an event, the lines up to the next match are appended to it"`
+	MultilineContinue string `toml:"multiline_continue" desc:"regexp matching the lines appended to the previous one"`
+	MultilineMaxLines int    `toml:"multiline_max_lines" desc:"lines after which an event is emitted"`
+	MultilineMaxBytes int    `toml:"multiline_max_bytes" desc:"bytes after which an event is emitted"`
+	MultilineTimeout  string `toml:"multiline_timeout" desc:"emit an event when no line was appended to it for this long"`
 }
 
 type TailsInput struct {
 	config         *TailsInputConfig
 	common         *plugins.PluginCommonConfig
+	log            *logger.Logger
 	rescanInterval time.Duration
 	files          []string
 	runner         plugins.InputRunner
+	// Cloned for every file.
+	multiline *multiline
+	stopChan  chan bool
+	tailers   sync.WaitGroup
 }
 
 // Represents an individual Logfile which is part of a Logstream
@@ -128,19 +139,32 @@ func (this *TailsInput) ExistInQueue(file string) bool {
 	}
 	return false
 }
-func (this *TailsInput) Init(pcf *plugins.PluginCommonConfig, conf toml.Primitive) (err error) {
-	this.common = pcf
-	this.config = &TailsInputConfig{
+func (this *TailsInput) ConfigStruct() interface{} {
+	return &TailsInputConfig{
 		LogDirectory:     "/var/log",
 		JournalDirectory: "/tmp/",
 		//FileMatch: "*.log",
-		RescanInterval: "1m",
-		SyncInterval:   2,
+		RescanInterval:    "1m",
+		SyncInterval:      2,
+		MultilineMaxLines: 500,
+		MultilineMaxBytes: 1 << 20,
+		MultilineTimeout:  "2s",
 	}
+}
+
+func (this *TailsInput) Init(pcf *plugins.PluginCommonConfig, conf toml.Primitive) (err error) {
+	this.common = pcf
+	this.log = pcf.Logger()
+	this.config = this.ConfigStruct().(*TailsInputConfig)
 	this.files = make([]string, 0)
+	this.stopChan = make(chan bool)
 	if err := toml.PrimitiveDecode(conf, this.config); err != nil {
 		return fmt.Errorf("Can't unmarshal tails config: %s", err)
 	}
+	if this.multiline, err = newMultiline(this.config.MultilineStart, this.config.MultilineContinue,
+		this.config.MultilineMaxLines, this.config.MultilineMaxBytes, this.config.MultilineTimeout); err != nil {
+		return fmt.Errorf("TailsInput: %s", err)
+	}
 	if this.config.FileMatch == "" {
 		return errors.New("`file_match` setting is required.")
 	}
@@ -168,8 +192,14 @@ func (this *TailsInput) Watcher() {
 			continue
 		}
 		this.files = append(this.files, logfile.FileName)
-		log.Printf("%s", logfile.FileName)
-		go this.Tailer(logfile.FileName)
+		this.log.Info("tailing file", "file", logfile.FileName)
+		this.tailers.Add(1)
+		go func(f string) {
+			defer this.tailers.Done()
+			if err := this.Tailer(f); err != nil {
+				this.log.Error("Tailer", "file", f, "err", err)
+			}
+		}(logfile.FileName)
 	}
 
 }
@@ -218,7 +248,23 @@ func (this *TailsInput) Tailer(f string) error {
 	}
 
 	tick := time.NewTicker(time.Second * time.Duration(3))
+	defer tick.Stop()
 	count := 0
+	ml := this.multiline.clone()
+	var flush <-chan time.Time
+	if ml.enabled() {
+		flushTick := time.NewTicker(ml.timeout / 2)
+		defer flushTick.Stop()
+		flush = flushTick.C
+	}
+	emit := func(text string) {
+		pack := <-this.runner.InChan()
+		pack.MsgBytes = []byte(text)
+		pack.Msg.Tag = this.common.Tag
+		pack.Msg.SetMeta(plugins.MetaFile, f)
+		pack.Msg.Timestamp = time.Now().Unix()
+		this.runner.RouterChan() <- pack
+	}
 
 	for {
 		select {
@@ -227,50 +273,66 @@ func (this *TailsInput) Tailer(f string) error {
 				if count > 0 {
 					offset, err := t.Tell()
 					if err != nil {
-						log.Println("Tell return error: ", err)
+						this.log.Error("Tell return error", "file", f, "err", err)
 						continue
 					}
-					if err = writePoint(pointfile, offset); err != nil {
+					// Lines of a buffered event are read again after a restart.
+					if err = writePoint(pointfile, ml.emittedOffset(offset)); err != nil {
 						return err
 					}
 					count = 0
 				}
 			}
+		case now := <-flush:
+			if ml.expired(now) {
+				emit(ml.flush())
+				count++
+			}
 		case line := <-t.Lines:
 			{
-				pack := <-this.runner.InChan()
-				pack.MsgBytes = []byte(line.Text)
-				pack.Msg.Tag = this.common.Tag
-				pack.Msg.Timestamp = time.Now().Unix()
+				for _, event := range ml.add(line.Text, time.Now()) {
+					emit(event)
+				}
 				count++
-				this.runner.RouterChan() <- pack
 			}
+		case <-this.stopChan:
+			offset, err := stopTail(t, ml, emit)
+			if err != nil {
+				return err
+			}
+			return writePoint(pointfile, offset)
 		}
 	}
-	err = t.Wait()
-	return err
 }
 
 func (this *TailsInput) Run(runner plugins.InputRunner) (err error) {
 	defer func() {
 
 		if err := recover(); err != nil {
-			log.Fatalln("recover panic at err:", err)
+			this.log.Fatal("recover panic", "err", err)
 		}
 	}()
 	this.runner = runner
 	this.Watcher()
-	ok := true
-	rescan := time.Tick(this.rescanInterval)
-	for ok {
+	rescan := time.NewTicker(this.rescanInterval)
+	defer rescan.Stop()
+	for {
 		select {
-		case <-rescan:
+		case <-rescan.C:
 			{
 				this.Watcher()
 			}
+		case <-this.stopChan:
+			this.tailers.Wait()
+			return nil
 		}
 	}
-	return nil
+}
+
+// Stops tailing the files: every tailer emits the lines it read, the buffered
+// event included, and saves the offset it reached.
+func (this *TailsInput) Stop() {
+	close(this.stopChan)
 }
 
 func init() {
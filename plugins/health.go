@@ -0,0 +1,136 @@
+package plugins
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Plugins that can tell whether they work, e.g. whether their connection is
+// up, implement HealthChecker. Health is called by the report server and
+// must return quickly.
+type HealthChecker interface {
+	Health() error
+}
+
+// LastError keeps the result of the last operation of a plugin. Embedded, it
+// implements HealthChecker.
+type LastError struct {
+	mutex sync.Mutex
+	err   error
+}
+
+func (this *LastError) SetError(err error) {
+	this.mutex.Lock()
+	this.err = err
+	this.mutex.Unlock()
+}
+
+func (this *LastError) Health() error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.err
+}
+
+// How long the router loop may not run before the pipeline is unhealthy.
+const routerStallTimeout = 5 * time.Second
+
+type HealthCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+	// Why a check that would fail passes, e.g. a paused output.
+	Note string `json:"note,omitempty"`
+}
+
+type HealthReport struct {
+	OK     bool           `json:"ok"`
+	Checks []*HealthCheck `json:"checks"`
+}
+
+func (this *HealthReport) add(name string, err error) {
+	check := &HealthCheck{Name: name, OK: err == nil}
+	if err != nil {
+		check.Error = err.Error()
+		this.OK = false
+	}
+	this.Checks = append(this.Checks, check)
+}
+
+func newHealthReport() *HealthReport {
+	return &HealthReport{OK: true, Checks: []*HealthCheck{}}
+}
+
+func pluginHealth(plugin interface{}) error {
+	if checker, ok := plugin.(HealthChecker); ok {
+		return checker.Health()
+	}
+	return nil
+}
+
+func queueHealth(n, capacity int, threshold float64) error {
+	if capacity > 0 && float64(n) >= float64(capacity)*threshold {
+		return fmt.Errorf("queue %d/%d over %.0f%%", n, capacity, threshold*100)
+	}
+	return nil
+}
+
+// Liveness: the router loop keeps running. The loop waiting for an output
+// paused with block is reported but still live.
+func (this *Pipeline) Health() *HealthReport {
+	report := newHealthReport()
+	stalled := this.router.SinceHeartbeat()
+	if stalled <= routerStallTimeout {
+		report.add("router", nil)
+		return report
+	}
+	if runner := this.router.waitingFor(); runner != nil && runner.blocking() {
+		report.Checks = append(report.Checks, &HealthCheck{Name: "router", OK: true,
+			Note: fmt.Sprintf("waiting %s for output %s, paused with backpressure", stalled, runner.Name())})
+		return report
+	}
+	report.add("router", fmt.Errorf("router loop stalled for %s", stalled))
+	return report
+}
+
+// Readiness: every plugin is running and healthy, and no queue is filled
+// beyond threshold, a fraction of its capacity. kaman has no disk queue, the
+// queue check is on the in-memory channels of the router and the outputs.
+func (this *Pipeline) Ready(threshold float64) *HealthReport {
+	report := this.Health()
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+
+	report.add("router queue", queueHealth(len(this.router.inChan), cap(this.router.inChan), threshold))
+	for _, runner := range this.inputs {
+		runner.mutex.Lock()
+		input := runner.input
+		runner.mutex.Unlock()
+		err := pluginHealth(input)
+		if state := runner.State(); state != StateRunning {
+			err = fmt.Errorf("input is %s", state)
+		}
+		report.add("input "+runner.Name(), err)
+	}
+	for _, runner := range this.outputs {
+		runner.mutex.Lock()
+		output := runner.output
+		runner.mutex.Unlock()
+		err := pluginHealth(output)
+		// Paused outputs were paused on purpose and still count as ready.
+		if state := runner.State(); state != StateRunning && state != StatePaused {
+			err = fmt.Errorf("output is %s", state)
+		}
+		if err == nil {
+			err = queueHealth(len(runner.routerChan), cap(runner.routerChan), threshold)
+		}
+		report.add("output "+runner.Name(), err)
+	}
+	for _, runner := range this.decoders {
+		report.add("decoder "+runner.common.Name, pluginHealth(runner.decoder))
+	}
+	for _, runner := range this.encoders {
+		report.add("encoder "+runner.common.Name, pluginHealth(runner.encoder))
+	}
+	return report
+}
@@ -3,18 +3,19 @@ package http
 import (
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net"
 	"net/http"
 	"time"
 
 	"github.com/bbangert/toml"
+	"github.com/millken/kaman/logger"
 	"github.com/millken/kaman/plugins"
 )
 
 type HttpListenInput struct {
 	config      *HttpListenInputConfig
 	common      *plugins.PluginCommonConfig
+	log         *logger.Logger
 	listener    net.Listener
 	ir          plugins.InputRunner
 	stopChan    chan bool
@@ -26,9 +27,9 @@ type HttpListenInput struct {
 type HttpListenInputConfig struct {
 	// TCP Address to listen to for incoming requests.
 	// Defaults to "127.0.0.1:8325".
-	Address        string
-	Headers        http.Header
-	RequestHeaders []string `toml:"request_headers"`
+	Address        string      `desc:"listen address"`
+	Headers        http.Header `desc:"headers added to every response"`
+	RequestHeaders []string    `toml:"request_headers" desc:"unused"`
 }
 
 func defaultStarter(hli *HttpListenInput) (err error) {
@@ -37,8 +38,7 @@ func defaultStarter(hli *HttpListenInput) (err error) {
 		return fmt.Errorf("Listener [%s] start fail: %s",
 			hli.config.Address, err.Error())
 	} else {
-		log.Printf(fmt.Sprintf("Listening on %s",
-			hli.config.Address))
+		hli.log.Info("Listening", "address", hli.config.Address)
 	}
 
 	err = hli.server.Serve(hli.listener)
@@ -52,7 +52,7 @@ func defaultStarter(hli *HttpListenInput) (err error) {
 func (hli *HttpListenInput) RequestHandler(w http.ResponseWriter, req *http.Request) {
 	body, err := ioutil.ReadAll(req.Body)
 	if err != nil {
-		log.Printf("req.Body ReadAll failed, err : %s", err)
+		hli.log.Error("req.Body ReadAll failed", "err", err)
 	}
 	pack := <-hli.ir.InChan()
 	pack.MsgBytes = body
@@ -64,14 +64,19 @@ func (hli *HttpListenInput) RequestHandler(w http.ResponseWriter, req *http.Requ
 
 }
 
-func (hli *HttpListenInput) Init(pcf *plugins.PluginCommonConfig, conf toml.Primitive) (err error) {
-	log.Println("HttpListenInput Init.")
-	hli.common = pcf
-	hli.config = &HttpListenInputConfig{
+func (hli *HttpListenInput) ConfigStruct() interface{} {
+	return &HttpListenInputConfig{
 		Address:        "127.0.0.1:8325",
 		Headers:        make(http.Header),
 		RequestHeaders: []string{},
 	}
+}
+
+func (hli *HttpListenInput) Init(pcf *plugins.PluginCommonConfig, conf toml.Primitive) (err error) {
+	hli.common = pcf
+	hli.log = pcf.Logger()
+	hli.log.Info("HttpListenInput Init.")
+	hli.config = hli.ConfigStruct().(*HttpListenInputConfig)
 
 	if err := toml.PrimitiveDecode(conf, hli.config); err != nil {
 		return fmt.Errorf("Can't unmarshal HttpListenInput config: %s", err)
@@ -91,7 +96,7 @@ func (hli *HttpListenInput) Init(pcf *plugins.PluginCommonConfig, conf toml.Prim
 func (hli *HttpListenInput) Run(runner plugins.InputRunner) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Fatalln("recover panic at err:", err)
+			hli.log.Fatal("recover panic", "err", err)
 		}
 	}()
 	hli.ir = runner
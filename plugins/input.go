@@ -1,20 +1,20 @@
 package plugins
 
 import (
-	"log"
+	"github.com/millken/kaman/logger"
 )
 
 var input_plugins = make(map[string]func() interface{})
 
 func RegisterInput(name string, input func() interface{}) {
 	if input == nil {
-		log.Fatalln("input: Register input is nil")
+		logger.Fatal("input: Register input is nil")
 	}
 
 	if _, ok := input_plugins[name]; ok {
-		log.Fatalln("input: Register called twice for input " + name)
+		logger.Fatal("input: Register called twice", "name", name)
 	}
-	log.Println("RegisterPlugin: ", name)
+	logger.Debug("RegisterPlugin", "name", name)
 
 	input_plugins[name] = input
 }
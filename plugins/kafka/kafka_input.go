@@ -4,60 +4,53 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"log"
 	"os"
 	"time"
 
 	"github.com/bbangert/toml"
-	"github.com/millken/kaman/metrics"
+	"github.com/millken/kaman/logger"
 	"github.com/millken/kaman/plugins"
 	"github.com/optiopay/kafka"
+	"github.com/optiopay/kafka/proto"
 )
 
 type KafkaInputConfig struct {
-	ClientId      string `toml:"client_id"`
-	Addrs         []string
-	Partition     int32
-	Topic         string
-	Partitions    int32
-	FlushInterval uint32 `toml:"flush_interval"`
+	ClientId      string   `toml:"client_id" desc:"client id sent to the brokers, defaults to the hostname"`
+	Addrs         []string `desc:"broker addresses, required"`
+	Partition     int32    `desc:"partition to consume"`
+	Topic         string   `desc:"topic to consume, required"`
+	Partitions    int32    `desc:"unused"`
+	FlushInterval uint32   `toml:"flush_interval" desc:"unused"`
 }
 
 type KafkaInput struct {
+	// Result of the last consume, reported by Health.
+	plugins.LastError
 	common   *plugins.PluginCommonConfig
+	log      *logger.Logger
 	config   *KafkaInputConfig
 	broker   *kafka.Broker
 	consumer kafka.Consumer
+	stopChan chan bool
 }
 
-type stdLogger struct {
-}
-
-func (stdLogger) Debug(msg string, args ...interface{}) {
-	log.Printf(msg, args...)
-}
-func (stdLogger) Info(msg string, args ...interface{}) {
-	log.Printf(msg, args...)
-}
-func (stdLogger) Warn(msg string, args ...interface{}) {
-	log.Printf(msg, args...)
-}
-func (stdLogger) Error(msg string, args ...interface{}) {
-	log.Printf(msg, args...)
-}
-
-func (self *KafkaInput) Init(pcf *plugins.PluginCommonConfig, conf toml.Primitive) (err error) {
-	log.Println("KafkaInput Init.")
-	self.common = pcf
+func (self *KafkaInput) ConfigStruct() interface{} {
 	hn, err := os.Hostname()
 	if err != nil {
 		hn = "kamanclient"
 	}
-	self.config = &KafkaInputConfig{
+	return &KafkaInputConfig{
 		ClientId:      hn,
 		Partitions:    0,
 		FlushInterval: 1000,
 	}
+}
+
+func (self *KafkaInput) Init(pcf *plugins.PluginCommonConfig, conf toml.Primitive) (err error) {
+	self.common = pcf
+	self.log = pcf.Logger()
+	self.log.Info("KafkaInput Init.")
+	self.config = self.ConfigStruct().(*KafkaInputConfig)
 	if err = toml.PrimitiveDecode(conf, self.config); err != nil {
 		return fmt.Errorf("Can't unmarshal KafkaInput config: %s", err)
 	}
@@ -70,7 +63,8 @@ func (self *KafkaInput) Init(pcf *plugins.PluginCommonConfig, conf toml.Primitiv
 
 	bcf := kafka.NewBrokerConf(self.config.ClientId)
 	bcf.AllowTopicCreation = false
-	//bcf.Logger = &stdLogger{}
+	// *logger.Logger implements kafka.Logger.
+	bcf.Logger = self.log.With("component", "kafka")
 
 	self.broker, err = kafka.Dial(self.config.Addrs, bcf)
 	if err != nil {
@@ -79,32 +73,72 @@ func (self *KafkaInput) Init(pcf *plugins.PluginCommonConfig, conf toml.Primitiv
 
 	defer self.broker.Close()
 	consumerconf := kafka.NewConsumerConf(self.config.Topic, self.config.Partition)
+	// Consume returns ErrNoData after about a second without messages, so Run
+	// sees Stop.
+	consumerconf.RetryLimit = 10
 	self.consumer, err = self.broker.Consumer(consumerconf)
 	if err != nil {
 		return fmt.Errorf("cannot create kafka consumer for %s:%d: %s", self.config.Topic, self.config.Partition, err)
 	}
+	self.stopChan = make(chan bool)
 	return err
 }
 
 func (self *KafkaInput) Run(runner plugins.InputRunner) (err error) {
-	counter := fmt.Sprintf("Tag:%s,Type:%s", self.common.Tag, self.common.Type)
-	mc := metrics.NewCounter(counter)
 
 	for {
+		select {
+		case <-self.stopChan:
+			return nil
+		default:
+		}
 		msg, err := self.consumer.Consume()
-		if err != nil && err != kafka.ErrNoData {
-			log.Printf("Consume :%s", err)
-			break
+		if err == kafka.ErrNoData {
+			continue
+		}
+		if err != nil {
+			self.log.Error("Consume", "err", err)
+			self.SetError(err)
+			if permanent(err) {
+				return err
+			}
+			// Keep consuming so the input recovers with the brokers, the
+			// error stays on Health until a message comes through.
+			select {
+			case <-self.stopChan:
+				return nil
+			case <-time.After(time.Second):
+			}
+			continue
 		}
+		self.SetError(nil)
 		pack := <-runner.InChan()
 		pack.MsgBytes = bytes.TrimSpace(msg.Value)
 		pack.Msg.Tag = self.common.Tag
 		pack.Msg.Timestamp = time.Now().Unix()
-		mc.Add(1)
 		runner.RouterChan() <- pack
+	}
+}
 
+// Reports whether retrying won't fix the error, like an offset out of range or
+// an unknown topic. The consumer already retried connection and leader errors
+// for a few seconds, they may clear once the brokers are back.
+func permanent(err error) bool {
+	if _, ok := err.(*proto.KafkaError); !ok {
+		return false
+	}
+	switch err {
+	case proto.ErrLeaderNotAvailable, proto.ErrNotLeaderForPartition, proto.ErrBrokerNotAvailable,
+		proto.ErrReplicaNotAvailable, proto.ErrRequestTimeout:
+		return false
 	}
-	return nil
+	return true
+}
+
+// Stops consuming. Run returns once the pending Consume does, within about a
+// second while no messages come, after its retries while the brokers fail.
+func (self *KafkaInput) Stop() {
+	close(self.stopChan)
 }
 
 func init() {
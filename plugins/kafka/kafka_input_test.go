@@ -0,0 +1,30 @@
+package kafka
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/optiopay/kafka/proto"
+)
+
+func TestPermanent(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{proto.ErrOffsetOutOfRange, true},
+		{proto.ErrUnknownTopicOrPartition, true},
+		{proto.ErrNotLeaderForPartition, false},
+		{proto.ErrBrokerNotAvailable, false},
+		{io.EOF, false},
+		{&net.OpError{Op: "dial", Err: errors.New("refused")}, false},
+		{errors.New("incomplete fetch response"), false},
+	}
+	for _, test := range tests {
+		if got := permanent(test.err); got != test.want {
+			t.Errorf("permanent(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
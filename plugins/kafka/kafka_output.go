@@ -3,11 +3,12 @@ package kafka
 import (
 	"errors"
 	"fmt"
-	"log"
 	"os"
 	"time"
 
 	"github.com/bbangert/toml"
+	"github.com/millken/kaman/logger"
+	"github.com/millken/kaman/metrics"
 	"github.com/millken/kaman/plugins"
 	"github.com/optiopay/kafka"
 	"github.com/optiopay/kafka/proto"
@@ -24,17 +25,21 @@ func newOutBatch() *outBatch {
 }
 
 type KafkaOutputConfig struct {
-	ClientId      string `toml:"client_id"`
-	Addrs         []string
-	Partition     int32
-	Topic         string
-	Partitions    int32
-	Distributer   string
-	FlushInterval uint32 `toml:"flush_interval"`
+	ClientId      string   `toml:"client_id" desc:"client id sent to the brokers, defaults to the hostname"`
+	Addrs         []string `desc:"broker addresses, required"`
+	Partition     int32    `desc:"partition written to when distributer is None"`
+	Topic         string   `desc:"topic to produce to, required"`
+	Partitions    int32    `desc:"number of partitions to distribute over, 0 uses the topic partition count"`
+	Distributer   string   `desc:"partition distributer, one of None, Random, RoundRobin, Hash"`
+	FlushInterval uint32   `toml:"flush_interval" desc:"batch flush interval in milliseconds"`
 }
 
 type KafkaOutput struct {
+	// Result of the last produce, reported by Health.
+	plugins.LastError
 	common               *plugins.PluginCommonConfig
+	log                  *logger.Logger
+	batchSize            *metrics.Histogram
 	config               *KafkaOutputConfig
 	broker               *kafka.Broker
 	producer             kafka.Producer
@@ -43,19 +48,25 @@ type KafkaOutput struct {
 	backChan             chan *outBatch
 }
 
-func (self *KafkaOutput) Init(pcf *plugins.PluginCommonConfig, conf toml.Primitive) (err error) {
-	log.Println("KafkaOutput Init.")
-	self.common = pcf
+func (self *KafkaOutput) ConfigStruct() interface{} {
 	hn, err := os.Hostname()
 	if err != nil {
 		hn = "kamanclient"
 	}
-	self.config = &KafkaOutputConfig{
+	return &KafkaOutputConfig{
 		ClientId:      hn,
 		Distributer:   "None",
 		Partitions:    0,
 		FlushInterval: 1000,
 	}
+}
+
+func (self *KafkaOutput) Init(pcf *plugins.PluginCommonConfig, conf toml.Primitive) (err error) {
+	self.common = pcf
+	self.log = pcf.Logger()
+	self.log.Info("KafkaOutput Init.")
+	self.batchSize = pcf.Metrics().Histogram("batch_size")
+	self.config = self.ConfigStruct().(*KafkaOutputConfig)
 	if err = toml.PrimitiveDecode(conf, self.config); err != nil {
 		return fmt.Errorf("Can't unmarshal KafkaOutput config: %s", err)
 	}
@@ -68,6 +79,7 @@ func (self *KafkaOutput) Init(pcf *plugins.PluginCommonConfig, conf toml.Primiti
 
 	bcf := kafka.NewBrokerConf(self.config.ClientId)
 	//bcf.AllowTopicCreation = true
+	bcf.Logger = self.log.With("component", "kafka")
 
 	// connect to kafka cluster
 	self.broker, err = kafka.Dial(self.config.Addrs, bcf)
@@ -83,7 +95,7 @@ func (self *KafkaOutput) Init(pcf *plugins.PluginCommonConfig, conf toml.Primiti
 	if err != nil {
 		return fmt.Errorf("cannot count to topic partitions: %s", err)
 	}
-	log.Printf("topic\"%s\" has %d partitions\n", self.config.Topic, partitions)
+	self.log.Info("topic partitions", "topic", self.config.Topic, "partitions", partitions)
 	if (self.config.Partition + 1) > partitions {
 		return fmt.Errorf("invalid partition: %d, topic have %d partitions",
 			self.config.Partition, partitions)
@@ -133,20 +145,24 @@ func (self *KafkaOutput) Run(runner plugins.OutputRunner) (err error) {
 			case pack = <-runner.InChan():
 				pack, err = plugins.PipeDecoder(self.common.Decoder, pack)
 				if err != nil {
-					log.Printf("PipeDecoder :%s", err)
+					if err != plugins.ErrDropped {
+						self.log.Error("PipeDecoder", "err", err)
+					}
 					pack.Recycle()
 					continue
 				}
 				pack, err = plugins.PipeEncoder(self.common.Encoder, pack)
 				if err != nil {
-					log.Printf("PipeEncoder :%s", err)
+					self.log.Error("PipeEncoder", "err", err)
 					pack.Recycle()
 					continue
 				}
 				message = &proto.Message{Value: pack.Msg.MsgBytes}
 				out.data = append(out.data, message)
+				pack.TraceSpan("send", self.common.Name, time.Now(), "batched")
 				pack.Recycle()
 			case <-timer.C:
+				self.batchSize.Update(int64(len(out.data)))
 				self.batchChan <- out
 				out = <-self.backChan
 				timer.Reset(timerDuration)
@@ -154,11 +170,12 @@ func (self *KafkaOutput) Run(runner plugins.OutputRunner) (err error) {
 				if err != nil {
 					bcf := kafka.NewBrokerConf(self.config.ClientId)
 					//bcf.AllowTopicCreation = true
+					bcf.Logger = self.log.With("component", "kafka")
 
 					// connect to kafka cluster
 					self.broker, err = kafka.Dial(self.config.Addrs, bcf)
 					if err != nil {
-						log.Printf("cannot reconnect to kafka cluster: %s", err)
+						self.log.Error("cannot reconnect to kafka cluster", "err", err)
 					}
 				}
 			}
@@ -167,9 +184,12 @@ func (self *KafkaOutput) Run(runner plugins.OutputRunner) (err error) {
 		for {
 			pack = <-runner.InChan()
 			message = &proto.Message{Value: pack.Msg.MsgBytes}
+			start := time.Now()
 			if _, err = self.producer.Produce(self.config.Topic, self.config.Partition, message); err != nil {
-				log.Printf("cannot produce message to %s:%d: %s", self.config.Topic, self.config.Partition, err)
+				self.log.Error("cannot produce message", "topic", self.config.Topic, "partition", self.config.Partition, "err", err)
 			}
+			self.SetError(err)
+			pack.TraceSend(self.common.Name, start, err)
 			pack.Recycle()
 		}
 	}
@@ -186,13 +206,14 @@ func (self *KafkaOutput) committer(or plugins.OutputRunner, errChan chan error)
 		select {
 		case out, ok = <-self.batchChan:
 			if !ok {
-				log.Printf("batchChan are not ready")
+				self.log.Warn("batchChan are not ready")
 				continue
 			}
 			//log.Printf("out=%#v", out)
 			if _, err = self.distributingProducer.Distribute(self.config.Topic, out.data...); err != nil {
-				log.Printf("cannot produce message to %s: %s", self.config.Topic, err)
+				self.log.Error("cannot produce message", "topic", self.config.Topic, "err", err)
 			}
+			self.SetError(err)
 
 			out.data = out.data[:0]
 			self.backChan <- out
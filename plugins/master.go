@@ -2,10 +2,23 @@ package plugins
 
 import (
 	"os"
+	"path/filepath"
 	"sync"
 	"syscall"
 )
 
+// Directory of the files plugins read, like pattern files, set by main from
+// share_dir before the plugins are initialized.
+var ShareDir = "/usr/share/kaman"
+
+// Returns the path in ShareDir, absolute paths are returned as they are.
+func PrependShareDir(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(ShareDir, path)
+}
+
 type MasterConfig struct {
 	PoolSize       int
 	PluginChanSize int
@@ -1,20 +1,20 @@
 package plugins
 
 import (
-	"log"
+	"github.com/millken/kaman/logger"
 )
 
 var output_plugins = make(map[string]func() interface{})
 
 func RegisterOutput(name string, out func() interface{}) {
 	if out == nil {
-		log.Fatalln("output: Register output is nil")
+		logger.Fatal("output: Register output is nil")
 	}
 
 	if _, ok := output_plugins[name]; ok {
-		log.Fatalln("output: Register called twice for output " + name)
+		logger.Fatal("output: Register called twice", "name", name)
 	}
-	log.Println("RegisterPlugin: ", name)
+	logger.Debug("RegisterPlugin", "name", name)
 
 	output_plugins[name] = out
 }
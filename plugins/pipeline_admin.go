@@ -0,0 +1,80 @@
+package plugins
+
+import (
+	"fmt"
+	"time"
+)
+
+// Runtime control of the pipeline, used by the admin API of the report
+// server.
+
+func (this *Pipeline) input(name string) (*iRunner, error) {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+	for _, runner := range this.inputs {
+		if runner.Name() == name {
+			return runner, nil
+		}
+	}
+	return nil, fmt.Errorf("no input %s", name)
+}
+
+func (this *Pipeline) output(name string) (*oRunner, error) {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+	for _, runner := range this.outputs {
+		if runner.Name() == name {
+			return runner, nil
+		}
+	}
+	return nil, fmt.Errorf("no output %s", name)
+}
+
+// Stops handing messages to the output, see oRunner.Pause for block.
+func (this *Pipeline) PauseOutput(name string, block bool) error {
+	runner, err := this.output(name)
+	if err != nil {
+		return err
+	}
+	return runner.Pause(block)
+}
+
+func (this *Pipeline) ResumeOutput(name string) error {
+	runner, err := this.output(name)
+	if err != nil {
+		return err
+	}
+	return runner.Resume()
+}
+
+// Stops the input and waits until the messages it sent have been processed.
+// Fails with ErrNotStoppable for inputs that don't implement Stopper.
+func (this *Pipeline) DrainInput(name string, timeout time.Duration) error {
+	runner, err := this.input(name)
+	if err != nil {
+		return err
+	}
+	return runner.Drain(timeout)
+}
+
+// Routes the messages whose tag matches the regex to the output, on top of
+// the routes it already has.
+func (this *Pipeline) AddRoute(tag, output string) error {
+	runner, err := this.output(output)
+	if err != nil {
+		return err
+	}
+	return this.router.AddOutChan(tag, runner)
+}
+
+func (this *Pipeline) RemoveRoute(tag, output string) error {
+	runner, err := this.output(output)
+	if err != nil {
+		return err
+	}
+	return this.router.RemoveOutChan(tag, runner)
+}
+
+func (this *Pipeline) Routes() []Route {
+	return this.router.Routes()
+}
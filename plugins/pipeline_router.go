@@ -1,54 +1,200 @@
 package plugins
 
 import (
-	"log"
+	"fmt"
 	"regexp"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
+
+	"github.com/millken/kaman/logger"
+	"github.com/millken/kaman/metrics"
 )
 
+// A tag regex and the output the matching packs are sent to.
+type route struct {
+	re     *regexp.Regexp
+	runner *oRunner
+}
+
+type Route struct {
+	Tag    string `json:"tag"`
+	Output string `json:"output"`
+}
+
 type Router struct {
-	inChan  chan *PipelinePack
-	outChan map[*regexp.Regexp]chan *PipelinePack
+	// Unix nanoseconds of the last loop iteration, the loop runs at least
+	// once a second while it isn't stuck. First for 64-bit alignment.
+	heartbeat int64
+	inChan    chan *PipelinePack
+	// The []*route the loop reads, replaced as a whole when routes change
+	// so the loop never waits on a lock.
+	outChan atomic.Value
+	// The *oRunner paused with backpressure the loop waits for, nil when
+	// it doesn't wait.
+	waiting  atomic.Value
+	mutex    sync.Mutex
+	messages *metrics.Meter
 }
 
 func (self *Router) Init() {
-	self.outChan = make(map[*regexp.Regexp]chan *PipelinePack)
+	self.outChan.Store([]*route{})
+	self.waiting.Store((*oRunner)(nil))
+	self.messages = metrics.DefaultRegistry.Meter("router.messages")
 }
 
-func (self *Router) AddOutChan(matchtag string, outChan chan *PipelinePack) error {
+func (self *Router) routes() []*route {
+	return self.outChan.Load().([]*route)
+}
+
+func (self *Router) AddOutChan(matchtag string, runner *oRunner) error {
 
 	re, err := regexp.Compile(matchtag)
 	if err != nil {
 		return err
 	}
 
-	self.outChan[re] = outChan
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	for _, r := range self.routes() {
+		if r.runner == runner && r.re.String() == matchtag {
+			return fmt.Errorf("route %s to %s exists", matchtag, runner.Name())
+		}
+	}
+	routes := append(append([]*route{}, self.routes()...), &route{re, runner})
+	self.outChan.Store(routes)
+	return nil
+}
+
+func (self *Router) RemoveOutChan(matchtag string, runner *oRunner) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	routes := make([]*route, 0, len(self.routes()))
+	for _, r := range self.routes() {
+		if r.runner != runner || r.re.String() != matchtag {
+			routes = append(routes, r)
+		}
+	}
+	if len(routes) == len(self.routes()) {
+		return fmt.Errorf("no route %s to %s", matchtag, runner.Name())
+	}
+	self.outChan.Store(routes)
 	return nil
 }
 
+// Returns the routes in the order they were added.
+func (self *Router) Routes() []Route {
+	routes := self.routes()
+	list := make([]Route, len(routes))
+	for i, r := range routes {
+		list[i] = Route{Tag: r.re.String(), Output: r.runner.Name()}
+	}
+	return list
+}
+
 func (self *Router) AddInChan(inChan chan *PipelinePack) {
 	self.inChan = inChan
+	metrics.DefaultRegistry.GaugeFunc("router.chan_len", func() int64 { return int64(len(inChan)) })
+	metrics.DefaultRegistry.Gauge("router.chan_cap").Set(int64(cap(inChan)))
+}
+
+// The output paused with backpressure the loop waits for, or nil.
+func (self *Router) waitingFor() *oRunner {
+	runner, _ := self.waiting.Load().(*oRunner)
+	return runner
+}
+
+// Time since the loop last ran, zero until it starts: the codecs are
+// initialized first and may take a while to load their databases.
+func (self *Router) SinceHeartbeat() time.Duration {
+	last := atomic.LoadInt64(&self.heartbeat)
+	if last == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(0, last))
 }
 
 func (self *Router) Loop() {
-	for pack := range self.inChan {
-
-		for re, outChan := range self.outChan {
-			flag := re.MatchString(pack.Msg.Tag)
-			if flag == true {
-				atomic.AddInt32(&pack.RefCount, 1)
-				select {
-				case outChan <- pack:
-				default:
-					{
-						log.Println("outChan fulled, tag=", pack.Msg.Tag)
-						<-outChan
-						outChan <- pack
+	// Outputs the current pack was sent to, an output with several matching
+	// routes gets it once.
+	sent := make([]*oRunner, 0, 8)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		atomic.StoreInt64(&self.heartbeat, time.Now().UnixNano())
+		select {
+		case pack, ok := <-self.inChan:
+			if !ok {
+				return
+			}
+			sent = self.route(pack, sent[:0])
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sends the pack to the matching outputs.
+func (self *Router) route(pack *PipelinePack, sent []*oRunner) []*oRunner {
+	self.messages.Mark(1)
+	// Read before the first send, decoders of the outputs may change it.
+	tag := pack.Msg.Tag
+
+routes:
+	for _, r := range self.routes() {
+		runner := r.runner
+		outChan := runner.routerChan
+		flag := r.re.MatchString(tag)
+		if flag == true {
+			for _, s := range sent {
+				if s == runner {
+					continue routes
+				}
+			}
+			sent = append(sent, runner)
+			atomic.AddInt32(&pack.RefCount, 1)
+			// The spans are added after the sends, the router still holds
+			// the pack so its trace can't be finished yet.
+			start := time.Now()
+			select {
+			case outChan <- pack:
+				pack.TraceSpan("route", runner.Name(), start, "queued")
+			default:
+				if runner.blocking() {
+					// Paused with backpressure: wait for the output.
+					self.waiting.Store(runner)
+					outChan <- pack
+					self.waiting.Store((*oRunner)(nil))
+					pack.TraceSpan("route", runner.Name(), start, "queued, output paused")
+					continue
+				}
+				{
+					logger.Warn("outChan fulled", "plugin", runner.Name(), "tag", tag)
+					select {
+					case dropped := <-outChan:
+						runner.metrics.drops.Add(1)
+						dropped.TraceSpan("drop", runner.Name(), time.Now(), "output queue full")
+						dropped.Recycle()
+					default:
 					}
+					outChan <- pack
+					pack.TraceSpan("route", runner.Name(), start, "queued, dropped the oldest")
 				}
 			}
 		}
+	}
 
-		pack.Recycle()
+	if pack.Trace() != nil {
+		result := "no matching route"
+		if len(sent) > 0 {
+			names := make([]string, len(sent))
+			for i, runner := range sent {
+				names[i] = runner.Name()
+			}
+			result = "routed to " + strings.Join(names, ",")
+		}
+		pack.TraceSpan("router", "", pack.RecvTime, result)
 	}
+	pack.Recycle()
+	return sent
 }
@@ -2,16 +2,19 @@ package plugins
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"os/signal"
 	"regexp"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/bbangert/toml"
 	notify "github.com/bitly/go-notify"
+	"github.com/millken/kaman/logger"
+	"github.com/millken/kaman/metrics"
 )
 
 type PluginConfig map[string]toml.Primitive
@@ -31,14 +34,32 @@ type Message struct {
 	Tag       string
 	Timestamp int64
 	Data      map[string]interface{}
+	// Set by inputs for the decoders, like MetaFile, nil when empty.
+	Meta map[string]string
 	sync.RWMutex
 }
 
+// Meta key of the file a message was read from.
+const MetaFile = "file"
+
+func (this *Message) SetMeta(key, value string) {
+	if this.Meta == nil {
+		this.Meta = make(map[string]string)
+	}
+	this.Meta[key] = value
+}
+
 type PipelinePack struct {
 	MsgBytes    []byte
 	Msg         Message
 	RecycleChan chan *PipelinePack
 	RefCount    int32
+	// When the input runner passed the pack to the router.
+	RecvTime time.Time
+	// The *Trace while the pack is traced. The decoders of the outputs
+	// sharing the pack may start its trace, so it is read and set
+	// atomically.
+	trace atomic.Value
 }
 
 func NewPipelinePack(recycleChan chan *PipelinePack) (pack *PipelinePack) {
@@ -60,23 +81,51 @@ func (this *PipelinePack) Zero() {
 	this.MsgBytes = this.MsgBytes[:cap(this.MsgBytes)]
 	this.Msg.Data = make(map[string]interface{})
 	this.Msg.MsgBytes = this.MsgBytes
+	this.Msg.Meta = nil
 	this.RefCount = 1
+	this.trace.Store((*Trace)(nil))
 }
 
 func (this *PipelinePack) Recycle() {
 	cnt := atomic.AddInt32(&this.RefCount, -1)
 	if cnt == 0 {
+		if trace := this.Trace(); trace != nil {
+			finishTrace(trace)
+		}
 		this.Zero()
 		this.RecycleChan <- this
 	}
 }
 
+// A named plugin section of the config file.
+type PluginSection struct {
+	Name   string
+	Config toml.Primitive
+}
+
+// Decodes the common options of the section.
+func (this *PluginSection) commonConfig() *PluginCommonConfig {
+	plugCommon := &PluginCommonConfig{}
+	if err := toml.PrimitiveDecode(this.Config, plugCommon); err != nil {
+		logger.Fatal("toml struct error", "plugin", this.Name, "err", err)
+	}
+	plugCommon.Name = this.Name
+	return plugCommon
+}
+
 type Pipeline struct {
-	InputRunners  []interface{}
-	OutputRunners []interface{}
-	DecodeRunners []interface{}
-	EncodeRunners []interface{}
+	InputRunners  []*PluginSection
+	OutputRunners []*PluginSection
+	DecodeRunners []*PluginSection
+	EncodeRunners []*PluginSection
 	router        Router
+
+	// The runners, guarded by mutex as the report server reads them.
+	mutex    sync.RWMutex
+	inputs   []*iRunner
+	outputs  []*oRunner
+	decoders []*decodeRunner
+	encoders []*encodeRunner
 }
 
 func NewPipeLine() *Pipeline {
@@ -88,7 +137,7 @@ func NewPipeLine() *Pipeline {
 
 func (this *Pipeline) LoadConfig(plugConfig map[string]toml.Primitive) error {
 	for k, v := range plugConfig {
-		log.Printf("v %+v", v)
+		logger.Debug("plugin config", "section", k, "config", v)
 		plugCommon := &PluginCommonConfig{}
 		if err := toml.PrimitiveDecode(v, plugCommon); err != nil {
 			return fmt.Errorf("Can't unmarshal config: %s", err)
@@ -97,97 +146,154 @@ func (this *Pipeline) LoadConfig(plugConfig map[string]toml.Primitive) error {
 		if pluginType == "" {
 			continue
 		}
-		if plugCommon.Tag == "" {
-			log.Println("Tag empty")
+		if plugCommon.Tag == "" && (pluginType == "Input" || pluginType == "Output") {
+			logger.Warn("Tag empty", "section", k)
+		}
+		if k == metrics.DefaultRegistry.Name {
+			return fmt.Errorf("section name %s is reserved for the pipeline metrics", k)
 		}
+		section := &PluginSection{Name: k, Config: v}
 		switch pluginType {
 		case "Input":
-			this.InputRunners = append(this.InputRunners, v)
+			this.InputRunners = append(this.InputRunners, section)
 		case "Output":
-			this.OutputRunners = append(this.OutputRunners, v)
+			this.OutputRunners = append(this.OutputRunners, section)
 		case "Encoder":
-			this.EncodeRunners = append(this.EncodeRunners, v)
+			this.EncodeRunners = append(this.EncodeRunners, section)
 		case "Decoder":
-			this.DecodeRunners = append(this.DecodeRunners, v)
+			this.DecodeRunners = append(this.DecodeRunners, section)
 		}
-		log.Printf("%s => %s", k, plugCommon.Type)
+		logger.Info("plugin loaded", "section", k, "type", plugCommon.Type)
 	}
 
 	return nil
 }
 
 func (this *Pipeline) Run(mc *MasterConfig) {
-	log.Println("Starting service...")
-	plugCommon := &PluginCommonConfig{
-		Type:    "",
-		Decoder: "",
-		Tag:     "",
-		Encoder: "",
-	}
+	logger.Info("Starting service...")
+	setRunningPipeline(this)
 	PoolSize := 1000
 	rChan := make(chan *PipelinePack, PoolSize)
 	this.router.AddInChan(rChan)
 	if len(this.InputRunners) == 0 {
-		log.Fatalln("InputRunner requires that at least one")
+		logger.Fatal("InputRunner requires that at least one")
 	}
 
-	for _, input_config := range this.InputRunners {
-		cf := input_config.(toml.Primitive)
+	for _, section := range this.InputRunners {
+		plugCommon := section.commonConfig()
 
 		InputRecycleChan := make(chan *PipelinePack, PoolSize)
 		for i := 0; i < PoolSize; i++ {
 			iPack := NewPipelinePack(InputRecycleChan)
 			InputRecycleChan <- iPack
 		}
-		iRunner := NewInputRunner(InputRecycleChan, rChan)
+		runner := NewInputRunner(plugCommon, InputRecycleChan, rChan)
+		this.mutex.Lock()
+		this.inputs = append(this.inputs, runner.(*iRunner))
+		this.mutex.Unlock()
 
-		go iRunner.Start(cf)
+		go runner.Start(section.Config)
 	}
 
-	for _, output_config := range this.OutputRunners {
-		cf := output_config.(toml.Primitive)
-
-		toml.PrimitiveDecode(cf, plugCommon)
+	for _, section := range this.OutputRunners {
+		plugCommon := section.commonConfig()
 		inChan := make(chan *PipelinePack, PoolSize)
-		oRunner := NewOutputRunner(inChan)
-		this.router.AddOutChan(plugCommon.Tag, oRunner.InChan())
+		runner := NewOutputRunner(plugCommon, inChan)
+		if err := this.router.AddOutChan(plugCommon.Tag, runner.(*oRunner)); err != nil {
+			logger.Fatal("invalid output tag", "plugin", section.Name, "tag", plugCommon.Tag, "err", err)
+		}
+		this.mutex.Lock()
+		this.outputs = append(this.outputs, runner.(*oRunner))
+		this.mutex.Unlock()
+
+		go runner.Start(section.Config)
+	}
 
-		go oRunner.Start(cf)
+	if err := this.InitCodecs(); err != nil {
+		logger.Fatal("codec init failed", "err", err)
 	}
 
-	for _, encode_config := range this.EncodeRunners {
-		toml.PrimitiveDecode(encode_config.(toml.Primitive), plugCommon)
+	go this.router.Loop()
+	this.SignalWorker()
+	this.StopCodecs()
+}
+
+// Creates and initializes the configured encoders and decoders, making them
+// available to PipeEncoder and PipeDecoder.
+func (this *Pipeline) InitCodecs() error {
+	for _, section := range this.EncodeRunners {
+		plugCommon := section.commonConfig()
 		encoder_plugin, ok := encoder_plugins[plugCommon.Type]
 		if !ok {
-			log.Fatalln("unkown encoder ", plugCommon.Type)
+			return fmt.Errorf("unkown encoder %s", plugCommon.Type)
 		}
 		encoder := encoder_plugin()
+		if setter, ok := encoder.(CommonConfigSetter); ok {
+			setter.SetCommonConfig(plugCommon)
+		}
 
-		err := encoder.(Encoder).Init(encode_config.(toml.Primitive))
+		err := encoder.(Encoder).Init(section.Config)
 		if err != nil {
-			log.Fatalln("encoder.(Encoder).Init", err)
+			return fmt.Errorf("[%s] encoder.(Encoder).Init %s", section.Name, err)
 		}
-		encoders[plugCommon.Encoder] = encoder.(Encoder)
+		runner := newEncodeRunner(plugCommon, encoder.(Encoder))
+		encoders[plugCommon.Encoder] = runner
+		this.mutex.Lock()
+		this.encoders = append(this.encoders, runner)
+		this.mutex.Unlock()
 	}
 
-	for _, decode_config := range this.DecodeRunners {
-		toml.PrimitiveDecode(decode_config.(toml.Primitive), plugCommon)
+	for _, section := range this.DecodeRunners {
+		plugCommon := section.commonConfig()
 		decoder_plugin, ok := decoder_plugins[plugCommon.Type]
 		if !ok {
-			log.Fatalln("unkown decoder ", plugCommon.Type)
+			return fmt.Errorf("unkown decoder %s", plugCommon.Type)
 		}
 		decoder := decoder_plugin()
+		if setter, ok := decoder.(CommonConfigSetter); ok {
+			setter.SetCommonConfig(plugCommon)
+		}
 
-		err := decoder.(Decoder).Init(decode_config.(toml.Primitive))
+		err := decoder.(Decoder).Init(section.Config)
 		if err != nil {
-			log.Fatalln("decoder.(Decoder).Init", err)
+			return fmt.Errorf("[%s] decoder.(Decoder).Init %s", section.Name, err)
 		}
-		decoders[plugCommon.Decoder] = decoder.(Decoder)
+		runner := newDecodeRunner(plugCommon, decoder.(Decoder))
+		decoders[plugCommon.Decoder] = runner
+		this.mutex.Lock()
+		this.decoders = append(this.decoders, runner)
+		this.mutex.Unlock()
 	}
 
-	go this.router.Loop()
-	this.SignalWorker()
+	// A cycle would recurse on the first message until the stack overflows.
+	chains := make(map[string][]string)
+	for _, runner := range this.decoders {
+		if chain, ok := runner.decoder.(DecoderChain); ok {
+			chains[runner.common.Decoder] = chain.ChainedDecoders()
+		}
+	}
+	if cycle := decoderCycle(chains); cycle != nil {
+		return fmt.Errorf("decoders run each other: %s", strings.Join(cycle, " -> "))
+	}
+	return nil
 }
+
+// Stops the encoders and decoders implementing Stopper.
+func (this *Pipeline) StopCodecs() {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+	for _, runner := range this.encoders {
+		if stopper, ok := runner.encoder.(Stopper); ok {
+			stopper.Stop()
+		}
+	}
+	for _, runner := range this.decoders {
+		if stopper, ok := runner.decoder.(Stopper); ok {
+			stopper.Stop()
+		}
+	}
+}
+
 func (this *Pipeline) SignalWorker() {
 	// wait for sigint
 	ok := true
@@ -200,12 +306,12 @@ func (this *Pipeline) SignalWorker() {
 		case sig := <-sigChan:
 			switch sig {
 			case syscall.SIGHUP:
-				log.Println("Reload initiated.")
+				logger.Info("Reload initiated.")
 				if err := notify.Post("reload", nil); err != nil {
-					log.Println("Error sending reload event: ", err)
+					logger.Error("Error sending reload event", "err", err)
 				}
 			case syscall.SIGINT, syscall.SIGTERM:
-				log.Println("Shutdown initiated.")
+				logger.Info("Shutdown initiated.")
 				ok = false
 			}
 		}
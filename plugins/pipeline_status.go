@@ -0,0 +1,182 @@
+package plugins
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type RunnerState int32
+
+const (
+	StateStarting RunnerState = iota
+	StateRunning
+	StateStopped
+	StatePaused
+	StateDraining
+)
+
+var runnerStateNames = []string{"starting", "running", "stopped", "paused", "draining"}
+
+func (s RunnerState) String() string {
+	if s < 0 || int(s) >= len(runnerStateNames) {
+		return "unknown"
+	}
+	return runnerStateNames[s]
+}
+
+// Embedded by the runners, safe to read from the report server.
+type runnerState struct {
+	state   int32
+	started int64
+}
+
+func (this *runnerState) setState(state RunnerState) {
+	if state == StateRunning {
+		atomic.CompareAndSwapInt64(&this.started, 0, time.Now().UnixNano())
+	}
+	atomic.StoreInt32(&this.state, int32(state))
+}
+
+// Changes the state only if it is from, so that of concurrent requests
+// only one makes the change.
+func (this *runnerState) swapState(from, to RunnerState) bool {
+	return atomic.CompareAndSwapInt32(&this.state, int32(from), int32(to))
+}
+
+func (this *runnerState) State() RunnerState {
+	return RunnerState(atomic.LoadInt32(&this.state))
+}
+
+// Time since the plugin started running, zero if it never did.
+func (this *runnerState) Uptime() time.Duration {
+	started := atomic.LoadInt64(&this.started)
+	if started == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(0, started))
+}
+
+type ChanStatus struct {
+	Len int `json:"len"`
+	Cap int `json:"cap"`
+}
+
+// The state of one plugin section, as served on /pipeline.
+type PluginStatus struct {
+	Name     string  `json:"name"`
+	Category string  `json:"category"`
+	Type     string  `json:"type"`
+	Tag      string  `json:"tag,omitempty"`
+	TagRegex string  `json:"tag_regex,omitempty"`
+	Decoder  string  `json:"decoder,omitempty"`
+	Encoder  string  `json:"encoder,omitempty"`
+	State    string  `json:"state"`
+	Uptime   float64 `json:"uptime"`
+	// Outputs: the queue the router fills. Inputs: the pack pool, its length
+	// is the number of free packs.
+	Chan *ChanStatus `json:"chan,omitempty"`
+	// Inputs: the outputs their tag is routed to. Outputs: the tag regexes
+	// routed to them.
+	Routes []string `json:"routes,omitempty"`
+}
+
+type PipelineStatus struct {
+	Router   ChanStatus      `json:"router"`
+	Inputs   []*PluginStatus `json:"inputs"`
+	Outputs  []*PluginStatus `json:"outputs"`
+	Decoders []*PluginStatus `json:"decoders"`
+	Encoders []*PluginStatus `json:"encoders"`
+}
+
+var (
+	running      *Pipeline
+	runningMutex sync.RWMutex
+)
+
+// Returns the pipeline Run is executing, nil before it started.
+func RunningPipeline() *Pipeline {
+	runningMutex.RLock()
+	defer runningMutex.RUnlock()
+	return running
+}
+
+func setRunningPipeline(p *Pipeline) {
+	runningMutex.Lock()
+	running = p
+	runningMutex.Unlock()
+}
+
+func newPluginStatus(category string, pcf *PluginCommonConfig, state *runnerState) *PluginStatus {
+	return &PluginStatus{
+		Name:     pcf.Name,
+		Category: category,
+		Type:     pcf.Type,
+		Tag:      pcf.Tag,
+		Decoder:  pcf.Decoder,
+		Encoder:  pcf.Encoder,
+		State:    state.State().String(),
+		Uptime:   state.Uptime().Seconds(),
+	}
+}
+
+// Returns the sections of the pipeline with their state and queue depths.
+func (this *Pipeline) Status() *PipelineStatus {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+
+	status := &PipelineStatus{
+		Inputs:   []*PluginStatus{},
+		Outputs:  []*PluginStatus{},
+		Decoders: []*PluginStatus{},
+		Encoders: []*PluginStatus{},
+	}
+	if this.router.inChan != nil {
+		status.Router = ChanStatus{Len: len(this.router.inChan), Cap: cap(this.router.inChan)}
+	}
+	for _, runner := range this.inputs {
+		s := newPluginStatus("Input", runner.common, &runner.runnerState)
+		s.Decoder, s.Encoder = "", ""
+		s.Chan = &ChanStatus{Len: len(runner.inChan), Cap: cap(runner.inChan)}
+		s.Routes = []string{}
+		for _, r := range this.router.routes() {
+			if r.re.MatchString(runner.common.Tag) && !containsString(s.Routes, r.runner.Name()) {
+				s.Routes = append(s.Routes, r.runner.Name())
+			}
+		}
+		status.Inputs = append(status.Inputs, s)
+	}
+	for _, runner := range this.outputs {
+		s := newPluginStatus("Output", runner.common, &runner.runnerState)
+		s.Tag = ""
+		s.TagRegex = runner.common.Tag
+		s.Chan = &ChanStatus{Len: len(runner.routerChan), Cap: cap(runner.routerChan)}
+		s.Routes = []string{}
+		for _, r := range this.router.routes() {
+			if r.runner == runner {
+				s.Routes = append(s.Routes, r.re.String())
+			}
+		}
+		status.Outputs = append(status.Outputs, s)
+	}
+	for _, runner := range this.decoders {
+		s := newPluginStatus("Decoder", runner.common, &runner.runnerState)
+		s.Encoder = ""
+		status.Decoders = append(status.Decoders, s)
+	}
+	for _, runner := range this.encoders {
+		s := newPluginStatus("Encoder", runner.common, &runner.runnerState)
+		s.Decoder = ""
+		status.Encoders = append(status.Encoders, s)
+	}
+	return status
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
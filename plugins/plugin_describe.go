@@ -0,0 +1,155 @@
+package plugins
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Describes a single config option of a plugin.
+type OptionDescription struct {
+	Key         string      `json:"key"`
+	Type        string      `json:"type"`
+	Default     interface{} `json:"default"`
+	Description string      `json:"description"`
+}
+
+// Describes a registered plugin and the options it accepts.
+type PluginDescription struct {
+	Name     string              `json:"name"`
+	Category string              `json:"category"`
+	Options  []OptionDescription `json:"options"`
+}
+
+// The plugin categories, in the order they are listed.
+var PluginCategories = []string{"Input", "Output", "Decoder", "Encoder"}
+
+func pluginFactories(category string) map[string]func() interface{} {
+	switch category {
+	case "Input":
+		return input_plugins
+	case "Output":
+		return output_plugins
+	case "Decoder":
+		return decoder_plugins
+	case "Encoder":
+		return encoder_plugins
+	}
+	return nil
+}
+
+// Returns the sorted names of all registered plugins, by category.
+func PluginNames() map[string][]string {
+	names := make(map[string][]string)
+	for _, category := range PluginCategories {
+		list := make([]string, 0)
+		for name := range pluginFactories(category) {
+			list = append(list, name)
+		}
+		sort.Strings(list)
+		names[category] = list
+	}
+	return names
+}
+
+// Returns the config options of the registered plugin `name`, including the
+// common options of its category. Default values are the ones the plugin's
+// ConfigStruct sets before decoding its TOML section.
+func DescribePlugin(name string) (desc *PluginDescription, err error) {
+	category := getPluginType(name)
+	factory, ok := pluginFactories(category)[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown plugin: %s", name)
+	}
+	desc = &PluginDescription{
+		Name:     name,
+		Category: category,
+		Options:  make([]OptionDescription, 0),
+	}
+
+	common := &PluginCommonConfig{Type: name}
+	for _, opt := range describeStruct(common) {
+		switch opt.Key {
+		case "tag":
+			if category != "Input" && category != "Output" {
+				continue
+			}
+		case "decoder":
+			if category == "Input" || category == "Encoder" {
+				continue
+			}
+			if category == "Decoder" {
+				opt.Description = "name other sections use to refer to this decoder"
+			}
+		case "encoder":
+			if category == "Input" || category == "Decoder" {
+				continue
+			}
+			if category == "Encoder" {
+				opt.Description = "name other sections use to refer to this encoder"
+			}
+		}
+		desc.Options = append(desc.Options, opt)
+	}
+
+	if hasConfig, ok := factory().(HasConfigStruct); ok {
+		desc.Options = append(desc.Options, describeStruct(hasConfig.ConfigStruct())...)
+	}
+	return desc, nil
+}
+
+func describeStruct(config interface{}) (options []OptionDescription) {
+	rv := reflect.Indirect(reflect.ValueOf(config))
+	if rv.Kind() != reflect.Struct {
+		return
+	}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" {
+			// Unexported fields are never decoded.
+			continue
+		}
+		key := field.Tag.Get("toml")
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			// The toml decoder falls back to a case insensitive match.
+			key = strings.ToLower(field.Name)
+		}
+		options = append(options, OptionDescription{
+			Key:         key,
+			Type:        tomlTypeName(field.Type),
+			Default:     rv.Field(i).Interface(),
+			Description: field.Tag.Get("desc"),
+		})
+	}
+	return
+}
+
+func tomlTypeName(t reflect.Type) string {
+	if t == reflect.TypeOf(time.Time{}) {
+		return "datetime"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.Slice, reflect.Array:
+		return "array of " + tomlTypeName(t.Elem())
+	case reflect.Map, reflect.Struct:
+		return "table"
+	case reflect.Ptr:
+		return tomlTypeName(t.Elem())
+	}
+	return "any"
+}
@@ -2,6 +2,8 @@ package plugins
 
 import (
 	"github.com/bbangert/toml"
+	"github.com/millken/kaman/logger"
+	"github.com/millken/kaman/metrics"
 )
 
 type Input interface {
@@ -14,6 +16,13 @@ type Output interface {
 	Run(out OutputRunner) error
 }
 
+// Inputs that can be stopped at runtime implement Stopper. After Stop, Run
+// must stop sending packs and return nil. Decoders and encoders that start
+// goroutines implement it to end them, see Pipeline.StopCodecs.
+type Stopper interface {
+	Stop()
+}
+
 type Decoder interface {
 	Init(config toml.Primitive) error
 	Decode(pack *PipelinePack) (*PipelinePack, error)
@@ -24,9 +33,41 @@ type Encoder interface {
 	Encode(pack *PipelinePack) (*PipelinePack, error)
 }
 
+// Plugins that have a config struct implement HasConfigStruct. The returned
+// struct carries the default values, Init decodes the TOML section on top of
+// it and `kaman -describe` reads the options from it.
+type HasConfigStruct interface {
+	ConfigStruct() interface{}
+}
+
+// Decoders and encoders get no common config in Init, those that log or
+// record metrics of their own implement CommonConfigSetter and receive it
+// before Init.
+type CommonConfigSetter interface {
+	SetCommonConfig(pcf *PluginCommonConfig)
+}
+
 type PluginCommonConfig struct {
-	Type    string `toml:"type"`
-	Tag     string `toml:"tag"`
-	Decoder string `toml:"decoder"`
-	Encoder string `toml:"encoder"`
+	// Name of the config section, set by the runner.
+	Name    string `toml:"-"`
+	Type    string `toml:"type" desc:"plugin type name"`
+	Tag     string `toml:"tag" desc:"message tag set by inputs, tag regex matched by outputs"`
+	Decoder string `toml:"decoder" desc:"decoder name"`
+	Encoder string `toml:"encoder" desc:"encoder name"`
+}
+
+// Returns a logger that carries the plugin name, type and tag as context.
+func (pcf *PluginCommonConfig) Logger() *logger.Logger {
+	if pcf == nil {
+		return logger.Default()
+	}
+	return logger.With("plugin", pcf.Name, "type", pcf.Type, "tag", pcf.Tag)
+}
+
+// Returns the metrics registry of the plugin instance.
+func (pcf *PluginCommonConfig) Metrics() *metrics.Registry {
+	if pcf == nil {
+		return metrics.DefaultRegistry
+	}
+	return metrics.PluginRegistry(pcf.Name, pcf.Type, pcf.Tag)
 }
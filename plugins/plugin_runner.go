@@ -1,109 +1,317 @@
 package plugins
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/bbangert/toml"
+	"github.com/millken/kaman/metrics"
 )
 
 type InputRunner interface {
+	Name() string
 	InChan() chan *PipelinePack
 	RouterChan() chan *PipelinePack
 	Start(cf toml.Primitive)
 }
 
+// Metrics every input records, whatever the plugin.
+type inputMetrics struct {
+	messages    *metrics.Meter
+	bytes       *metrics.Meter
+	lastMessage *metrics.Gauge
+}
+
 type iRunner struct {
+	runnerState
+	common     *PluginCommonConfig
 	inChan     chan *PipelinePack
+	outChan    chan *PipelinePack
 	routerChan chan *PipelinePack
+	metrics    inputMetrics
+
+	mutex sync.Mutex
+	input Input
+	// Closed when Run returned.
+	done chan struct{}
 }
 
-func NewInputRunner(in, router chan *PipelinePack) InputRunner {
+// Creates the runner of an input. `in` is the pack pool of the input, packs
+// sent to RouterChan are accounted for and passed on to `router`.
+func NewInputRunner(pcf *PluginCommonConfig, in, router chan *PipelinePack) InputRunner {
+	registry := pcf.Metrics()
+	registry.GaugeFunc("pool_free", func() int64 { return int64(len(in)) })
+	registry.Gauge("pool_size").Set(int64(cap(in)))
 	return &iRunner{
+		common:     pcf,
 		inChan:     in,
+		outChan:    make(chan *PipelinePack),
 		routerChan: router,
+		metrics: inputMetrics{
+			messages:    registry.Meter("messages_out"),
+			bytes:       registry.Meter("bytes_out"),
+			lastMessage: registry.Gauge("last_message"),
+		},
+		done: make(chan struct{}),
 	}
 }
 
+func (this *iRunner) Name() string {
+	return this.common.Name
+}
+
 func (this *iRunner) InChan() chan *PipelinePack {
 	return this.inChan
 }
 
 func (this *iRunner) RouterChan() chan *PipelinePack {
-	return this.routerChan
+	return this.outChan
 }
 
-func (this *iRunner) Start(conf toml.Primitive) {
-	plugCommon := &PluginCommonConfig{
-		Type: "",
-		Tag:  "",
-	}
-	if err := toml.PrimitiveDecode(conf, plugCommon); err != nil {
-		log.Fatalln("toml struct error")
+// Forwards the packs of the input to the router.
+func (this *iRunner) pump() {
+	for pack := range this.outChan {
+		now := time.Now()
+		pack.RecvTime = now
+		this.metrics.messages.Mark(1)
+		this.metrics.bytes.Mark(int64(len(pack.MsgBytes)))
+		this.metrics.lastMessage.Set(now.Unix())
+		publishTap(TapInput, this.common.Name, pack)
+		startTrace("input", this.common.Name, pack)
+		pack.TraceSpan("input", this.common.Name, now, "")
+		this.routerChan <- pack
 	}
+}
+
+func (this *iRunner) Start(conf toml.Primitive) {
+	log := this.common.Logger()
 
-	input, ok := input_plugins[plugCommon.Type]
+	input, ok := input_plugins[this.common.Type]
 	if !ok {
-		log.Fatalln("unkown type ", plugCommon.Type)
+		log.Fatal("unkown type")
 	}
 
 	in := input()
 
-	err := in.(Input).Init(plugCommon, conf)
+	err := in.(Input).Init(this.common, conf)
 	if err != nil {
-		log.Fatalln("in.(Input).Init", err)
+		log.Fatal("in.(Input).Init", "err", err)
 	}
 
+	this.mutex.Lock()
+	this.input = in.(Input)
+	this.mutex.Unlock()
+
+	go this.pump()
+	this.setState(StateRunning)
 	err = in.(Input).Run(this)
+	close(this.done)
 	if err != nil {
-		log.Fatalln("in.(Input).Run", err)
+		log.Fatal("in.(Input).Run", "err", err)
+	}
+	if this.State() != StateDraining {
+		this.setState(StateStopped)
 	}
 }
 
+var ErrNotStoppable = errors.New("input can't be stopped")
+
+// Stops the input and waits up to timeout until all the packs it sent have
+// been processed by the outputs.
+func (this *iRunner) Drain(timeout time.Duration) error {
+	this.mutex.Lock()
+	input := this.input
+	this.mutex.Unlock()
+	if input == nil {
+		return fmt.Errorf("input is %s", this.State())
+	}
+	stopper, ok := input.(Stopper)
+	if !ok {
+		return ErrNotStoppable
+	}
+	// Inputs may not be stopped twice.
+	if !this.swapState(StateRunning, StateDraining) {
+		return fmt.Errorf("input is %s", this.State())
+	}
+	stopper.Stop()
+	deadline := time.After(timeout)
+	select {
+	case <-this.done:
+	case <-deadline:
+		return fmt.Errorf("input did not stop within %s", timeout)
+	}
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for len(this.inChan) < cap(this.inChan) {
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			return fmt.Errorf("%d messages still in flight after %s", cap(this.inChan)-len(this.inChan), timeout)
+		}
+	}
+	this.setState(StateStopped)
+	return nil
+}
+
 type OutputRunner interface {
+	Name() string
 	InChan() chan *PipelinePack
 	Start(cf toml.Primitive)
 }
 
+// Metrics every output records, whatever the plugin.
+type outputMetrics struct {
+	messages    *metrics.Meter
+	bytes       *metrics.Meter
+	drops       *metrics.Counter
+	latency     *metrics.Timer
+	lastMessage *metrics.Gauge
+}
+
 type oRunner struct {
-	inChan chan *PipelinePack
+	runnerState
+	common     *PluginCommonConfig
+	routerChan chan *PipelinePack
+	inChan     chan *PipelinePack
+	metrics    outputMetrics
+
+	// Non nil while paused, closed by Resume.
+	resume     chan struct{}
+	resumeLock sync.Mutex
+	block      int32
+
+	mutex  sync.Mutex
+	output Output
 }
 
-func NewOutputRunner(in chan *PipelinePack) OutputRunner {
+// Creates the runner of an output. The router queues packs on `in`, the
+// runner hands them to the plugin through InChan.
+func NewOutputRunner(pcf *PluginCommonConfig, in chan *PipelinePack) OutputRunner {
+	registry := pcf.Metrics()
+	registry.GaugeFunc("chan_len", func() int64 { return int64(len(in)) })
+	registry.Gauge("chan_cap").Set(int64(cap(in)))
 	return &oRunner{
-		inChan: in,
+		common:     pcf,
+		routerChan: in,
+		inChan:     make(chan *PipelinePack),
+		metrics: outputMetrics{
+			messages:    registry.Meter("messages_in"),
+			bytes:       registry.Meter("bytes_in"),
+			drops:       registry.Counter("drops"),
+			latency:     registry.Timer("latency"),
+			lastMessage: registry.Gauge("last_message"),
+		},
 	}
 }
 
+func (this *oRunner) Name() string {
+	return this.common.Name
+}
+
 func (this *oRunner) InChan() chan *PipelinePack {
 	return this.inChan
 }
 
-func (this *oRunner) Start(cf toml.Primitive) {
-	plugCommon := &PluginCommonConfig{
-		Type:    "",
-		Tag:     "",
-		Decoder: "",
-		Encoder: "",
+// Stops handing packs to the plugin. With block the router waits once the
+// queue of the output is full, applying backpressure to all inputs; otherwise
+// the queue buffers the packs and drops the oldest ones when full.
+func (this *oRunner) Pause(block bool) error {
+	this.resumeLock.Lock()
+	defer this.resumeLock.Unlock()
+	if this.State() != StateRunning {
+		return fmt.Errorf("output is %s", this.State())
+	}
+	this.resume = make(chan struct{})
+	if block {
+		atomic.StoreInt32(&this.block, 1)
+	}
+	this.setState(StatePaused)
+	return nil
+}
+
+func (this *oRunner) Resume() error {
+	this.resumeLock.Lock()
+	defer this.resumeLock.Unlock()
+	if this.resume == nil {
+		return fmt.Errorf("output is %s", this.State())
 	}
-	log.Printf("cf %+v", cf)
-	if err := toml.PrimitiveDecode(cf, plugCommon); err != nil {
-		log.Fatalln("toml struct error")
+	atomic.StoreInt32(&this.block, 0)
+	this.setState(StateRunning)
+	close(this.resume)
+	this.resume = nil
+	return nil
+}
+
+// Whether the router should wait for the output rather than drop packs.
+func (this *oRunner) blocking() bool {
+	return atomic.LoadInt32(&this.block) == 1
+}
+
+func (this *oRunner) waitResumed() {
+	this.resumeLock.Lock()
+	resume := this.resume
+	this.resumeLock.Unlock()
+	if resume != nil {
+		<-resume
 	}
+}
 
-	output_plugin, ok := output_plugins[plugCommon.Type]
+// Hands the packs queued by the router to the plugin.
+func (this *oRunner) pump() {
+	for pack := range this.routerChan {
+		// Held here while paused, the router queues the following ones.
+		this.waitResumed()
+		// The plugin may recycle the pack, finishing its trace, as soon as
+		// it has it.
+		size := len(pack.MsgBytes)
+		recvTime := pack.RecvTime
+		now := time.Now()
+		if trace := pack.Trace(); trace != nil {
+			routed := trace.endOf("route", this.common.Name)
+			if routed.IsZero() {
+				// Taken before the router got to record the route.
+				routed = now
+			}
+			trace.add(Span{Stage: "queue", Plugin: this.common.Name, Start: routed, Duration: now.Sub(routed)})
+		}
+		this.inChan <- pack
+		this.metrics.messages.Mark(1)
+		this.metrics.bytes.Mark(int64(size))
+		this.metrics.lastMessage.Set(now.Unix())
+		if !recvTime.IsZero() {
+			this.metrics.latency.Update(now.Sub(recvTime))
+		}
+	}
+}
+
+func (this *oRunner) Start(cf toml.Primitive) {
+	log := this.common.Logger()
+	log.Debug("output config", "config", cf)
+
+	output_plugin, ok := output_plugins[this.common.Type]
 	if !ok {
-		log.Fatalln("unkown type ", plugCommon.Type)
+		log.Fatal("unkown type")
 	}
 
 	out := output_plugin()
 
-	err := out.(Output).Init(plugCommon, cf)
+	err := out.(Output).Init(this.common, cf)
 	if err != nil {
-		log.Fatalln("out.(Output).Init", err)
+		log.Fatal("out.(Output).Init", "err", err)
 	}
 
+	this.mutex.Lock()
+	this.output = out.(Output)
+	this.mutex.Unlock()
+
+	go this.pump()
+	this.setState(StateRunning)
 	err = out.(Output).Run(this)
+	this.setState(StateStopped)
 	if err != nil {
-		log.Fatalln("out.(Output).Run", err)
+		log.Fatal("out.(Output).Run", "err", err)
 	}
 }
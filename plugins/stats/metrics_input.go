@@ -0,0 +1,178 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/bbangert/toml"
+	"github.com/millken/kaman/logger"
+	"github.com/millken/kaman/metrics"
+	"github.com/millken/kaman/plugins"
+)
+
+// MetricsInput periodically injects kaman's own metrics into the pipeline:
+// one message per metrics registry (the router and every plugin, including
+// their channel depths) and one with the Go runtime stats.
+type MetricsInput struct {
+	config   *MetricsInputConfig
+	common   *plugins.PluginCommonConfig
+	log      *logger.Logger
+	hostname string
+	stopChan chan bool
+}
+
+type MetricsInputConfig struct {
+	TickerInterval int  `toml:"ticker_interval" desc:"seconds between snapshots"`
+	Runtime        bool `desc:"also emit the Go runtime stats"`
+}
+
+func (self *MetricsInput) ConfigStruct() interface{} {
+	return &MetricsInputConfig{
+		TickerInterval: 10,
+		Runtime:        true,
+	}
+}
+
+func (self *MetricsInput) Init(pcf *plugins.PluginCommonConfig, conf toml.Primitive) (err error) {
+	self.common = pcf
+	self.log = pcf.Logger()
+	self.config = self.ConfigStruct().(*MetricsInputConfig)
+	if err := toml.PrimitiveDecode(conf, self.config); err != nil {
+		return fmt.Errorf("Can't unmarshal MetricsInput config: %s", err)
+	}
+	if self.config.TickerInterval <= 0 {
+		return fmt.Errorf("ticker_interval must be positive")
+	}
+	if self.hostname, err = os.Hostname(); err != nil {
+		self.hostname = "unknown"
+	}
+	self.stopChan = make(chan bool)
+	return nil
+}
+
+func (self *MetricsInput) Run(runner plugins.InputRunner) error {
+	ticker := time.NewTicker(time.Duration(self.config.TickerInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-self.stopChan:
+			return nil
+		case now := <-ticker.C:
+			for name, snap := range metrics.Snapshot() {
+				self.emit(runner, now, registryFields(name, snap))
+			}
+			if self.config.Runtime {
+				self.emit(runner, now, runtimeFields())
+			}
+		}
+	}
+}
+
+func (self *MetricsInput) Stop() {
+	close(self.stopChan)
+}
+
+// Sends one message, the fields are both the message data and, as JSON, its
+// bytes.
+func (self *MetricsInput) emit(runner plugins.InputRunner, now time.Time, fields map[string]interface{}) {
+	fields["hostname"] = self.hostname
+	fields["timestamp"] = now.Unix()
+	msgBytes, err := json.Marshal(fields)
+	if err != nil {
+		self.log.Error("json.Marshal", "err", err)
+		return
+	}
+	pack := <-runner.InChan()
+	pack.MsgBytes = msgBytes
+	pack.Msg.Tag = self.common.Tag
+	pack.Msg.Timestamp = now.Unix()
+	pack.Msg.Data = fields
+	runner.RouterChan() <- pack
+}
+
+// Flattens a registry snapshot, a meter "messages_in" becomes the fields
+// "messages_in.count", "messages_in.rate1" and so on.
+func registryFields(name string, snap *metrics.RegistrySnapshot) map[string]interface{} {
+	fields := map[string]interface{}{
+		"plugin": name,
+	}
+	if snap.Labels != nil {
+		fields["plugin_type"] = snap.Labels["type"]
+		fields["plugin_tag"] = snap.Labels["tag"]
+	}
+	for metric, value := range snap.Metrics {
+		switch v := value.(type) {
+		case metrics.MeterSnapshot:
+			addMeter(fields, metric, v)
+		case metrics.HistogramSnapshot:
+			addHistogram(fields, metric, v)
+		case metrics.TimerSnapshot:
+			addHistogram(fields, metric, v.HistogramSnapshot)
+			addMeter(fields, metric, metrics.MeterSnapshot{
+				Count:    v.Count,
+				Rate1:    v.Rate1,
+				Rate5:    v.Rate5,
+				Rate15:   v.Rate15,
+				RateMean: v.RateMean,
+			})
+		default:
+			fields[metric] = v
+		}
+	}
+	return fields
+}
+
+func addMeter(fields map[string]interface{}, name string, m metrics.MeterSnapshot) {
+	fields[name+".count"] = m.Count
+	fields[name+".rate1"] = m.Rate1
+	fields[name+".rate5"] = m.Rate5
+	fields[name+".rate15"] = m.Rate15
+	fields[name+".rate_mean"] = m.RateMean
+}
+
+func addHistogram(fields map[string]interface{}, name string, h metrics.HistogramSnapshot) {
+	fields[name+".count"] = h.Count
+	fields[name+".min"] = h.Min
+	fields[name+".max"] = h.Max
+	fields[name+".mean"] = h.Mean
+	fields[name+".stddev"] = h.StdDev
+	fields[name+".p50"] = h.Pct50
+	fields[name+".p75"] = h.Pct75
+	fields[name+".p90"] = h.Pct90
+	fields[name+".p95"] = h.Pct95
+	fields[name+".p99"] = h.Pct99
+	fields[name+".p999"] = h.Pct999
+}
+
+func runtimeFields() map[string]interface{} {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return map[string]interface{}{
+		"plugin":                 "runtime",
+		"goroutines":             runtime.NumGoroutine(),
+		"gomaxprocs":             runtime.GOMAXPROCS(0),
+		"memstats.alloc":         ms.Alloc,
+		"memstats.total_alloc":   ms.TotalAlloc,
+		"memstats.sys":           ms.Sys,
+		"memstats.heap_alloc":    ms.HeapAlloc,
+		"memstats.heap_sys":      ms.HeapSys,
+		"memstats.heap_idle":     ms.HeapIdle,
+		"memstats.heap_inuse":    ms.HeapInuse,
+		"memstats.heap_released": ms.HeapReleased,
+		"memstats.heap_objects":  ms.HeapObjects,
+		"memstats.mallocs":       ms.Mallocs,
+		"memstats.frees":         ms.Frees,
+		"memstats.num_gc":        ms.NumGC,
+		"memstats.pause_total":   ms.PauseTotalNs,
+	}
+}
+
+func init() {
+	plugins.RegisterInput("MetricsInput", func() interface{} {
+		return new(MetricsInput)
+	})
+}
@@ -0,0 +1,182 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Where in the pipeline a tap copies messages.
+type TapPoint int
+
+const (
+	// After the input, before the router.
+	TapInput TapPoint = iota
+	// After a decoder.
+	TapDecode
+	// After an encoder.
+	TapEncode
+)
+
+var tapPointNames = []string{"input", "decode", "encode"}
+
+func (p TapPoint) String() string {
+	if p < 0 || int(p) >= len(tapPointNames) {
+		return "unknown"
+	}
+	return tapPointNames[p]
+}
+
+func ParseTapPoint(s string) (TapPoint, error) {
+	for i, name := range tapPointNames {
+		if name == s {
+			return TapPoint(i), nil
+		}
+	}
+	return TapInput, fmt.Errorf("unknown tap point: %s", s)
+}
+
+// A message copied by a tap.
+type TapMessage struct {
+	Point     string                 `json:"point"`
+	Plugin    string                 `json:"plugin"`
+	Tag       string                 `json:"tag"`
+	Timestamp int64                  `json:"timestamp"`
+	Bytes     string                 `json:"bytes"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	Meta      map[string]string      `json:"meta,omitempty"`
+}
+
+// A Tap receives JSON copies of the messages passing a pipeline point. It is
+// lossy: messages are dropped rather than waiting for a slow reader.
+type Tap struct {
+	Point TapPoint
+	// Matched against the message tag, nil matches all.
+	Tag *regexp.Regexp
+	// When set, the message data must have the field and, if Value is set,
+	// its value must match Value.
+	Field string
+	Value *regexp.Regexp
+	// Copies every Sample-th matching message.
+	Sample int64
+
+	C       chan []byte
+	matched int64
+	dropped int64
+}
+
+func NewTap(point TapPoint) *Tap {
+	return &Tap{
+		Point:  point,
+		Sample: 1,
+		C:      make(chan []byte, 64),
+	}
+}
+
+// Messages lost because the reader did not keep up.
+func (this *Tap) Dropped() int64 {
+	return atomic.LoadInt64(&this.dropped)
+}
+
+func (this *Tap) match(pack *PipelinePack) bool {
+	if this.Tag != nil && !this.Tag.MatchString(pack.Msg.Tag) {
+		return false
+	}
+	if this.Field != "" {
+		value, ok := pack.Msg.Data[this.Field]
+		if !ok {
+			return false
+		}
+		if this.Value != nil && !this.Value.MatchString(fmt.Sprint(value)) {
+			return false
+		}
+	}
+	return atomic.AddInt64(&this.matched, 1)%this.Sample == 0
+}
+
+var (
+	// The []*Tap subscribed, replaced as a whole on changes.
+	taps      atomic.Value
+	tapsMutex sync.Mutex
+	// Lets publishers skip all the work while nobody listens.
+	tapCount int32
+)
+
+func init() {
+	taps.Store([]*Tap{})
+}
+
+func Subscribe(tap *Tap) {
+	tapsMutex.Lock()
+	defer tapsMutex.Unlock()
+	list := append(append([]*Tap{}, taps.Load().([]*Tap)...), tap)
+	taps.Store(list)
+	atomic.StoreInt32(&tapCount, int32(len(list)))
+}
+
+func Unsubscribe(tap *Tap) {
+	tapsMutex.Lock()
+	defer tapsMutex.Unlock()
+	list := make([]*Tap, 0)
+	for _, t := range taps.Load().([]*Tap) {
+		if t != tap {
+			list = append(list, t)
+		}
+	}
+	taps.Store(list)
+	atomic.StoreInt32(&tapCount, int32(len(list)))
+}
+
+// Copies the pack to the taps of the point, never blocks.
+func publishTap(point TapPoint, plugin string, pack *PipelinePack) {
+	if atomic.LoadInt32(&tapCount) == 0 {
+		return
+	}
+	// Outputs share the pack, the decoder of another one may be writing
+	// the data.
+	pack.Msg.RLock()
+	defer pack.Msg.RUnlock()
+	var msg []byte
+	for _, tap := range taps.Load().([]*Tap) {
+		if tap.Point != point || !tap.match(pack) {
+			continue
+		}
+		if msg == nil {
+			msg = tapMessage(point, plugin, pack)
+		}
+		select {
+		case tap.C <- msg:
+		default:
+			atomic.AddInt64(&tap.dropped, 1)
+		}
+	}
+}
+
+func tapMessage(point TapPoint, plugin string, pack *PipelinePack) []byte {
+	// Encoders write Msg.MsgBytes, before them it's the raw input.
+	msgBytes := pack.MsgBytes
+	if point == TapEncode {
+		msgBytes = pack.Msg.MsgBytes
+	}
+	tm := &TapMessage{
+		Point:     point.String(),
+		Plugin:    plugin,
+		Tag:       pack.Msg.Tag,
+		Timestamp: pack.Msg.Timestamp,
+		Bytes:     string(msgBytes),
+		Data:      pack.Msg.Data,
+		Meta:      pack.Msg.Meta,
+	}
+	if tm.Timestamp == 0 {
+		tm.Timestamp = time.Now().Unix()
+	}
+	js, err := json.Marshal(tm)
+	if err != nil {
+		tm.Data = nil
+		js, _ = json.Marshal(tm)
+	}
+	return js
+}
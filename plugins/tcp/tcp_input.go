@@ -5,13 +5,12 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"log"
 	"net"
 	"sync"
 	"time"
 
 	"github.com/bbangert/toml"
-	"github.com/millken/kaman/metrics"
+	"github.com/millken/kaman/logger"
 	"github.com/millken/kaman/plugins"
 )
 
@@ -22,31 +21,41 @@ type TcpInput struct {
 	listener          net.Listener
 	wg                sync.WaitGroup
 	stopChan          chan bool
+	// The open connections, closed by Stop.
+	conns             map[net.Conn]bool
+	connLock          sync.Mutex
+	stopped           bool
 	config            *TcpInputConfig
 	common            *plugins.PluginCommonConfig
+	log               *logger.Logger
 	runner            plugins.InputRunner
 }
 
 type TcpInputConfig struct {
 	// Network type (e.g. "tcp", "tcp4", "tcp6", "unix" or "unixpacket").
 	// Needs to match the input type.
-	Net string
+	Net string `desc:"network type"`
 	// String representation of the address of the network connection on which
 	// the listener should be listening (e.g. "127.0.0.1:5565").
-	Address string
+	Address string `desc:"listen address, e.g. 127.0.0.1:5565"`
 	// Set to true if TCP Keep Alive should be used.
-	KeepAlive bool `toml:"keep_alive"`
+	KeepAlive bool `toml:"keep_alive" desc:"enable TCP keep alive"`
 	// Integer indicating seconds between keep alives.
-	KeepAlivePeriod int `toml:"keep_alive_period"`
+	KeepAlivePeriod int `toml:"keep_alive_period" desc:"seconds between keep alives"`
+}
+
+func (self *TcpInput) ConfigStruct() interface{} {
+	return &TcpInputConfig{
+		Net: "tcp",
+	}
 }
 
 func (self *TcpInput) Init(pcf *plugins.PluginCommonConfig, conf toml.Primitive) (err error) {
 
-	log.Println("TcpInput Init")
 	self.common = pcf
-	self.config = &TcpInputConfig{
-		Net: "tcp",
-	}
+	self.log = pcf.Logger()
+	self.log.Info("TcpInput Init")
+	self.config = self.ConfigStruct().(*TcpInputConfig)
 	if err := toml.PrimitiveDecode(conf, self.config); err != nil {
 		return fmt.Errorf("Can't unmarshal TcpInput config: %s", err)
 	}
@@ -69,6 +78,7 @@ func (self *TcpInput) Init(pcf *plugins.PluginCommonConfig, conf toml.Primitive)
 		self.keepAliveDuration = time.Duration(self.config.KeepAlivePeriod) * time.Second
 	}
 	self.stopChan = make(chan bool)
+	self.conns = make(map[net.Conn]bool)
 	closeIt = false
 	return nil
 }
@@ -86,9 +96,8 @@ func (self *TcpInput) handleConnection(conn net.Conn) {
 	//	host = raddr
 	//}
 	//log.Printf("handle conn: %s, host: %s", raddr, host)
-	counter := fmt.Sprintf("Tag:%s,Type:%s", self.common.Tag, self.common.Type)
-	mc := metrics.NewCounter(counter)
 	defer func() {
+		self.untrack(conn)
 		conn.Close()
 		self.wg.Done()
 	}()
@@ -130,7 +139,6 @@ func (self *TcpInput) handleConnection(conn net.Conn) {
 			pack.MsgBytes = bytes.TrimSpace(buf[:])
 			pack.Msg.Tag = self.common.Tag
 			pack.Msg.Timestamp = time.Now().Unix()
-			mc.Add(1)
 			self.runner.RouterChan() <- pack
 			buf = buf[:0] 
 		}
@@ -147,12 +155,16 @@ func (self *TcpInput) Run(runner plugins.InputRunner) error {
 	self.runner = runner
 	for {
 		if conn, e = self.listener.Accept(); e != nil {
-			if netErr, ok := e.(net.Error); ok && netErr.Temporary() {
-				log.Print(fmt.Errorf("TCP accept failed: %s", e))
-				continue
-			} else {
-				break
+			select {
+			case <-self.stopChan:
+				e = nil
+			default:
+				if netErr, ok := e.(net.Error); ok && netErr.Temporary() {
+					self.log.Error("TCP accept failed", "err", e)
+					continue
+				}
 			}
+			break
 		}
 		if self.config.KeepAlive {
 			tcpConn, ok := conn.(*net.TCPConn)
@@ -164,6 +176,10 @@ func (self *TcpInput) Run(runner plugins.InputRunner) error {
 				tcpConn.SetKeepAlivePeriod(self.keepAliveDuration)
 			}
 		}
+		if !self.track(conn) {
+			conn.Close()
+			continue
+		}
 		self.wg.Add(1)
 		go self.handleConnection(conn)
 	}
@@ -171,6 +187,36 @@ func (self *TcpInput) Run(runner plugins.InputRunner) error {
 	return e
 }
 
+// Adds an accepted connection to those Stop closes, false once stopped.
+func (self *TcpInput) track(conn net.Conn) bool {
+	self.connLock.Lock()
+	defer self.connLock.Unlock()
+	if self.stopped {
+		return false
+	}
+	self.conns[conn] = true
+	return true
+}
+
+func (self *TcpInput) untrack(conn net.Conn) {
+	self.connLock.Lock()
+	delete(self.conns, conn)
+	self.connLock.Unlock()
+}
+
+// Closes the listener and the open connections, so connections waiting for
+// data end at once rather than at their read deadline.
+func (self *TcpInput) Stop() {
+	close(self.stopChan)
+	self.listener.Close()
+	self.connLock.Lock()
+	self.stopped = true
+	for conn := range self.conns {
+		conn.Close()
+	}
+	self.connLock.Unlock()
+}
+
 func init() {
 	plugins.RegisterInput("TcpInput", func() interface{} {
 		return new(TcpInput)
@@ -0,0 +1,280 @@
+package plugins
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Which packs get traced. Packs are marked by their input when the sample
+// hits, with a Field they must also have the field, matching Value if set.
+// Packs whose field only exists once decoded are marked by the decoder.
+type TraceFilter struct {
+	// Fraction of the packs traced, 0 with a Field traces all matching.
+	Sample float64
+	Field  string
+	Value  *regexp.Regexp
+	// Finished traces kept, also the limit of traces in flight.
+	Size int
+}
+
+// A span is one stage a traced pack went through.
+type Span struct {
+	Stage    string
+	Plugin   string
+	Start    time.Time
+	Duration time.Duration
+	Result   string
+}
+
+// A Trace records the spans of a pack from its input until it is recycled.
+type Trace struct {
+	ID    int64
+	Tag   string
+	Input string
+	Start time.Time
+	mutex sync.Mutex
+	end   time.Time
+	spans []Span
+}
+
+func (this *Trace) add(span Span) {
+	this.mutex.Lock()
+	this.spans = append(this.spans, span)
+	this.mutex.Unlock()
+}
+
+// End of the last span of the stage and plugin, zero without one.
+func (this *Trace) endOf(stage, plugin string) time.Time {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	for i := len(this.spans) - 1; i >= 0; i-- {
+		if s := this.spans[i]; s.Stage == stage && s.Plugin == plugin {
+			return s.Start.Add(s.Duration)
+		}
+	}
+	return time.Time{}
+}
+
+type SpanInfo struct {
+	Stage string `json:"stage"`
+	// Nanoseconds since the trace started.
+	Offset   int64  `json:"offset_ns"`
+	Duration int64  `json:"duration_ns"`
+	Plugin   string `json:"plugin,omitempty"`
+	Result   string `json:"result,omitempty"`
+}
+
+type TraceInfo struct {
+	ID    int64     `json:"id"`
+	Tag   string    `json:"tag"`
+	Input string    `json:"input,omitempty"`
+	Start time.Time `json:"start"`
+	// Zero while the pack is in flight.
+	Duration int64      `json:"duration_ns"`
+	Done     bool       `json:"done"`
+	Spans    []SpanInfo `json:"spans"`
+}
+
+// Returns the trace with its spans in time order.
+func (this *Trace) Info() *TraceInfo {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	info := &TraceInfo{
+		ID:    this.ID,
+		Tag:   this.Tag,
+		Input: this.Input,
+		Start: this.Start,
+		Done:  !this.end.IsZero(),
+		Spans: make([]SpanInfo, len(this.spans)),
+	}
+	if info.Done {
+		info.Duration = int64(this.end.Sub(this.Start))
+	}
+	for i, s := range this.spans {
+		info.Spans[i] = SpanInfo{
+			Stage:    s.Stage,
+			Plugin:   s.Plugin,
+			Offset:   int64(s.Start.Sub(this.Start)),
+			Duration: int64(s.Duration),
+			Result:   s.Result,
+		}
+	}
+	sort.Stable(spanList(info.Spans))
+	return info
+}
+
+type spanList []SpanInfo
+
+func (s spanList) Len() int           { return len(s) }
+func (s spanList) Less(i, j int) bool { return s[i].Offset < s[j].Offset }
+func (s spanList) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+var tracer = struct {
+	// The *TraceFilter, nil while tracing is off.
+	filter atomic.Value
+	mutex  sync.Mutex
+	nextID int64
+	active map[int64]*Trace
+	// Finished traces, the oldest first.
+	done []*Trace
+	// Packs not traced because too many traces were in flight.
+	skipped int64
+}{active: make(map[int64]*Trace)}
+
+func init() {
+	tracer.filter.Store((*TraceFilter)(nil))
+}
+
+// Starts tracing the packs matching the filter, nil stops it. Traces already
+// recorded are kept.
+func SetTraceFilter(filter *TraceFilter) error {
+	if filter != nil {
+		if filter.Sample < 0 || filter.Sample > 1 {
+			return fmt.Errorf("trace sample must be between 0 and 1")
+		}
+		if filter.Sample == 0 && filter.Field == "" {
+			return fmt.Errorf("trace needs a sample or a field")
+		}
+		if filter.Size <= 0 {
+			filter.Size = 100
+		}
+	}
+	tracer.filter.Store(filter)
+	return nil
+}
+
+func CurrentTraceFilter() *TraceFilter {
+	return tracer.filter.Load().(*TraceFilter)
+}
+
+func (this *TraceFilter) match(pack *PipelinePack) bool {
+	if this.Field != "" {
+		// The decoder of another output may be writing the data.
+		pack.Msg.RLock()
+		value, ok := pack.Msg.Data[this.Field]
+		pack.Msg.RUnlock()
+		if !ok {
+			return false
+		}
+		if this.Value != nil && !this.Value.MatchString(fmt.Sprint(value)) {
+			return false
+		}
+	}
+	return this.Sample == 0 || rand.Float64() < this.Sample
+}
+
+// Marks the pack as traced if it matches the filter.
+func startTrace(stage, plugin string, pack *PipelinePack) {
+	filter := CurrentTraceFilter()
+	if filter == nil || !filter.match(pack) {
+		return
+	}
+	tracer.mutex.Lock()
+	defer tracer.mutex.Unlock()
+	if pack.Trace() != nil {
+		return
+	}
+	if len(tracer.active) >= filter.Size {
+		tracer.skipped++
+		return
+	}
+	tracer.nextID++
+	trace := &Trace{
+		ID:    tracer.nextID,
+		Tag:   pack.Msg.Tag,
+		Start: pack.RecvTime,
+	}
+	if stage == "input" {
+		trace.Input = plugin
+	}
+	if trace.Start.IsZero() {
+		trace.Start = time.Now()
+	}
+	tracer.active[trace.ID] = trace
+	pack.trace.Store(trace)
+}
+
+// Called once the pack is recycled by the last holder.
+func finishTrace(trace *Trace) {
+	trace.mutex.Lock()
+	trace.end = time.Now()
+	trace.mutex.Unlock()
+
+	size := 100
+	if filter := CurrentTraceFilter(); filter != nil {
+		size = filter.Size
+	}
+	tracer.mutex.Lock()
+	defer tracer.mutex.Unlock()
+	delete(tracer.active, trace.ID)
+	tracer.done = append(tracer.done, trace)
+	if len(tracer.done) > size {
+		tracer.done = append([]*Trace{}, tracer.done[len(tracer.done)-size:]...)
+	}
+}
+
+// Returns the traces in flight and the finished ones, the latest first.
+func Traces() (active, done []*TraceInfo, skipped int64) {
+	tracer.mutex.Lock()
+	list := make([]*Trace, 0, len(tracer.active))
+	for _, t := range tracer.active {
+		list = append(list, t)
+	}
+	finished := append([]*Trace{}, tracer.done...)
+	skipped = tracer.skipped
+	tracer.mutex.Unlock()
+
+	active = make([]*TraceInfo, 0, len(list))
+	for _, t := range list {
+		active = append(active, t.Info())
+	}
+	sort.Sort(traceList(active))
+	done = make([]*TraceInfo, 0, len(finished))
+	for i := len(finished) - 1; i >= 0; i-- {
+		done = append(done, finished[i].Info())
+	}
+	return active, done, skipped
+}
+
+// Latest first.
+type traceList []*TraceInfo
+
+func (s traceList) Len() int           { return len(s) }
+func (s traceList) Less(i, j int) bool { return s[i].ID > s[j].ID }
+func (s traceList) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// Returns the trace of the pack, nil when it isn't traced.
+func (this *PipelinePack) Trace() *Trace {
+	trace, _ := this.trace.Load().(*Trace)
+	return trace
+}
+
+// Records a span if the pack is traced.
+func (this *PipelinePack) TraceSpan(stage, plugin string, start time.Time, result string) {
+	if trace := this.Trace(); trace != nil {
+		trace.add(Span{
+			Stage:    stage,
+			Plugin:   plugin,
+			Start:    start,
+			Duration: time.Since(start),
+			Result:   result,
+		})
+	}
+}
+
+// Records the result of an output sending the pack, started at start.
+func (this *PipelinePack) TraceSend(output string, start time.Time, err error) {
+	this.TraceSpan("send", output, start, traceResult(err))
+}
+
+func traceResult(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return "ok"
+}
@@ -3,11 +3,10 @@ package udp
 import (
 	"bytes"
 	"fmt"
-	"log"
 	"net"
 	"time"
 
-	"github.com/millken/kaman/metrics"
+	"github.com/millken/kaman/logger"
 	"github.com/millken/kaman/plugins"
 
 	"github.com/bbangert/toml"
@@ -24,6 +23,7 @@ type UdpInput struct {
 	stopChan chan bool
 	config   *UdpInputConfig
 	common   *plugins.PluginCommonConfig
+	log      *logger.Logger
 	runner   plugins.InputRunner
 	inChan   chan UdpPack
 	buffer   bytes.Buffer
@@ -32,19 +32,24 @@ type UdpInput struct {
 type UdpInputConfig struct {
 	// Network type (e.g. "tcp", "tcp4", "tcp6", "unix" or "unixpacket").
 	// Needs to match the input type.
-	Net string
+	Net string `desc:"network type"`
 	// String representation of the address of the network connection on which
 	// the listener should be listening (e.g. "127.0.0.1:5565").
-	Address string
+	Address string `desc:"listen address, e.g. 127.0.0.1:5565"`
+}
+
+func (self *UdpInput) ConfigStruct() interface{} {
+	return &UdpInputConfig{
+		Net: "udp4",
+	}
 }
 
 func (self *UdpInput) Init(pcf *plugins.PluginCommonConfig, conf toml.Primitive) (err error) {
 
-	log.Println("UdpInput Init")
 	self.common = pcf
-	self.config = &UdpInputConfig{
-		Net: "udp4",
-	}
+	self.log = pcf.Logger()
+	self.log.Info("UdpInput Init")
+	self.config = self.ConfigStruct().(*UdpInputConfig)
 	if err := toml.PrimitiveDecode(conf, self.config); err != nil {
 		return fmt.Errorf("Can't unmarshal UdpInput config: %s", err)
 	}
@@ -76,8 +81,6 @@ func (self *UdpInput) Run(runner plugins.InputRunner) error {
 	self.runner = runner
 	buf := make([]byte, UDP_PACKET_SIZE)
 	stopped := false
-	counter := fmt.Sprintf("Tag:%s,Type:%s", self.common.Tag, self.common.Type)
-	mc := metrics.NewCounter(counter)
 	msgbytes := make([]byte, 0, 10000)
 
 	for !stopped {
@@ -87,7 +90,11 @@ func (self *UdpInput) Run(runner plugins.InputRunner) error {
 		default:
 			n, _, err := self.listener.ReadFromUDP(buf)
 			if err != nil {
-				log.Printf("read from udp err : %s", err)
+				select {
+				case <-self.stopChan:
+				default:
+					self.log.Error("read from udp", "err", err)
+				}
 				continue
 			}
 			//log.Printf("get %d from %s: %s", n, addr, buf[0:n])
@@ -106,7 +113,6 @@ func (self *UdpInput) Run(runner plugins.InputRunner) error {
 					pack.MsgBytes = bytes.TrimSpace(msgbytes)
 					pack.Msg.Tag = self.common.Tag
 					pack.Msg.Timestamp = time.Now().Unix()
-					mc.Add(1)
 					msgbytes = msgbytes[:0]
 
 					self.runner.RouterChan() <- pack
@@ -118,6 +124,11 @@ func (self *UdpInput) Run(runner plugins.InputRunner) error {
 	return e
 }
 
+func (self *UdpInput) Stop() {
+	close(self.stopChan)
+	self.listener.Close()
+}
+
 func init() {
 	plugins.RegisterInput("UdpInput", func() interface{} {
 		return new(UdpInput)
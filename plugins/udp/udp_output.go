@@ -3,10 +3,11 @@ package udp
 import (
 	"errors"
 	"fmt"
-	"log"
 	"net"
 	"runtime"
+	"time"
 
+	"github.com/millken/kaman/logger"
 	"github.com/millken/kaman/plugins"
 
 	"github.com/bbangert/toml"
@@ -16,29 +17,37 @@ import (
 type UdpOutputConfig struct {
 	// Network type ("udp", "udp4", "udp6", or "unixgram"). Needs to match the
 	// input type.
-	Net string
+	Net string `desc:"network type, udp, udp4, udp6 or unixgram"`
 	// String representation of the address of the network connection to which
 	// we will be sending out packets (e.g. "192.168.64.48:3336").
-	Address string
+	Address string `desc:"destination address"`
 	// Optional address to use as the local address for the connection.
-	LocalAddress string `toml:"local_address"`
+	LocalAddress string `toml:"local_address" desc:"optional local address"`
 	// Maximum size of message, plugin drops the data if it exceeds this limit.
-	MaxMessageSize int `toml:"max_message_size"`
+	MaxMessageSize int `toml:"max_message_size" desc:"messages larger than this are dropped"`
 }
 
 type UdpOutput struct {
+	common *plugins.PluginCommonConfig
 	config *UdpOutputConfig
+	log    *logger.Logger
 	conn   net.Conn
 }
 
-func (self *UdpOutput) Init(pcf *plugins.PluginCommonConfig, conf toml.Primitive) error {
-	var err error
-	log.Println("UdpOutput Init.")
-	self.config = &UdpOutputConfig{
+func (self *UdpOutput) ConfigStruct() interface{} {
+	return &UdpOutputConfig{
 		Net: "udp",
 		// Defines maximum size of udp data for IPv4.
 		MaxMessageSize: 65507,
 	}
+}
+
+func (self *UdpOutput) Init(pcf *plugins.PluginCommonConfig, conf toml.Primitive) error {
+	var err error
+	self.common = pcf
+	self.log = pcf.Logger()
+	self.log.Info("UdpOutput Init.")
+	self.config = self.ConfigStruct().(*UdpOutputConfig)
 	if err := toml.PrimitiveDecode(conf, self.config); err != nil {
 		return fmt.Errorf("Can't unmarshal UdpOutput config: %s", err)
 	}
@@ -96,11 +105,14 @@ func (self *UdpOutput) Run(runner plugins.OutputRunner) error {
 	for pack := range runner.InChan() {
 		outBytes = pack.MsgBytes
 		msgSize := len(outBytes)
+		start := time.Now()
 		if msgSize > self.config.MaxMessageSize {
 			e = fmt.Errorf("Message has exceeded allowed UDP data size: %d > %d",
 				msgSize, self.config.MaxMessageSize)
+			pack.TraceSend(self.common.Name, start, e)
 		} else {
-			self.conn.Write(outBytes)
+			_, err := self.conn.Write(outBytes)
+			pack.TraceSend(self.common.Name, start, err)
 		}
 		pack.Recycle()
 	}
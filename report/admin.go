@@ -0,0 +1,129 @@
+package report
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/millken/kaman/logger"
+	"github.com/millken/kaman/plugins"
+)
+
+const defaultDrainTimeout = 30 * time.Second
+
+// Reports whether the request carries the admin token, either as
+// `Authorization: Bearer <token>` or in the X-Kaman-Token header. The header
+// wins, Authorization may hold the report server credentials.
+func (srv *Server) authorized(r *http.Request) bool {
+	token := r.Header.Get("X-Kaman-Token")
+	if auth := r.Header.Get("Authorization"); token == "" && strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return srv.validToken(token)
+}
+
+func (srv *Server) validToken(token string) bool {
+	return srv.AdminToken != "" &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(srv.AdminToken)) == 1
+}
+
+// adminHandler serves the runtime control endpoints under /admin/. They are
+// disabled unless the server has an AdminToken.
+type adminHandler struct {
+	srv *Server
+}
+
+func NewAdmin(srv *Server) *adminHandler {
+	return &adminHandler{srv: srv}
+}
+
+func (this *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+	if this.srv.AdminToken == "" {
+		http.Error(w, "Admin API disabled, no admin token configured.", http.StatusForbidden)
+		return
+	}
+	if !this.srv.authorized(r) {
+		logger.Warn("admin request denied", "path", r.URL.Path, "remote", r.RemoteAddr)
+		http.Error(w, "Invalid admin token.", http.StatusUnauthorized)
+		return
+	}
+	pipeline := plugins.RunningPipeline()
+	if pipeline == nil {
+		http.Error(w, "Pipeline not running.", http.StatusServiceUnavailable)
+		return
+	}
+
+	action := strings.TrimPrefix(r.URL.Path, "/admin/")
+	if action == "routes" && r.Method == "GET" {
+		w.Header().Set("Content-Type", "application/json")
+		writeJsonResponse(w, pipeline.Routes(), nil)
+		return
+	}
+	if r.Method != "POST" && !((action == "routes" || action == "trace") && r.Method == "DELETE") {
+		http.Error(w, "Method not allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var err error
+	kv := []interface{}{"action", action, "remote", r.RemoteAddr}
+	switch action {
+	case "pause":
+		block := r.FormValue("block") == "true"
+		kv = append(kv, "output", r.FormValue("output"), "block", block)
+		err = pipeline.PauseOutput(r.FormValue("output"), block)
+	case "resume":
+		kv = append(kv, "output", r.FormValue("output"))
+		err = pipeline.ResumeOutput(r.FormValue("output"))
+	case "drain":
+		timeout := defaultDrainTimeout
+		if t := r.FormValue("timeout"); t != "" {
+			if timeout, err = time.ParseDuration(t); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		kv = append(kv, "input", r.FormValue("input"))
+		logger.Info("admin action started", kv...)
+		err = pipeline.DrainInput(r.FormValue("input"), timeout)
+	case "routes":
+		kv = append(kv, "tag", r.FormValue("tag"), "output", r.FormValue("output"))
+		if r.Method == "DELETE" {
+			kv[1] = "remove route"
+			err = pipeline.RemoveRoute(r.FormValue("tag"), r.FormValue("output"))
+		} else {
+			kv[1] = "add route"
+			err = pipeline.AddRoute(r.FormValue("tag"), r.FormValue("output"))
+		}
+	case "trace":
+		if r.Method == "DELETE" {
+			kv[1] = "stop trace"
+			err = plugins.SetTraceFilter(nil)
+			break
+		}
+		filter, perr := parseTraceFilter(r)
+		if perr != nil {
+			http.Error(w, perr.Error(), http.StatusBadRequest)
+			return
+		}
+		kv = append(kv, "sample", filter.Sample, "field", filter.Field, "value", r.FormValue("value"))
+		err = plugins.SetTraceFilter(filter)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		logger.Warn("admin action failed", append(kv, "err", err)...)
+		status := http.StatusConflict
+		if err == plugins.ErrNotStoppable {
+			status = http.StatusNotImplemented
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	logger.Info("admin action", kv...)
+	w.Header().Set("Content-Type", "application/json")
+	writeJsonResponse(w, pipeline.Status(), nil)
+}
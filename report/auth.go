@@ -0,0 +1,113 @@
+package report
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/millken/kaman/logger"
+)
+
+// Probes must keep working without credentials.
+var unauthenticated = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+func (srv *Server) checkAuth() error {
+	if (srv.AuthUser == "") != (srv.AuthPassword == "") {
+		return fmt.Errorf("report auth needs both a user and a password")
+	}
+	if srv.AuthUser != "" && srv.AuthToken != "" {
+		return fmt.Errorf("report auth is either basic or bearer, not both")
+	}
+	return nil
+}
+
+// Reports whether the request carries the report server credentials, the
+// basic auth user and password or the bearer token.
+func (srv *Server) authenticated(r *http.Request) bool {
+	if srv.AuthUser != "" {
+		user, password, ok := r.BasicAuth()
+		return ok &&
+			subtle.ConstantTimeCompare([]byte(user), []byte(srv.AuthUser)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(password), []byte(srv.AuthPassword)) == 1
+	}
+	if srv.AuthToken != "" {
+		auth := r.Header.Get("Authorization")
+		return strings.HasPrefix(auth, "Bearer ") &&
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(srv.AuthToken)) == 1
+	}
+	return true
+}
+
+// authHandler rejects the requests without the report server credentials.
+type authHandler struct {
+	srv     *Server
+	handler http.Handler
+}
+
+func (this *authHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !unauthenticated[r.URL.Path] && !this.srv.authenticated(r) {
+		logger.Warn("report request denied", "path", r.URL.Path, "remote", r.RemoteAddr)
+		if this.srv.AuthUser != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="kaman"`)
+		} else {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="kaman"`)
+		}
+		http.Error(w, "Unauthorized.", http.StatusUnauthorized)
+		return
+	}
+	this.handler.ServeHTTP(w, r)
+}
+
+// Returns nil when the server has no certificate and serves plain HTTP. With
+// a client CA, clients must present a certificate signed by it.
+func (srv *Server) tlsConfig() (*tls.Config, error) {
+	if srv.TLSCert == "" && srv.TLSKey == "" {
+		if srv.TLSClientCA != "" {
+			return nil, fmt.Errorf("report tls_client_ca needs tls_cert and tls_key")
+		}
+		return nil, nil
+	}
+	if srv.TLSCert == "" || srv.TLSKey == "" {
+		return nil, fmt.Errorf("report TLS needs both tls_cert and tls_key")
+	}
+	cert, err := tls.LoadX509KeyPair(srv.TLSCert, srv.TLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("Can't load report certificate: %s", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if srv.TLSClientCA != "" {
+		pem, err := ioutil.ReadFile(srv.TLSClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("Can't read report client CA: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificate found in %s", srv.TLSClientCA)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// Serves the profiles of net/http/pprof under /debug/pprof/.
+func NewPprof() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
@@ -0,0 +1,85 @@
+package report
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/millken/kaman/logger"
+)
+
+func TestAuthHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	basic := &Server{AuthUser: "kaman", AuthPassword: "secret"}
+	bearer := &Server{AuthToken: "t0ken"}
+
+	for _, c := range []struct {
+		srv    *Server
+		path   string
+		user   string
+		pass   string
+		bearer string
+		code   int
+	}{
+		{basic, "/stats", "", "", "", http.StatusUnauthorized},
+		{basic, "/stats", "kaman", "wrong", "", http.StatusUnauthorized},
+		{basic, "/stats", "kaman", "secret", "", http.StatusOK},
+		{basic, "/healthz", "", "", "", http.StatusOK},
+		{bearer, "/metrics", "", "", "", http.StatusUnauthorized},
+		{bearer, "/metrics", "", "", "wrong", http.StatusUnauthorized},
+		{bearer, "/metrics", "", "", "t0ken", http.StatusOK},
+		{bearer, "/readyz", "", "", "", http.StatusOK},
+		{&Server{}, "/stats", "", "", "", http.StatusOK},
+	} {
+		r, _ := http.NewRequest("GET", "http://localhost"+c.path, nil)
+		if c.user != "" {
+			r.SetBasicAuth(c.user, c.pass)
+		}
+		if c.bearer != "" {
+			r.Header.Set("Authorization", "Bearer "+c.bearer)
+		}
+		w := httptest.NewRecorder()
+		(&authHandler{srv: c.srv, handler: ok}).ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("%s %s/%s/%s: got %d, want %d", c.path, c.user, c.pass, c.bearer, w.Code, c.code)
+		}
+	}
+
+	if err := (&Server{AuthUser: "kaman"}).checkAuth(); err == nil {
+		t.Error("user without password accepted")
+	}
+	if err := (&Server{AuthUser: "a", AuthPassword: "b", AuthToken: "c"}).checkAuth(); err == nil {
+		t.Error("basic and bearer auth accepted together")
+	}
+}
+
+func TestLogLevelToken(t *testing.T) {
+	level := logger.GetLevel()
+	defer logger.SetLevel(level)
+	for _, c := range []struct {
+		srv   *Server
+		token string
+		code  int
+	}{
+		{&Server{}, "", http.StatusForbidden},
+		{&Server{AdminToken: "secret"}, "", http.StatusUnauthorized},
+		{&Server{AdminToken: "secret"}, "wrong", http.StatusUnauthorized},
+		{&Server{AdminToken: "secret"}, "secret", http.StatusOK},
+	} {
+		r, _ := http.NewRequest("POST", "http://localhost/loglevel?level=debug", nil)
+		if c.token != "" {
+			r.Header.Set("X-Kaman-Token", c.token)
+		}
+		w := httptest.NewRecorder()
+		NewLogLevel(c.srv).ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("token %q: got %d, want %d", c.token, w.Code, c.code)
+		}
+	}
+	r, _ := http.NewRequest("GET", "http://localhost/loglevel", nil)
+	w := httptest.NewRecorder()
+	NewLogLevel(&Server{}).ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("GET: got %d", w.Code)
+	}
+}
@@ -0,0 +1,264 @@
+package report
+
+import (
+	"html/template"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/millken/kaman/logger"
+	"github.com/millken/kaman/metrics"
+	"github.com/millken/kaman/plugins"
+)
+
+// Set by main to the kaman version.
+var Version string
+
+var started = time.Now()
+
+// What the dashboard shows, rendered into the page and sent over /ws every
+// second.
+type dashboard struct {
+	Version  string             `json:"version"`
+	Platform string             `json:"platform"`
+	Uptime   string             `json:"uptime"`
+	Router   dashboardRouter    `json:"router"`
+	Plugins  []*dashboardPlugin `json:"plugins"`
+	Memory   dashboardMemory    `json:"memory"`
+}
+
+type dashboardRouter struct {
+	Rate1   float64 `json:"rate1"`
+	ChanLen int64   `json:"chan_len"`
+	ChanCap int64   `json:"chan_cap"`
+}
+
+type dashboardPlugin struct {
+	Name     string  `json:"name"`
+	Category string  `json:"category"`
+	Type     string  `json:"type"`
+	State    string  `json:"state"`
+	Count    int64   `json:"count"`
+	Rate1    float64 `json:"rate1"`
+	Rate5    float64 `json:"rate5"`
+	Rate15   float64 `json:"rate15"`
+	Errors   int64   `json:"errors"`
+	ChanLen  int     `json:"chan_len"`
+	ChanCap  int     `json:"chan_cap"`
+}
+
+type dashboardMemory struct {
+	Alloc      uint64 `json:"alloc"`
+	Sys        uint64 `json:"sys"`
+	HeapAlloc  uint64 `json:"heap_alloc"`
+	Objects    uint64 `json:"heap_objects"`
+	NumGC      uint32 `json:"num_gc"`
+	Goroutines int    `json:"goroutines"`
+}
+
+// The throughput and error metrics shown for a plugin, by category.
+var dashboardMetrics = map[string]struct{ messages, errors string }{
+	"Input":   {"messages_out", ""},
+	"Output":  {"messages_in", "drops"},
+	"Decoder": {"messages_in", "decode_errors"},
+	"Encoder": {"messages_in", "encode_errors"},
+}
+
+func newDashboard() *dashboard {
+	d := &dashboard{
+		Version:  Version,
+		Platform: runtime.GOOS + "/" + runtime.GOARCH + " " + runtime.Version(),
+		Uptime:   (time.Since(started) / time.Second * time.Second).String(),
+		Plugins:  []*dashboardPlugin{},
+	}
+
+	if m, ok := metrics.DefaultRegistry.Get("router.messages").(*metrics.Meter); ok {
+		d.Router.Rate1 = m.Snapshot().Rate1
+	}
+	if g, ok := metrics.DefaultRegistry.Get("router.chan_len").(*metrics.GaugeFunc); ok {
+		d.Router.ChanLen = g.Value()
+	}
+	if g, ok := metrics.DefaultRegistry.Get("router.chan_cap").(*metrics.Gauge); ok {
+		d.Router.ChanCap = g.Value()
+	}
+
+	if pipeline := plugins.RunningPipeline(); pipeline != nil {
+		status := pipeline.Status()
+		for _, list := range [][]*plugins.PluginStatus{status.Inputs, status.Outputs, status.Decoders, status.Encoders} {
+			for _, s := range list {
+				d.Plugins = append(d.Plugins, newDashboardPlugin(s))
+			}
+		}
+	}
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	d.Memory = dashboardMemory{
+		Alloc:      ms.Alloc,
+		Sys:        ms.Sys,
+		HeapAlloc:  ms.HeapAlloc,
+		Objects:    ms.HeapObjects,
+		NumGC:      ms.NumGC,
+		Goroutines: runtime.NumGoroutine(),
+	}
+	return d
+}
+
+func newDashboardPlugin(s *plugins.PluginStatus) *dashboardPlugin {
+	p := &dashboardPlugin{
+		Name:     s.Name,
+		Category: s.Category,
+		Type:     s.Type,
+		State:    s.State,
+	}
+	if s.Chan != nil {
+		p.ChanLen, p.ChanCap = s.Chan.Len, s.Chan.Cap
+		if s.Category == "Input" {
+			// Show the packs in flight rather than the free ones.
+			p.ChanLen = s.Chan.Cap - s.Chan.Len
+		}
+	}
+	names := dashboardMetrics[s.Category]
+	registry := metrics.PluginRegistry(s.Name, s.Type, s.Tag)
+	if m, ok := registry.Get(names.messages).(*metrics.Meter); ok {
+		snap := m.Snapshot()
+		p.Count, p.Rate1, p.Rate5, p.Rate15 = snap.Count, snap.Rate1, snap.Rate5, snap.Rate15
+	}
+	if c, ok := registry.Get(names.errors).(*metrics.Counter); ok {
+		p.Errors = c.Value()
+	}
+	return p
+}
+
+var dashboardTmpl = template.Must(template.New("dashboard").Parse(dashboardTemplate))
+
+// dashboardHandler serves the dashboard page on /.
+type dashboardHandler int
+
+func NewDashboard() *dashboardHandler {
+	return new(dashboardHandler)
+}
+
+func (this *dashboardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTmpl.Execute(w, newDashboard()); err != nil {
+		logger.Error("dashboard template", "err", err)
+	}
+}
+
+const dashboardTemplate = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>kaman {{.Version}}</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; margin: 20px; color: #333; }
+h1 { font-size: 22px; } h2 { font-size: 17px; margin-top: 24px; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: right; }
+th { background: #eef; } td.l, th.l { text-align: left; }
+.running { color: #080; } .paused, .draining { color: #b60; } .stopped, .starting { color: #b00; }
+#conn { color: #888; font-size: 12px; }
+svg.spark { width: 120px; height: 18px; } svg.spark polyline { fill: none; stroke: #36c; }
+</style></head>
+<body>
+<h1>kaman {{.Version}} <span id="conn"></span></h1>
+<table>
+<tr><th class="l">Uptime</th><td class="l" id="uptime">{{.Uptime}}</td></tr>
+<tr><th class="l">Platform</th><td class="l">{{.Platform}}</td></tr>
+<tr><th class="l">Router</th><td class="l" id="router">{{printf "%.2f" .Router.Rate1}} msg/s, queue {{.Router.ChanLen}}/{{.Router.ChanCap}}</td></tr>
+</table>
+
+<h2>Plugins</h2>
+<table>
+<thead><tr><th class="l">Name</th><th class="l">Category</th><th class="l">Type</th><th class="l">State</th>
+<th>Messages</th><th>1 min</th><th>5 min</th><th>15 min</th><th>Errors/drops</th><th>Queue</th><th>Last hour</th></tr></thead>
+<tbody id="plugins">
+{{range .Plugins}}<tr><td class="l">{{.Name}}</td><td class="l">{{.Category}}</td><td class="l">{{.Type}}</td>
+<td class="l {{.State}}">{{.State}}</td><td>{{.Count}}</td><td>{{printf "%.2f" .Rate1}}</td><td>{{printf "%.2f" .Rate5}}</td>
+<td>{{printf "%.2f" .Rate15}}</td><td>{{.Errors}}</td><td>{{if .ChanCap}}{{.ChanLen}}/{{.ChanCap}}{{end}}</td><td></td></tr>
+{{end}}</tbody>
+</table>
+
+<h2>Memory</h2>
+<table>
+<tr><th class="l">Allocated</th><td id="m-alloc">{{.Memory.Alloc}}</td></tr>
+<tr><th class="l">Heap</th><td id="m-heap_alloc">{{.Memory.HeapAlloc}}</td></tr>
+<tr><th class="l">Heap objects</th><td id="m-heap_objects">{{.Memory.Objects}}</td></tr>
+<tr><th class="l">From the OS</th><td id="m-sys">{{.Memory.Sys}}</td></tr>
+<tr><th class="l">GC runs</th><td id="m-num_gc">{{.Memory.NumGC}}</td></tr>
+<tr><th class="l">Goroutines</th><td id="m-goroutines">{{.Memory.Goroutines}}</td></tr>
+</table>
+
+<script>
+(function() {
+	function cell(text, cls) {
+		var td = document.createElement("td");
+		td.className = cls || "";
+		td.textContent = text;
+		return td;
+	}
+	// Rates of the last hour from /history, by plugin and metric.
+	var history = {};
+	function sparkline(values) {
+		var td = cell("");
+		if (!values || values.length < 2) return td;
+		var max = Math.max.apply(null, values) || 1, points = [];
+		values.forEach(function(v, i) {
+			points.push((i * 120 / (values.length - 1)).toFixed(1) + "," + (17 - v * 16 / max).toFixed(1));
+		});
+		td.innerHTML = '<svg class="spark"><polyline points="' + points.join(" ") + '"/></svg>';
+		td.title = "max " + max.toFixed(2) + " msg/s";
+		return td;
+	}
+	function loadHistory() {
+		var req = new XMLHttpRequest();
+		req.open("GET", "/history?since=1h");
+		req.onload = function() {
+			if (req.status != 200) return;
+			history = {};
+			JSON.parse(req.responseText).forEach(function(s) {
+				history[s.plugin + "/" + s.metric] = s.values;
+			});
+		};
+		req.send();
+	}
+	function update(d) {
+		document.getElementById("uptime").textContent = d.uptime;
+		document.getElementById("router").textContent = d.router.rate1.toFixed(2) +
+			" msg/s, queue " + d.router.chan_len + "/" + d.router.chan_cap;
+		var body = document.getElementById("plugins");
+		while (body.firstChild) body.removeChild(body.firstChild);
+		d.plugins.forEach(function(p) {
+			var tr = document.createElement("tr");
+			[cell(p.name, "l"), cell(p.category, "l"), cell(p.type, "l"), cell(p.state, "l " + p.state),
+			 cell(p.count), cell(p.rate1.toFixed(2)), cell(p.rate5.toFixed(2)), cell(p.rate15.toFixed(2)),
+			 cell(p.errors), cell(p.chan_cap ? p.chan_len + "/" + p.chan_cap : ""),
+			 sparkline(history[p.name + "/" + (p.category == "Input" ? "messages_out" : "messages_in")])].forEach(function(td) {
+				tr.appendChild(td);
+			});
+			body.appendChild(tr);
+		});
+		for (var k in d.memory) {
+			var el = document.getElementById("m-" + k);
+			if (el) el.textContent = d.memory[k];
+		}
+	}
+	function connect() {
+		var ws = new WebSocket((location.protocol == "https:" ? "wss://" : "ws://") + location.host + "/ws");
+		ws.onopen = function() { document.getElementById("conn").textContent = "live"; };
+		ws.onmessage = function(e) { update(JSON.parse(e.data)); };
+		ws.onclose = function() {
+			document.getElementById("conn").textContent = "disconnected";
+			setTimeout(connect, 5000);
+		};
+	}
+	connect();
+	loadHistory();
+	setInterval(loadHistory, 10000);
+})();
+</script>
+</body></html>
+`
@@ -0,0 +1,54 @@
+package report
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/millken/kaman/plugins"
+)
+
+// Default fraction of a queue's capacity above which kaman isn't ready.
+const defaultQueueThreshold = 0.9
+
+// healthHandler serves /healthz, or /readyz when ready is set. Both answer
+// 200 or 503 with the checks as JSON.
+type healthHandler struct {
+	ready bool
+}
+
+func NewHealth(ready bool) *healthHandler {
+	return &healthHandler{ready: ready}
+}
+
+func (this *healthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+	var report *plugins.HealthReport
+	pipeline := plugins.RunningPipeline()
+	switch {
+	case pipeline == nil && this.ready:
+		report = &plugins.HealthReport{Checks: []*plugins.HealthCheck{
+			{Name: "pipeline", Error: "not running"},
+		}}
+	case pipeline == nil:
+		// Alive, still loading the config.
+		report = &plugins.HealthReport{OK: true, Checks: []*plugins.HealthCheck{}}
+	case this.ready:
+		threshold := defaultQueueThreshold
+		if t := r.FormValue("queue_threshold"); t != "" {
+			var err error
+			if threshold, err = strconv.ParseFloat(t, 64); err != nil || threshold <= 0 {
+				http.Error(w, "Invalid queue_threshold.", http.StatusBadRequest)
+				return
+			}
+		}
+		report = pipeline.Ready(threshold)
+	default:
+		report = pipeline.Health()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !report.OK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	writeJsonResponse(w, report, nil)
+}
@@ -0,0 +1,199 @@
+package report
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/millken/kaman/metrics"
+)
+
+// History keeps the per second rates of the meters and counters of every
+// registry in a ring buffer, one sample every Resolution.
+type History struct {
+	Resolution time.Duration
+	mutex      sync.RWMutex
+	// Unix seconds of the samples, next is where the next one goes.
+	times  []int64
+	next   int
+	series map[string]*historySeries
+	// Metric values at the previous sample, by series key. Once primed,
+	// metrics missing from it are new and counted from 0.
+	last   map[string]int64
+	primed bool
+}
+
+type historySeries struct {
+	plugin, metric string
+	values         []float64
+}
+
+// A series of evenly spaced samples, Values[i] is the rate at
+// Start + i*Step.
+type HistorySeries struct {
+	Plugin string    `json:"plugin"`
+	Metric string    `json:"metric"`
+	Start  int64     `json:"start"`
+	Step   int64     `json:"step"`
+	Values []float64 `json:"values"`
+}
+
+func NewHistory(resolution time.Duration, size int) *History {
+	return &History{
+		Resolution: resolution,
+		times:      make([]int64, size),
+		series:     make(map[string]*historySeries),
+		last:       make(map[string]int64),
+	}
+}
+
+func (this *History) Run(stop chan struct{}) {
+	this.mutex.Lock()
+	this.last = metricCounts()
+	this.primed = true
+	this.mutex.Unlock()
+
+	ticker := time.NewTicker(this.Resolution)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			this.sample(now)
+		}
+	}
+}
+
+// Returns the meter and counter values of every registry, by series key.
+func metricCounts() map[string]int64 {
+	counts := make(map[string]int64)
+	record := func(r *metrics.Registry) {
+		r.Each(func(name string, metric interface{}) {
+			switch m := metric.(type) {
+			case *metrics.Meter:
+				counts[r.Name+"\x00"+name] = m.Count()
+			case *metrics.Counter:
+				counts[r.Name+"\x00"+name] = m.Value()
+			}
+		})
+	}
+	record(metrics.DefaultRegistry)
+	for _, r := range metrics.Registries() {
+		record(r)
+	}
+	return counts
+}
+
+func (this *History) sample(now time.Time) {
+	counts := metricCounts()
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	i := this.next
+	this.times[i] = now.Unix()
+	this.next = (i + 1) % len(this.times)
+	for key, count := range counts {
+		s, ok := this.series[key]
+		if !ok {
+			// Zero until the metric existed.
+			j := strings.Index(key, "\x00")
+			s = &historySeries{
+				plugin: key[:j],
+				metric: key[j+1:],
+				values: make([]float64, len(this.times)),
+			}
+			this.series[key] = s
+		}
+		last, seen := this.last[key]
+		rate := 0.0
+		if (seen || this.primed) && count >= last {
+			rate = float64(count-last) / this.Resolution.Seconds()
+		}
+		s.values[i] = rate
+		this.last[key] = count
+	}
+}
+
+// Returns the samples taken since the time, of the series matching plugin and
+// metric, an empty string matches all.
+func (this *History) Query(plugin, metric string, since time.Time) []*HistorySeries {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+
+	// The ring positions of the samples, oldest first.
+	size := len(this.times)
+	index := make([]int, 0, size)
+	for n := 0; n < size; n++ {
+		i := (this.next + n) % size
+		if this.times[i] != 0 && this.times[i] >= since.Unix() {
+			index = append(index, i)
+		}
+	}
+
+	list := make(historyList, 0)
+	for _, s := range this.series {
+		if (plugin != "" && s.plugin != plugin) || (metric != "" && s.metric != metric) {
+			continue
+		}
+		hs := &HistorySeries{
+			Plugin: s.plugin,
+			Metric: s.metric,
+			Step:   int64(this.Resolution / time.Second),
+			Values: make([]float64, len(index)),
+		}
+		if len(index) > 0 {
+			hs.Start = this.times[index[0]]
+		}
+		for j, i := range index {
+			hs.Values[j] = s.values[i]
+		}
+		list = append(list, hs)
+	}
+	sort.Sort(list)
+	return list
+}
+
+// Sorts by plugin, then metric.
+type historyList []*HistorySeries
+
+func (s historyList) Len() int      { return len(s) }
+func (s historyList) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s historyList) Less(i, j int) bool {
+	if s[i].Plugin != s[j].Plugin {
+		return s[i].Plugin < s[j].Plugin
+	}
+	return s[i].Metric < s[j].Metric
+}
+
+// historyHandler serves the history as JSON on /history.
+type historyHandler struct {
+	srv *Server
+}
+
+func NewHistoryHandler(srv *Server) *historyHandler {
+	return &historyHandler{srv: srv}
+}
+
+func (this *historyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	history := this.srv.history
+	if history == nil {
+		http.Error(w, "History disabled.", http.StatusNotFound)
+		return
+	}
+	q := r.URL.Query()
+	since := time.Time{}
+	if s := q.Get("since"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			http.Error(w, "Invalid since: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		since = time.Now().Add(-d)
+	}
+	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+	w.Header().Set("Content-Type", "application/json")
+	writeJsonResponse(w, history.Query(q.Get("plugin"), q.Get("metric"), since), nil)
+}
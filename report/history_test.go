@@ -0,0 +1,40 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/millken/kaman/metrics"
+)
+
+func TestHistory(t *testing.T) {
+	m := metrics.PluginRegistry("hist1", "TcpInput", "t").Meter("messages_out")
+	h := NewHistory(10*time.Second, 3)
+	now := time.Unix(1000, 0)
+	for i := 0; i < 5; i++ {
+		m.Mark(int64(i * 10))
+		h.sample(now.Add(time.Duration(i) * 10 * time.Second))
+	}
+
+	list := h.Query("hist1", "messages_out", time.Time{})
+	if len(list) != 1 {
+		t.Fatalf("got %d series", len(list))
+	}
+	s := list[0]
+	// Only the last three samples are kept, the first one had no rate.
+	if s.Start != 1020 || s.Step != 10 || len(s.Values) != 3 {
+		t.Fatalf("got %+v", s)
+	}
+	for i, want := range []float64{2, 3, 4} {
+		if s.Values[i] != want {
+			t.Errorf("value %d: got %v, want %v", i, s.Values[i], want)
+		}
+	}
+
+	if list = h.Query("hist1", "messages_out", time.Unix(1035, 0)); len(list[0].Values) != 1 || list[0].Start != 1040 {
+		t.Errorf("since: got %+v", list[0])
+	}
+	if list = h.Query("nope", "", time.Time{}); len(list) != 0 {
+		t.Errorf("got %d series for an unknown plugin", len(list))
+	}
+}
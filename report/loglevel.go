@@ -0,0 +1,43 @@
+package report
+
+import (
+	"net/http"
+
+	"github.com/millken/kaman/logger"
+)
+
+// logLevelHandler shows the diagnostics log level, a POST or PUT with a
+// `level` parameter changes it. Changes need the admin token and are
+// disabled without one.
+type logLevelHandler struct {
+	srv *Server
+}
+
+func NewLogLevel(srv *Server) *logLevelHandler {
+	return &logLevelHandler{srv: srv}
+}
+
+func (this *logLevelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+	if r.Method == "POST" || r.Method == "PUT" {
+		if this.srv.AdminToken == "" {
+			http.Error(w, "Log level changes disabled, no admin token configured.", http.StatusForbidden)
+			return
+		}
+		if !this.srv.authorized(r) {
+			logger.Warn("log level change denied", "remote", r.RemoteAddr)
+			http.Error(w, "Invalid admin token.", http.StatusUnauthorized)
+			return
+		}
+		level, err := logger.ParseLevel(r.FormValue("level"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.Info("log level changed", "from", logger.GetLevel(), "to", level,
+			"remote", r.RemoteAddr)
+		logger.SetLevel(level)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJsonResponse(w, map[string]string{"level": logger.GetLevel().String()}, nil)
+}
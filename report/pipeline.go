@@ -0,0 +1,25 @@
+package report
+
+import (
+	"net/http"
+
+	"github.com/millken/kaman/plugins"
+)
+
+// pipelineHandler serves the topology of the running pipeline.
+type pipelineHandler int
+
+func NewPipeline() *pipelineHandler {
+	return new(pipelineHandler)
+}
+
+func (this *pipelineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+	pipeline := plugins.RunningPipeline()
+	if pipeline == nil {
+		http.Error(w, "Pipeline not running.", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJsonResponse(w, pipeline.Status(), nil)
+}
@@ -0,0 +1,258 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/millken/kaman/metrics"
+)
+
+const prometheusNamespace = "kaman"
+
+// prometheusHandler serves the metrics registries and the Go runtime stats in
+// the Prometheus text exposition format.
+type prometheusHandler int
+
+func NewPrometheus() *prometheusHandler {
+	return new(prometheusHandler)
+}
+
+// All samples of one metric name, across registries.
+type promFamily struct {
+	name    string
+	kind    string
+	help    string
+	samples []string
+}
+
+type promWriter struct {
+	families map[string]*promFamily
+}
+
+func newPromWriter() *promWriter {
+	return &promWriter{families: make(map[string]*promFamily)}
+}
+
+// Adds a sample to the family `name`. Samples whose type conflicts with the
+// one the family was first seen with are dropped, Prometheus rejects mixed
+// families.
+func (pw *promWriter) add(name, kind, help, suffix string, labels []string, value float64) {
+	family, ok := pw.families[name]
+	if !ok {
+		family = &promFamily{name: name, kind: kind, help: help}
+		pw.families[name] = family
+	} else if family.kind != kind {
+		return
+	}
+	var buf bytes.Buffer
+	buf.WriteString(name)
+	buf.WriteString(suffix)
+	if len(labels) > 0 {
+		buf.WriteByte('{')
+		for i := 0; i < len(labels); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(labels[i])
+			buf.WriteString(`="`)
+			buf.WriteString(escapeLabelValue(labels[i+1]))
+			buf.WriteByte('"')
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(formatPromValue(value))
+	family.samples = append(family.samples, buf.String())
+}
+
+// Help texts of the metrics every runner records.
+var promHelp = map[string]string{
+	"messages_in":     "Messages received by the plugin.",
+	"messages_out":    "Messages sent by the input to the router.",
+	"bytes_in":        "Bytes received by the plugin.",
+	"bytes_out":       "Bytes sent by the input to the router.",
+	"last_message":    "Unix time of the last message.",
+	"pool_free":       "Free packs in the pool of the input.",
+	"pool_size":       "Size of the pack pool of the input.",
+	"chan_len":        "Messages queued for the output.",
+	"chan_cap":        "Capacity of the output queue.",
+	"drops":           "Messages dropped because the output queue was full.",
+	"latency":         "Time from the input receiving a message to the output getting it.",
+	"decode_errors":   "Messages the decoder failed on.",
+	"decode_time":     "Time spent decoding a message.",
+	"encode_errors":   "Messages the encoder failed on.",
+	"encode_time":     "Time spent encoding a message.",
+	"router.messages": "Messages passed through the router.",
+	"router.chan_len": "Messages queued for the router.",
+	"router.chan_cap": "Capacity of the router queue.",
+}
+
+func (pw *promWriter) addMetric(metric string, labels []string, m interface{}) {
+	name := promName(prometheusNamespace + "_" + metric)
+	help, ok := promHelp[metric]
+	if !ok {
+		help = metric
+	}
+	switch value := metrics.SnapshotOf(m).(type) {
+	case int64:
+		switch m.(type) {
+		case *metrics.Counter:
+			pw.add(name+"_total", "counter", help, "", labels, float64(value))
+		default:
+			pw.add(name, "gauge", help, "", labels, float64(value))
+		}
+	case metrics.MeterSnapshot:
+		pw.add(name+"_total", "counter", help, "", labels, float64(value.Count))
+	case metrics.HistogramSnapshot:
+		pw.addSummary(name, help, labels, value, 1)
+	case metrics.TimerSnapshot:
+		// Timers record nanoseconds, Prometheus wants seconds.
+		pw.addSummary(name+"_seconds", help, labels, value.HistogramSnapshot, 1e-9)
+	}
+}
+
+func (pw *promWriter) addSummary(name, help string, labels []string, snap metrics.HistogramSnapshot, scale float64) {
+	quantiles := []struct {
+		q string
+		v float64
+	}{
+		{"0.5", snap.Pct50},
+		{"0.75", snap.Pct75},
+		{"0.9", snap.Pct90},
+		{"0.95", snap.Pct95},
+		{"0.99", snap.Pct99},
+		{"0.999", snap.Pct999},
+	}
+	for _, quantile := range quantiles {
+		qlabels := append(append([]string{}, labels...), "quantile", quantile.q)
+		pw.add(name, "summary", help, "", qlabels, quantile.v*scale)
+	}
+	// The histograms keep a sample of the values, the sum is estimated from
+	// their mean.
+	pw.add(name, "summary", help, "_sum", labels, snap.Mean*float64(snap.Count)*scale)
+	pw.add(name, "summary", help, "_count", labels, float64(snap.Count))
+}
+
+func (pw *promWriter) addRegistry(r *metrics.Registry) {
+	var labels []string
+	keys := make([]string, 0, len(r.Labels))
+	for key := range r.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		labels = append(labels, promName(key), r.Labels[key])
+	}
+	r.Each(func(name string, m interface{}) {
+		pw.addMetric(name, labels, m)
+	})
+}
+
+func (pw *promWriter) addRuntime() {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	gauges := []struct {
+		name  string
+		help  string
+		value float64
+	}{
+		{"go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine())},
+		{"go_gomaxprocs", "Value of GOMAXPROCS.", float64(runtime.GOMAXPROCS(0))},
+		{"go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(ms.Alloc)},
+		{"go_memstats_sys_bytes", "Number of bytes obtained from the system.", float64(ms.Sys)},
+		{"go_memstats_heap_alloc_bytes", "Number of heap bytes allocated and still in use.", float64(ms.HeapAlloc)},
+		{"go_memstats_heap_sys_bytes", "Number of heap bytes obtained from the system.", float64(ms.HeapSys)},
+		{"go_memstats_heap_idle_bytes", "Number of heap bytes waiting to be used.", float64(ms.HeapIdle)},
+		{"go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", float64(ms.HeapInuse)},
+		{"go_memstats_heap_released_bytes", "Number of heap bytes released to the OS.", float64(ms.HeapReleased)},
+		{"go_memstats_heap_objects", "Number of allocated objects.", float64(ms.HeapObjects)},
+		{"go_memstats_stack_inuse_bytes", "Number of bytes in use by the stack allocator.", float64(ms.StackInuse)},
+		{"go_memstats_next_gc_bytes", "Heap size the next garbage collection runs at.", float64(ms.NextGC)},
+		{"go_memstats_last_gc_time_seconds", "Unix time of the last garbage collection.", float64(ms.LastGC) / 1e9},
+	}
+	for _, g := range gauges {
+		pw.add(g.name, "gauge", g.help, "", nil, g.value)
+	}
+	counters := []struct {
+		name  string
+		help  string
+		value float64
+	}{
+		{"go_memstats_alloc_bytes_total", "Total number of bytes allocated, even if freed.", float64(ms.TotalAlloc)},
+		{"go_memstats_mallocs_total", "Total number of mallocs.", float64(ms.Mallocs)},
+		{"go_memstats_frees_total", "Total number of frees.", float64(ms.Frees)},
+		{"go_memstats_gc_total", "Number of completed garbage collections.", float64(ms.NumGC)},
+		{"go_memstats_gc_pause_seconds_total", "Total time spent in garbage collection pauses.", float64(ms.PauseTotalNs) / 1e9},
+		{"go_cgo_calls_total", "Number of cgo calls made by the process.", float64(runtime.NumCgoCall())},
+	}
+	for _, c := range counters {
+		pw.add(c.name, "counter", c.help, "", nil, c.value)
+	}
+}
+
+func (pw *promWriter) write(buf *bytes.Buffer) {
+	names := make([]string, 0, len(pw.families))
+	for name := range pw.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		family := pw.families[name]
+		fmt.Fprintf(buf, "# HELP %s %s\n", family.name, escapeHelp(family.help))
+		fmt.Fprintf(buf, "# TYPE %s %s\n", family.name, family.kind)
+		for _, sample := range family.samples {
+			buf.WriteString(sample)
+			buf.WriteByte('\n')
+		}
+	}
+}
+
+func (this *prometheusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pw := newPromWriter()
+	pw.addRegistry(metrics.DefaultRegistry)
+	for _, registry := range metrics.Registries() {
+		pw.addRegistry(registry)
+	}
+	pw.addRuntime()
+
+	var buf bytes.Buffer
+	pw.write(&buf)
+	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(buf.Bytes())
+}
+
+// Replaces the characters Prometheus does not allow in metric and label
+// names, "router.chan_len" becomes "router_chan_len".
+func promName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == ':' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
+
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func formatPromValue(v float64) string {
+	if v == float64(int64(v)) {
+		return strconv.FormatInt(int64(v), 10)
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,37 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/millken/kaman/metrics"
+)
+
+func TestPrometheusFormat(t *testing.T) {
+	r := metrics.PluginRegistry("in\"1", "TcpInput", "t3")
+	r.Counter("drops").Add(2)
+	r.Gauge("pool_free").Set(10)
+	r.Timer("latency").Update(2 * time.Second)
+
+	pw := newPromWriter()
+	pw.addRegistry(r)
+	var buf bytes.Buffer
+	pw.write(&buf)
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE kaman_drops_total counter\n",
+		`kaman_drops_total{name="in\"1",tag="t3",type="TcpInput"} 2` + "\n",
+		"# TYPE kaman_pool_free gauge\n",
+		`kaman_pool_free{name="in\"1",tag="t3",type="TcpInput"} 10` + "\n",
+		"# TYPE kaman_latency_seconds summary\n",
+		`kaman_latency_seconds{name="in\"1",tag="t3",type="TcpInput",quantile="0.5"} 2` + "\n",
+		`kaman_latency_seconds_count{name="in\"1",tag="t3",type="TcpInput"} 1` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, out)
+		}
+	}
+}
@@ -1,32 +1,69 @@
 package report
 
 import (
-	"expvar"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
-	"log"
 	"net"
 	"net/http"
 	"time"
 
+	"github.com/millken/kaman/logger"
 	"golang.org/x/net/websocket"
 )
 
 type Server struct {
-	Address     string
-	server      *http.Server
-	listener    net.Listener
-	handler     http.Handler
-	starterFunc func(srv *Server) error
+	Address string
+	// Enables the admin API and protects changes made over HTTP.
+	AdminToken string
+	// Serves HTTPS with the certificate and key, and requires client
+	// certificates signed by TLSClientCA when it is set.
+	TLSCert     string
+	TLSKey      string
+	TLSClientCA string
+	// Basic auth user and password or bearer token every request but the
+	// health probes must carry.
+	AuthUser     string
+	AuthPassword string
+	AuthToken    string
+	// Serves net/http/pprof under /debug/pprof/.
+	Pprof bool
+	// Rates are kept every HistoryResolution for HistorySize samples, a
+	// size of 0 disables the history.
+	HistoryResolution time.Duration
+	HistorySize       int
+	history           *History
+	server            *http.Server
+	mux               *http.ServeMux
+	listener          net.Listener
+	handler           http.Handler
+	starterFunc       func(srv *Server) error
 }
 
 func defaultStarter(srv *Server) (err error) {
+	if err = srv.checkAuth(); err != nil {
+		return err
+	}
+	config, err := srv.tlsConfig()
+	if err != nil {
+		return err
+	}
 	srv.listener, err = net.Listen("tcp", srv.Address)
 	if err != nil {
 		return fmt.Errorf("Listener [%s] start fail: %s",
 			srv.Address, err.Error())
 	} else {
-		log.Printf(fmt.Sprintf("Listening on %s",
-			srv.Address))
+		logger.Info("report server listening", "address", srv.Address, "tls", config != nil)
+	}
+	if config != nil {
+		srv.listener = tls.NewListener(srv.listener, config)
+	}
+	if srv.Pprof {
+		srv.mux.Handle("/debug/pprof/", NewPprof())
+	}
+	if srv.HistorySize > 0 {
+		srv.history = NewHistory(srv.HistoryResolution, srv.HistorySize)
+		go srv.history.Run(make(chan struct{}))
 	}
 
 	err = srv.server.Serve(srv.listener)
@@ -37,13 +74,13 @@ func defaultStarter(srv *Server) (err error) {
 	return nil
 }
 
+// Sends the dashboard data every second.
 func wsServer(ws *websocket.Conn) {
-	var buf string
 	defer func() {
 		if err := ws.Close(); err != nil {
-			log.Println("Websocket could not be closed", err.Error())
+			logger.Warn("Websocket could not be closed", "err", err)
 		} else {
-			log.Println("Websocket closed")
+			logger.Debug("Websocket closed")
 		}
 	}()
 	//q := ws.Request().URL.Query()
@@ -53,15 +90,14 @@ func wsServer(ws *websocket.Conn) {
 	for !stopped {
 		select {
 		case <-ticker:
-			val := expvar.Get(metricsVar)
-			if val == nil {
-				buf = ""
-			} else {
-				buf = val.String()
+			buf, err := json.Marshal(newDashboard())
+			if err != nil {
+				logger.Error("dashboard json", "err", err)
+				return
 			}
-			_, err := ws.Write([]byte(buf))
+			_, err = ws.Write(buf)
 			if err != nil {
-				log.Printf("Websocket error: %s\n", err.Error())
+				logger.Debug("Websocket error", "err", err)
 				stopped = true
 			}
 
@@ -71,20 +107,33 @@ func wsServer(ws *websocket.Conn) {
 
 func NewServer(addr string) *Server {
 	srv := &Server{
-		Address: addr,
+		Address:           addr,
+		HistoryResolution: 10 * time.Second,
+		HistorySize:       8640,
 	}
 	srv.starterFunc = defaultStarter
 
 	mux := http.NewServeMux()
 	stats := NewMetric()
 	runtime := NewRuntime()
+	mux.Handle("/", NewDashboard())
 	mux.Handle("/stats", stats)
 	mux.Handle("/runtime", runtime)
+	mux.Handle("/metrics", NewPrometheus())
+	mux.Handle("/pipeline", NewPipeline())
+	mux.Handle("/history", NewHistoryHandler(srv))
+	mux.Handle("/traces", NewTraces())
+	mux.Handle("/healthz", NewHealth(false))
+	mux.Handle("/readyz", NewHealth(true))
+	mux.Handle("/loglevel", NewLogLevel(srv))
+	mux.Handle("/admin/", NewAdmin(srv))
 	mux.Handle("/ws", websocket.Handler(wsServer))
+	mux.Handle("/tap", NewTap(srv))
 
+	srv.mux = mux
 	srv.server = &http.Server{
 		Addr:    srv.Address,
-		Handler: mux,
+		Handler: &authHandler{srv: srv, handler: mux},
 		//ReadTimeout:  10 * time.Second,
 		//WriteTimeout: 10 * time.Second,
 	}
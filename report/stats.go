@@ -5,6 +5,8 @@ import (
 	"expvar"
 	"net/http"
 	"sync"
+
+	"github.com/millken/kaman/metrics"
 )
 
 const (
@@ -35,7 +37,18 @@ func writeJsonResponse(w http.ResponseWriter, obj interface{}, err error) error
 func (mh *metricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
 
-	//	_, _ = metrics.Snapshot()
+	// ?plugin=name limits the output to the registry of one plugin.
+	if name := r.URL.Query().Get("plugin"); name != "" {
+		snap, ok := metrics.Snapshot()[name]
+		if !ok {
+			http.Error(w, "No metrics for plugin "+name, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		writeJsonResponse(w, snap, nil)
+		return
+	}
+
 	val := expvar.Get(metricsVar)
 	if val == nil {
 		w.WriteHeader(http.StatusNotImplemented)
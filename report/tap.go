@@ -0,0 +1,109 @@
+package report
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/millken/kaman/logger"
+	"github.com/millken/kaman/plugins"
+	"golang.org/x/net/websocket"
+)
+
+// tapHandler streams the messages of a tap over a websocket, e.g.
+// /tap?point=decode&tag=^nginx&field=status&value=^5&sample=10
+type tapHandler struct {
+	srv *Server
+}
+
+func NewTap(srv *Server) *tapHandler {
+	return &tapHandler{srv: srv}
+}
+
+func newTapFromQuery(r *http.Request) (*plugins.Tap, error) {
+	q := r.URL.Query()
+	point := plugins.TapInput
+	if p := q.Get("point"); p != "" {
+		var err error
+		if point, err = plugins.ParseTapPoint(p); err != nil {
+			return nil, err
+		}
+	}
+	tap := plugins.NewTap(point)
+	if tag := q.Get("tag"); tag != "" {
+		re, err := regexp.Compile(tag)
+		if err != nil {
+			return nil, err
+		}
+		tap.Tag = re
+	}
+	tap.Field = q.Get("field")
+	if value := q.Get("value"); value != "" {
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+		tap.Value = re
+	}
+	if sample := q.Get("sample"); sample != "" {
+		n, err := strconv.ParseInt(sample, 10, 64)
+		if err != nil || n < 1 {
+			return nil, strconv.ErrSyntax
+		}
+		tap.Sample = n
+	}
+	return tap, nil
+}
+
+func (this *tapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Messages may hold sensitive data, so taps are part of the admin API.
+	// Browsers can't set headers on websockets, so the token may also be
+	// passed as a parameter.
+	if this.srv.AdminToken == "" {
+		http.Error(w, "Tap disabled, no admin token configured.", http.StatusForbidden)
+		return
+	}
+	if !this.srv.authorized(r) && !this.srv.validToken(r.URL.Query().Get("token")) {
+		http.Error(w, "Invalid admin token.", http.StatusUnauthorized)
+		return
+	}
+	tap, err := newTapFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// No origin check, so that command line clients like wscat work.
+	websocket.Server{Handler: func(ws *websocket.Conn) {
+		this.stream(ws, tap)
+	}}.ServeHTTP(w, r)
+}
+
+func (this *tapHandler) stream(ws *websocket.Conn, tap *plugins.Tap) {
+	log := logger.With("remote", ws.Request().RemoteAddr, "point", tap.Point)
+	log.Info("tap opened", "query", ws.Request().URL.RawQuery)
+	plugins.Subscribe(tap)
+	defer func() {
+		plugins.Unsubscribe(tap)
+		ws.Close()
+		log.Info("tap closed", "dropped", tap.Dropped())
+	}()
+
+	// The client sends nothing, reading only notices when it goes away.
+	closed := make(chan struct{})
+	go func() {
+		io.Copy(ioutil.Discard, ws)
+		close(closed)
+	}()
+	for {
+		select {
+		case msg := <-tap.C:
+			if _, err := ws.Write(msg); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
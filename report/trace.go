@@ -0,0 +1,122 @@
+package report
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/millken/kaman/plugins"
+)
+
+type traceFilter struct {
+	Sample float64 `json:"sample"`
+	Field  string  `json:"field,omitempty"`
+	Value  string  `json:"value,omitempty"`
+	Size   int     `json:"size"`
+}
+
+type traces struct {
+	// Nil while tracing is off.
+	Filter  *traceFilter         `json:"filter"`
+	Skipped int64                `json:"skipped"`
+	Active  []*plugins.TraceInfo `json:"active"`
+	Done    []*plugins.TraceInfo `json:"done"`
+}
+
+func currentTraceFilter() *traceFilter {
+	filter := plugins.CurrentTraceFilter()
+	if filter == nil {
+		return nil
+	}
+	tf := &traceFilter{
+		Sample: filter.Sample,
+		Field:  filter.Field,
+		Size:   filter.Size,
+	}
+	if filter.Value != nil {
+		tf.Value = filter.Value.String()
+	}
+	return tf
+}
+
+// Builds the filter from the sample, field, value and size parameters.
+func parseTraceFilter(r *http.Request) (*plugins.TraceFilter, error) {
+	filter := &plugins.TraceFilter{Field: r.FormValue("field")}
+	var err error
+	if s := r.FormValue("sample"); s != "" {
+		if filter.Sample, err = strconv.ParseFloat(s, 64); err != nil {
+			return nil, fmt.Errorf("Invalid sample: %s", err)
+		}
+	}
+	if s := r.FormValue("value"); s != "" {
+		if filter.Value, err = regexp.Compile(s); err != nil {
+			return nil, fmt.Errorf("Invalid value: %s", err)
+		}
+	}
+	if s := r.FormValue("size"); s != "" {
+		if filter.Size, err = strconv.Atoi(s); err != nil {
+			return nil, fmt.Errorf("Invalid size: %s", err)
+		}
+	}
+	return filter, nil
+}
+
+// traceHandler serves the recorded traces on /traces, as JSON or, with
+// format=text, one line per span.
+type traceHandler int
+
+func NewTraces() *traceHandler {
+	return new(traceHandler)
+}
+
+func (this *traceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+	t := &traces{Filter: currentTraceFilter()}
+	t.Active, t.Done, t.Skipped = plugins.Traces()
+
+	if s := r.URL.Query().Get("id"); s != "" {
+		id, _ := strconv.ParseInt(s, 10, 64)
+		t.Active = selectTrace(t.Active, id)
+		t.Done = selectTrace(t.Done, id)
+		if len(t.Active)+len(t.Done) == 0 {
+			http.Error(w, "No trace "+s, http.StatusNotFound)
+			return
+		}
+	}
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, list := range [][]*plugins.TraceInfo{t.Active, t.Done} {
+			for _, trace := range list {
+				writeTraceText(w, trace)
+			}
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJsonResponse(w, t, nil)
+}
+
+func selectTrace(list []*plugins.TraceInfo, id int64) []*plugins.TraceInfo {
+	for _, trace := range list {
+		if trace.ID == id {
+			return []*plugins.TraceInfo{trace}
+		}
+	}
+	return []*plugins.TraceInfo{}
+}
+
+func writeTraceText(w http.ResponseWriter, trace *plugins.TraceInfo) {
+	state := "in flight"
+	if trace.Done {
+		state = "done in " + time.Duration(trace.Duration).String()
+	}
+	fmt.Fprintf(w, "trace %d tag=%s input=%s %s %s\n", trace.ID, trace.Tag, trace.Input,
+		trace.Start.Format(time.RFC3339Nano), state)
+	for _, s := range trace.Spans {
+		fmt.Fprintf(w, "  %12s  %-7s %-16s %12s  %s\n", "+"+time.Duration(s.Offset).String(),
+			s.Stage, s.Plugin, time.Duration(s.Duration), s.Result)
+	}
+}
@@ -0,0 +1,322 @@
+// Package syslog parses RFC3164 and RFC5424 syslog messages, accepting the
+// usual deviations of rsyslog and network devices: missing priority or
+// hostname, octet counted framing, years and fractions in RFC3164 timestamps,
+// RFC3339 timestamps in RFC3164 messages and Cisco sequence numbers.
+package syslog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var facilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var severities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+type Message struct {
+	// "rfc3164" or "rfc5424".
+	Format   string
+	Priority int
+	Facility int
+	Severity int
+	// 0 for RFC3164.
+	Version   int
+	Timestamp time.Time
+	Hostname  string
+	AppName   string
+	ProcID    string
+	MsgID     string
+	// Params by SD-ID.
+	StructuredData map[string]map[string]string
+	Message        string
+}
+
+func (this *Message) FacilityName() string {
+	if this.Facility >= 0 && this.Facility < len(facilities) {
+		return facilities[this.Facility]
+	}
+	return strconv.Itoa(this.Facility)
+}
+
+func (this *Message) SeverityName() string {
+	if this.Severity >= 0 && this.Severity < len(severities) {
+		return severities[this.Severity]
+	}
+	return strconv.Itoa(this.Severity)
+}
+
+// Parses the message. RFC3164 timestamps without a zone are in loc, those
+// without a year are in the year that puts them closest before now.
+func Parse(line string, now time.Time, loc *time.Location) (*Message, error) {
+	line = strings.TrimRight(line, "\r\n\x00")
+	line = stripOctetCount(line)
+	m := &Message{Priority: 13}
+	rest := line
+	if strings.HasPrefix(rest, "<") {
+		end := strings.IndexByte(rest, '>')
+		if end < 2 || end > 4 {
+			return nil, fmt.Errorf("invalid priority in %q", cut(line))
+		}
+		// Atoi would take signs as well.
+		pri := 0
+		for _, c := range []byte(rest[1:end]) {
+			if c < '0' || c > '9' {
+				return nil, fmt.Errorf("invalid priority in %q", cut(line))
+			}
+			pri = pri*10 + int(c-'0')
+		}
+		if pri > 191 {
+			return nil, fmt.Errorf("invalid priority in %q", cut(line))
+		}
+		m.Priority = pri
+		rest = rest[end+1:]
+	}
+	m.Facility, m.Severity = m.Priority/8, m.Priority%8
+
+	// RFC5424 has a version right after the priority.
+	if len(rest) > 1 && rest[0] >= '1' && rest[0] <= '9' {
+		if i := strings.IndexByte(rest, ' '); i > 0 && i <= 3 {
+			if v, err := strconv.Atoi(rest[:i]); err == nil {
+				m.Version = v
+				m.Format = "rfc5424"
+				return m, m.parse5424(rest[i+1:], line)
+			}
+		}
+	}
+	m.Format = "rfc3164"
+	m.parse3164(rest, now, loc)
+	return m, nil
+}
+
+// TCP syslog may prefix messages with their length.
+func stripOctetCount(line string) string {
+	i := 0
+	for i < len(line) && line[i] >= '0' && line[i] <= '9' {
+		i++
+	}
+	if i > 0 && i+1 < len(line) && line[i] == ' ' && line[i+1] == '<' {
+		return line[i+1:]
+	}
+	return line
+}
+
+func cut(s string) string {
+	if len(s) > 64 {
+		return s[:64] + "..."
+	}
+	return s
+}
+
+// Returns the next space separated field and the rest.
+func field(s string) (string, string) {
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+func (this *Message) parse5424(rest, line string) (err error) {
+	var ts string
+	ts, rest = field(rest)
+	if ts != "-" {
+		if this.Timestamp, err = time.Parse(time.RFC3339Nano, ts); err != nil {
+			return fmt.Errorf("invalid timestamp %q: %s", ts, err)
+		}
+	}
+	var s string
+	s, rest = field(rest)
+	this.Hostname = nilValue(s)
+	s, rest = field(rest)
+	this.AppName = nilValue(s)
+	s, rest = field(rest)
+	this.ProcID = nilValue(s)
+	s, rest = field(rest)
+	this.MsgID = nilValue(s)
+
+	if strings.HasPrefix(rest, "-") {
+		rest = strings.TrimPrefix(rest[1:], " ")
+	} else if strings.HasPrefix(rest, "[") {
+		if this.StructuredData, rest, err = parseStructuredData(rest); err != nil {
+			return fmt.Errorf("%s in %q", err, cut(line))
+		}
+		rest = strings.TrimPrefix(rest, " ")
+	} else if rest != "" {
+		return fmt.Errorf("missing structured data in %q", cut(line))
+	}
+	this.Message = strings.TrimPrefix(rest, "\ufeff")
+	return nil
+}
+
+// [id key="value" ...][id2 ...], values escape ", \ and ].
+func parseStructuredData(s string) (map[string]map[string]string, string, error) {
+	sd := make(map[string]map[string]string)
+	for strings.HasPrefix(s, "[") {
+		s = s[1:]
+		end := strings.IndexAny(s, " ]")
+		if end <= 0 {
+			return nil, "", fmt.Errorf("invalid structured data")
+		}
+		params := make(map[string]string)
+		sd[s[:end]] = params
+		s = s[end:]
+		for {
+			s = strings.TrimLeft(s, " ")
+			if strings.HasPrefix(s, "]") {
+				s = s[1:]
+				break
+			}
+			eq := strings.Index(s, "=\"")
+			if eq <= 0 {
+				return nil, "", fmt.Errorf("invalid structured data param")
+			}
+			name := s[:eq]
+			s = s[eq+2:]
+			var value []byte
+			closed := false
+			for i := 0; i < len(s); i++ {
+				c := s[i]
+				if c == '\\' && i+1 < len(s) && strings.IndexByte(`"\]`, s[i+1]) >= 0 {
+					value = append(value, s[i+1])
+					i++
+					continue
+				}
+				if c == '"' {
+					s = s[i+1:]
+					closed = true
+					break
+				}
+				value = append(value, c)
+			}
+			if !closed {
+				return nil, "", fmt.Errorf("unterminated structured data value")
+			}
+			params[name] = string(value)
+		}
+	}
+	return sd, s, nil
+}
+
+// RFC3164 timestamp layouts, the longer ones first so fractions aren't
+// left over.
+var layouts3164 = []string{
+	"Jan _2 2006 15:04:05.000",
+	"Jan _2 2006 15:04:05",
+	time.StampMicro,
+	time.StampMilli,
+	time.Stamp,
+}
+
+func (this *Message) parse3164(rest string, now time.Time, loc *time.Location) {
+	// Cisco: "<189>123: *Mar  1 18:46:11.123: %SYS-5-CONFIG_I: ..."
+	if i := strings.Index(rest, ": "); i > 0 && isDigits(rest[:i]) {
+		rest = rest[i+2:]
+	}
+	rest = strings.TrimLeft(rest, "*.")
+
+	if ts, after, ok := parseTimestamp3164(rest, now, loc); ok {
+		this.Timestamp = ts
+		rest = strings.TrimPrefix(strings.TrimPrefix(after, ":"), " ")
+		// The hostname may be missing, a tag looks like "app:" or "app[1]:".
+		if host, after := field(rest); host != "" && !strings.HasSuffix(host, ":") && !strings.Contains(host, "[") {
+			this.Hostname = host
+			rest = after
+		}
+	}
+	this.Message = rest
+
+	// TAG[PID]: MSG, the tag is alphanumeric and at most 32 characters by
+	// the RFC, longer ones and other characters are common.
+	colon := strings.Index(rest, ": ")
+	if colon < 0 && strings.HasSuffix(rest, ":") {
+		colon = len(rest) - 1
+	}
+	if colon <= 0 || colon > 64 || strings.ContainsAny(rest[:colon], " \t") {
+		return
+	}
+	tag := rest[:colon]
+	if i := strings.IndexByte(tag, '['); i > 0 && strings.HasSuffix(tag, "]") {
+		this.ProcID = tag[i+1 : len(tag)-1]
+		tag = tag[:i]
+	}
+	this.AppName = tag
+	this.Message = strings.TrimPrefix(rest[colon+1:], " ")
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func parseTimestamp3164(s string, now time.Time, loc *time.Location) (time.Time, string, bool) {
+	// rsyslog may send RFC3339 timestamps in RFC3164 messages.
+	if len(s) > 0 && s[0] >= '0' && s[0] <= '9' {
+		ts, rest := field(s)
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		return t, rest, err == nil
+	}
+	for _, layout := range layouts3164 {
+		if len(s) < len(layout) {
+			continue
+		}
+		t, err := time.ParseInLocation(layout, s[:len(layout)], loc)
+		if err != nil {
+			continue
+		}
+		if t.Year() == 0 {
+			t = t.AddDate(now.In(loc).Year(), 0, 0)
+			// A December message read in January is from last year.
+			if t.After(now.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+		}
+		return t, s[len(layout):], true
+	}
+	return time.Time{}, s, false
+}
+
+// Adds the fields of the message to data, leaving out the empty ones.
+// Structured data params are named sd.<SD-ID>.<name>.
+func (this *Message) Fields(data map[string]interface{}) {
+	data["syslog_format"] = this.Format
+	data["priority"] = this.Priority
+	data["facility"] = this.Facility
+	data["severity"] = this.Severity
+	data["facility_name"] = this.FacilityName()
+	data["severity_name"] = this.SeverityName()
+	data["message"] = this.Message
+	if this.Version > 0 {
+		data["version"] = this.Version
+	}
+	for name, value := range map[string]string{
+		"hostname": this.Hostname,
+		"app_name": this.AppName,
+		"proc_id":  this.ProcID,
+		"msg_id":   this.MsgID,
+	} {
+		if value != "" {
+			data[name] = value
+		}
+	}
+	for id, params := range this.StructuredData {
+		for k, v := range params {
+			data["sd."+id+"."+k] = v
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package syslog
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+func TestRFC5424(t *testing.T) {
+	m, err := Parse(`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="App\"lication"][other x="]\]"] `+"\ufeff"+`An application event`, now, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{}
+	m.Fields(data)
+	want := map[string]interface{}{
+		"syslog_format":                    "rfc5424",
+		"priority":                         165,
+		"facility":                         20,
+		"severity":                         5,
+		"facility_name":                    "local4",
+		"severity_name":                    "notice",
+		"version":                          1,
+		"hostname":                         "mymachine.example.com",
+		"app_name":                         "evntslog",
+		"msg_id":                           "ID47",
+		"sd.exampleSDID@32473.iut":         "3",
+		"sd.exampleSDID@32473.eventSource": `App"lication`,
+		"sd.other.x":                       "]]",
+		"message":                          "An application event",
+	}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("got %v\nwant %v", data, want)
+	}
+	if !m.Timestamp.Equal(time.Date(2003, 10, 11, 22, 14, 15, 3e6, time.UTC)) {
+		t.Errorf("got time %s", m.Timestamp)
+	}
+
+	m, err = Parse("<13>1 - - - - -", now, time.UTC)
+	if err != nil || !m.Timestamp.IsZero() || m.Hostname != "" || m.Message != "" {
+		t.Errorf("nil values: %+v %v", m, err)
+	}
+	if _, err = Parse(`<13>1 - h a - - [id x="1"`, now, time.UTC); err == nil {
+		t.Error("unterminated structured data parsed")
+	}
+	if _, err = Parse("<13>1 yesterday h a - - -", now, time.UTC); err == nil {
+		t.Error("invalid timestamp parsed")
+	}
+}
+
+func TestRFC3164(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	for _, c := range []struct {
+		line                    string
+		time                    time.Time
+		host, app, pid, message string
+	}{
+		{"<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8",
+			time.Date(2026, 10, 11, 22, 14, 15, 0, loc), "mymachine", "su", "", "'su root' failed for lonvick on /dev/pts/8"},
+		{"<13>Oct  9 01:02:03 host sshd[1234]: Accepted publickey",
+			time.Date(2026, 10, 9, 1, 2, 3, 0, loc), "host", "sshd", "1234", "Accepted publickey"},
+		// No hostname.
+		{"<13>Oct  9 01:02:03 sshd[1234]: hello",
+			time.Date(2026, 10, 9, 1, 2, 3, 0, loc), "", "sshd", "1234", "hello"},
+		// No tag.
+		{"<13>Oct  9 01:02:03 host just some text",
+			time.Date(2026, 10, 9, 1, 2, 3, 0, loc), "host", "", "", "just some text"},
+		// Times in the future are from last year.
+		{"<13>Dec 31 23:59:59 host app: late",
+			time.Date(2025, 12, 31, 23, 59, 59, 0, loc), "host", "app", "", "late"},
+		{"<13>Oct 11 2024 22:14:15.250 fw01 kernel: drop",
+			time.Date(2024, 10, 11, 22, 14, 15, 250e6, loc), "fw01", "kernel", "", "drop"},
+		{"<13>2026-10-11T22:14:15.5+02:00 host app[7]: rsyslog",
+			time.Date(2026, 10, 11, 20, 14, 15, 500e6, time.UTC), "host", "app", "7", "rsyslog"},
+		// Cisco IOS.
+		{"<189>123: *Mar  1 18:46:11.123: %SYS-5-CONFIG_I: Configured from console",
+			time.Date(2026, 3, 1, 18, 46, 11, 123e6, loc), "", "%SYS-5-CONFIG_I", "", "Configured from console"},
+		// Octet counted, no priority.
+		{"38 <13>Oct 11 22:14:15 host app: framed",
+			time.Date(2026, 10, 11, 22, 14, 15, 0, loc), "host", "app", "", "framed"},
+		{"no header at all", time.Time{}, "", "", "", "no header at all"},
+	} {
+		m, err := Parse(c.line+"\n", now, loc)
+		if err != nil {
+			t.Errorf("%s: %s", c.line, err)
+			continue
+		}
+		if m.Format != "rfc3164" || !m.Timestamp.Equal(c.time) || m.Hostname != c.host ||
+			m.AppName != c.app || m.ProcID != c.pid || m.Message != c.message {
+			t.Errorf("%s: got %+v", c.line, m)
+		}
+	}
+
+	m, _ := Parse("no header at all", now, loc)
+	if m.Priority != 13 || m.FacilityName() != "user" || m.SeverityName() != "notice" {
+		t.Errorf("default priority: %+v", m)
+	}
+	jan := time.Date(2027, 1, 2, 0, 0, 0, 0, time.UTC)
+	if m, _ = Parse("<13>Dec 31 23:59:59 host app: late", jan, loc); m.Timestamp.Year() != 2026 {
+		t.Errorf("got %s in January", m.Timestamp)
+	}
+	for _, line := range []string{
+		"<999>Oct 11 22:14:15 host app: x",
+		"<192>Oct 11 22:14:15 host app: x",
+		"<-1>hello",
+		"<-9>x",
+		"<+5>Oct 11 22:14:15 host app: x",
+		"< 5>Oct 11 22:14:15 host app: x",
+	} {
+		if _, err := Parse(line, now, loc); err == nil {
+			t.Errorf("invalid priority of %q parsed", line)
+		}
+	}
+	if m, err := Parse("<191>1 - - - - - - x", now, loc); err != nil || m.FacilityName() != "local7" || m.SeverityName() != "debug" {
+		t.Errorf("highest priority: %+v %v", m, err)
+	}
+}
+
+func TestNames(t *testing.T) {
+	for _, m := range []Message{{Facility: -1, Severity: -1}, {Facility: 24, Severity: 8}} {
+		if m.FacilityName() != strconv.Itoa(m.Facility) || m.SeverityName() != strconv.Itoa(m.Severity) {
+			t.Errorf("names of %+v", m)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Package timestamp parses event times with Go layouts, strftime layouts or
+// as unix epochs.
+package timestamp
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Named layouts, besides the Go layouts and strftime ones.
+var Layouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"UnixDate":    time.UnixDate,
+	"RubyDate":    time.RubyDate,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"RFC850":      time.RFC850,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Stamp":       time.Stamp,
+	"StampMilli":  time.StampMilli,
+	"ISO8601":     "2006-01-02T15:04:05.999999999Z0700",
+	"common_log":  "02/Jan/2006:15:04:05 -0700",
+}
+
+// Nanoseconds per unit of the epoch layouts.
+var epochs = map[string]int64{
+	"unix":    1e9,
+	"unix_ms": 1e6,
+	"unix_us": 1e3,
+	"unix_ns": 1,
+}
+
+var strftime = map[byte]string{
+	'a': "Mon",
+	'A': "Monday",
+	'b': "Jan",
+	'B': "January",
+	'd': "02",
+	'e': "_2",
+	'F': "2006-01-02",
+	'h': "Jan",
+	'H': "15",
+	'I': "03",
+	'j': "002",
+	'm': "01",
+	'M': "04",
+	'p': "PM",
+	'R': "15:04",
+	'S': "05",
+	'T': "15:04:05",
+	'y': "06",
+	'Y': "2006",
+	'z': "-0700",
+	'Z': "MST",
+	'%': "%",
+	// Fractions, after the dot of the seconds.
+	'L': "000",
+	'f': "000000",
+	'N': "000000000",
+}
+
+// Converts a strftime layout to a Go one.
+func Strftime(layout string) (string, error) {
+	var goLayout []byte
+	for i := 0; i < len(layout); i++ {
+		if layout[i] != '%' {
+			goLayout = append(goLayout, layout[i])
+			continue
+		}
+		if i++; i == len(layout) {
+			return "", fmt.Errorf("%s ends with %%", layout)
+		}
+		s, ok := strftime[layout[i]]
+		if !ok {
+			return "", fmt.Errorf("unsupported %%%c in %s", layout[i], layout)
+		}
+		goLayout = append(goLayout, s...)
+	}
+	return string(goLayout), nil
+}
+
+type layout struct {
+	// Go layout, empty for epochs.
+	layout string
+	// Nanoseconds per unit of an epoch.
+	unit int64
+}
+
+// A Parser tries its layouts in order.
+type Parser struct {
+	layouts  []layout
+	location *time.Location
+}
+
+// Layouts are Go layouts, names of Layouts, strftime layouts with % or the
+// epochs unix, unix_ms, unix_us and unix_ns (%s is unix). Times without a
+// zone are in loc.
+func New(layouts []string, loc *time.Location) (*Parser, error) {
+	if len(layouts) == 0 {
+		return nil, fmt.Errorf("no layouts")
+	}
+	p := &Parser{location: loc}
+	for _, l := range layouts {
+		if l == "%s" {
+			l = "unix"
+		}
+		if unit, ok := epochs[l]; ok {
+			p.layouts = append(p.layouts, layout{unit: unit})
+			continue
+		}
+		if named, ok := Layouts[l]; ok {
+			l = named
+		} else if strings.Contains(l, "%") {
+			var err error
+			if l, err = Strftime(l); err != nil {
+				return nil, err
+			}
+		}
+		p.layouts = append(p.layouts, layout{layout: l})
+	}
+	return p, nil
+}
+
+// Parses a string or a number. Times without a year are in the year that
+// puts them closest before now.
+func (this *Parser) Parse(value interface{}, now time.Time) (time.Time, error) {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = strings.TrimSpace(v)
+	case []byte:
+		s = strings.TrimSpace(string(v))
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		s = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case int, int32, int64, uint, uint32, uint64:
+		s = fmt.Sprint(v)
+	default:
+		return time.Time{}, fmt.Errorf("%v is a %T, not a time", value, value)
+	}
+	for _, l := range this.layouts {
+		if l.layout == "" {
+			if t, ok := parseEpoch(s, l.unit); ok {
+				return t, nil
+			}
+			continue
+		}
+		t, err := time.ParseInLocation(l.layout, s, this.location)
+		if err != nil {
+			continue
+		}
+		if t.Year() == 0 {
+			t = t.AddDate(now.In(this.location).Year(), 0, 0)
+			if t.After(now.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q matches none of the %d layouts", s, len(this.layouts))
+}
+
+// The integer and fraction parts are parsed apart, as float64 would round
+// milliseconds and below.
+func parseEpoch(s string, unit int64) (time.Time, bool) {
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	n, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || n > math.MaxInt64/unit || n < math.MinInt64/unit {
+		return time.Time{}, false
+	}
+	ns := n * unit
+	if frac != "" {
+		// Nanoseconds of a unit.
+		frac = (frac + "000000000")[:9]
+		f, err := strconv.ParseInt(frac, 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		if strings.HasPrefix(whole, "-") {
+			f = -f
+		}
+		ns += f * unit / 1e9
+	}
+	return time.Unix(0, ns), true
+}
@@ -0,0 +1,77 @@
+package timestamp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStrftime(t *testing.T) {
+	for in, want := range map[string]string{
+		"%Y-%m-%d %H:%M:%S.%L %z": "2006-01-02 15:04:05.000 -0700",
+		"%d/%b/%Y:%T":             "02/Jan/2006:15:04:05",
+		"%e %B %y %I%p 100%%":     "_2 January 06 03PM 100%",
+	} {
+		if got, err := Strftime(in); err != nil || got != want {
+			t.Errorf("%s: got %s %v", in, got, err)
+		}
+	}
+	for _, in := range []string{"%Q", "%Y%"} {
+		if _, err := Strftime(in); err == nil {
+			t.Errorf("%s converted", in)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	p, err := New([]string{"RFC3339", "%Y-%m-%d %H:%M:%S", "Mon Jan _2 15:04:05 MST 06", "Jan _2 15:04:05", "unix_ms"}, shanghai)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		value interface{}
+		want  time.Time
+	}{
+		{"2026-10-19T13:55:36+02:00", time.Date(2026, 10, 19, 11, 55, 36, 0, time.UTC)},
+		// In the default zone.
+		{"2026-10-19 13:55:36", time.Date(2026, 10, 19, 5, 55, 36, 0, time.UTC)},
+		{"Mon Oct 19 13:55:36 CST 26", time.Date(2026, 10, 19, 5, 55, 36, 0, time.UTC)},
+		// Without a year, in the future so last year.
+		{"Dec 31 23:00:00", time.Date(2025, 12, 31, 15, 0, 0, 0, time.UTC)},
+		{int64(1760860536123), time.Unix(1760860536, 123e6)},
+		{"1760860536123", time.Unix(1760860536, 123e6)},
+		{float64(1760860536123), time.Unix(1760860536, 123e6)},
+	} {
+		got, err := p.Parse(c.value, now)
+		if err != nil || !got.Equal(c.want) {
+			t.Errorf("%v: got %s %v, want %s", c.value, got, err, c.want)
+		}
+	}
+	for _, value := range []interface{}{"yesterday", true, "1e400"} {
+		if _, err := p.Parse(value, now); err == nil {
+			t.Errorf("%v parsed", value)
+		}
+	}
+}
+
+func TestEpochs(t *testing.T) {
+	for layout, value := range map[string]interface{}{
+		"unix":    1760860536.5,
+		"%s":      "1760860536.5",
+		"unix_us": int64(1760860536500000),
+		"unix_ns": "1760860536500000000",
+	} {
+		p, err := New([]string{layout}, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := p.Parse(value, time.Now())
+		if err != nil || !got.Equal(time.Unix(1760860536, 5e8)) {
+			t.Errorf("%s %v: got %s %v", layout, value, got, err)
+		}
+	}
+	if _, err := New(nil, time.UTC); err == nil {
+		t.Error("no layouts accepted")
+	}
+}
@@ -0,0 +1,114 @@
+package useragent
+
+// The built-in rules, in the format of regexes.yaml. A short list of the
+// common browsers, systems, devices and crawlers; the full uap-core file
+// tells far more apart.
+const builtinRules = `
+user_agent_parsers:
+  # Crawlers first, many of them claim to be a browser as well.
+  - regex: '(Googlebot|bingbot|Baiduspider|YandexBot|DuckDuckBot|Applebot|facebookexternalhit|Twitterbot|AhrefsBot|SemrushBot|Bytespider|GPTBot|PetalBot)(?:/(\d+)(?:\.(\d+))?)?'
+  - regex: '([\w.-]*(?:bot|crawler|spider))/v?(\d+)?(?:\.(\d+))?'
+    regex_flag: 'i'
+  - regex: '(curl|Wget|python-requests|Go-http-client|okhttp|Apache-HttpClient)/(\d+)(?:\.(\d+))?(?:\.(\d+))?'
+
+  - regex: '(?:Edge?|EdgA|EdgiOS)/(\d+)\.(\d+)(?:\.(\d+))?'
+    family_replacement: 'Edge'
+    v1_replacement: '$1'
+    v2_replacement: '$2'
+    v3_replacement: '$3'
+  - regex: '(?:OPR|Opera)/(\d+)\.(\d+)(?:\.(\d+))?'
+    family_replacement: 'Opera'
+    v1_replacement: '$1'
+    v2_replacement: '$2'
+    v3_replacement: '$3'
+  - regex: '(SamsungBrowser)/(\d+)\.(\d+)'
+    family_replacement: 'Samsung Internet'
+  - regex: '(MicroMessenger)/(\d+)\.(\d+)\.(\d+)'
+    family_replacement: 'WeChat'
+  - regex: '(UCBrowser)/(\d+)\.(\d+)\.(\d+)'
+    family_replacement: 'UC Browser'
+  - regex: '(CriOS)/(\d+)\.(\d+)\.(\d+)'
+    family_replacement: 'Chrome Mobile iOS'
+  - regex: '(FxiOS)/(\d+)\.(\d+)'
+    family_replacement: 'Firefox iOS'
+  - regex: 'Android.+(Chrome)/(\d+)\.(\d+)\.(\d+).* Mobile'
+    family_replacement: 'Chrome Mobile'
+  - regex: '(Chromium|Chrome)/(\d+)\.(\d+)\.(\d+)'
+  - regex: '(Firefox)/(\d+)\.(\d+)(?:\.(\d+))?'
+  - regex: 'Version/(\d+)\.(\d+)(?:\.(\d+))?.* Mobile/\S+ Safari/'
+    family_replacement: 'Mobile Safari'
+    v1_replacement: '$1'
+    v2_replacement: '$2'
+    v3_replacement: '$3'
+  - regex: 'Version/(\d+)\.(\d+)(?:\.(\d+))? Safari/'
+    family_replacement: 'Safari'
+    v1_replacement: '$1'
+    v2_replacement: '$2'
+    v3_replacement: '$3'
+  - regex: '(MSIE) (\d+)\.(\d+)'
+    family_replacement: 'IE'
+  - regex: 'Trident/\d+\.\d+.*rv:(\d+)\.(\d+)'
+    family_replacement: 'IE'
+    v1_replacement: '$1'
+    v2_replacement: '$2'
+
+os_parsers:
+  - regex: 'Windows NT 10\.0'
+    os_replacement: 'Windows'
+    os_v1_replacement: '10'
+  - regex: 'Windows NT 6\.3'
+    os_replacement: 'Windows'
+    os_v1_replacement: '8'
+    os_v2_replacement: '1'
+  - regex: 'Windows NT 6\.2'
+    os_replacement: 'Windows'
+    os_v1_replacement: '8'
+  - regex: 'Windows NT 6\.1'
+    os_replacement: 'Windows'
+    os_v1_replacement: '7'
+  - regex: 'Windows NT 5\.1'
+    os_replacement: 'Windows'
+    os_v1_replacement: 'XP'
+  - regex: '(Windows Phone)(?: OS)? (\d+)\.(\d+)'
+  - regex: '(?:CPU OS|iPhone OS) (\d+)_(\d+)(?:_(\d+))?'
+    os_replacement: 'iOS'
+    os_v1_replacement: '$1'
+    os_v2_replacement: '$2'
+    os_v3_replacement: '$3'
+  - regex: '(?:iPhone|iPad|iPod)'
+    os_replacement: 'iOS'
+  - regex: 'Mac OS X (\d+)[_.](\d+)(?:[_.](\d+))?'
+    os_replacement: 'Mac OS X'
+    os_v1_replacement: '$1'
+    os_v2_replacement: '$2'
+    os_v3_replacement: '$3'
+  - regex: '(HarmonyOS)'
+  - regex: '(Android)[ /]?(\d+)?(?:\.(\d+))?(?:\.(\d+))?'
+  - regex: '(CrOS) \w+ (\d+)\.(\d+)\.(\d+)'
+    os_replacement: 'Chrome OS'
+  - regex: '(Ubuntu|Fedora|Debian)'
+  - regex: '(Linux)'
+
+device_parsers:
+  - regex: '(?:Googlebot|bingbot|Baiduspider|YandexBot|DuckDuckBot|Applebot|facebookexternalhit|Twitterbot|AhrefsBot|SemrushBot|Bytespider|GPTBot|PetalBot)'
+    device_replacement: 'Spider'
+    brand_replacement: 'Spider'
+    model_replacement: 'Desktop'
+  - regex: '[\w.-]*(?:bot|crawler|spider)[/;)]'
+    regex_flag: 'i'
+    device_replacement: 'Spider'
+    brand_replacement: 'Spider'
+    model_replacement: 'Desktop'
+  - regex: '(iPhone|iPad|iPod)'
+    brand_replacement: 'Apple'
+  - regex: 'Macintosh'
+    device_replacement: 'Mac'
+    brand_replacement: 'Apple'
+    model_replacement: 'Mac'
+  - regex: 'Android [\d.]+; (?:[a-zA-Z]{2}-[a-zA-Z]{2}; )?(SM-[A-Z0-9]+)'
+    device_replacement: 'Samsung $1'
+    brand_replacement: 'Samsung'
+    model_replacement: '$1'
+  - regex: 'Android [\d.]+; (?:[a-zA-Z]{2}-[a-zA-Z]{2}; )?([^;)]+?)(?: Build/[^;)]*)?\)'
+    brand_replacement: 'Generic_Android'
+`
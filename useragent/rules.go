@@ -0,0 +1,216 @@
+package useragent
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The parts a rule list yields: the replacement keys and the groups parts
+// without a replacement are taken from, 0 for none.
+type list struct {
+	keys   []string
+	groups []int
+}
+
+var lists = map[string]list{
+	"user_agent_parsers": {
+		[]string{"family_replacement", "v1_replacement", "v2_replacement", "v3_replacement"},
+		[]int{1, 2, 3, 4},
+	},
+	"os_parsers": {
+		[]string{"os_replacement", "os_v1_replacement", "os_v2_replacement", "os_v3_replacement"},
+		[]int{1, 2, 3, 4},
+	},
+	// The brand has no group, the model is the device unless replaced.
+	"device_parsers": {
+		[]string{"device_replacement", "brand_replacement", "model_replacement"},
+		[]int{1, 0, 1},
+	},
+}
+
+type rule struct {
+	regexp       *regexp.Regexp
+	replacements []string
+}
+
+// A mapping of a list and the line it starts on.
+type item struct {
+	line   int
+	values map[string]string
+}
+
+// The rules of a regexes.yaml file by list name.
+type rules map[string][]*rule
+
+// Reads the subset of YAML regexes.yaml files are written in: top level
+// lists of mappings with plain, 'single' or "double" quoted values. Lists
+// other than the three of ua-parser are ignored. Regexes Go can't compile,
+// like those with lookarounds, are left out and returned in skipped.
+func parseRules(data []byte) (all rules, skipped []error, err error) {
+	all = make(rules)
+	var (
+		list    string
+		current *item
+		items   []*item
+		lineNo  int
+	)
+	flush := func() {
+		if current != nil {
+			items = append(items, current)
+			current = nil
+		}
+		l, ok := lists[list]
+		for _, it := range items {
+			if !ok {
+				break
+			}
+			r, err := newRule(it.values, l.keys)
+			if err != nil {
+				skipped = append(skipped, fmt.Errorf("line %d: %s", it.line, err))
+				continue
+			}
+			all[list] = append(all[list], r)
+		}
+		items = nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || trimmed[0] == '#' || line == "---" {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '-' {
+			if !strings.HasSuffix(line, ":") {
+				return nil, nil, fmt.Errorf("line %d: expected a list name", lineNo)
+			}
+			flush()
+			list = strings.TrimSuffix(line, ":")
+			continue
+		}
+		if list == "" {
+			return nil, nil, fmt.Errorf("line %d: item outside a list", lineNo)
+		}
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			if current != nil {
+				items = append(items, current)
+			}
+			current = &item{lineNo, make(map[string]string)}
+			trimmed = strings.TrimLeft(trimmed[1:], " ")
+			if trimmed == "" {
+				continue
+			}
+		} else if current == nil {
+			return nil, nil, fmt.Errorf("line %d: expected a list item", lineNo)
+		}
+		key, value, err := keyValue(trimmed)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %s", lineNo, err)
+		}
+		current.values[key] = value
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	flush()
+	for name := range lists {
+		if len(all[name]) == 0 {
+			return nil, nil, fmt.Errorf("no %s", name)
+		}
+	}
+	return all, skipped, nil
+}
+
+func newRule(item map[string]string, keys []string) (*rule, error) {
+	expr, ok := item["regex"]
+	if !ok {
+		return nil, fmt.Errorf("item without a regex")
+	}
+	if item["regex_flag"] == "i" {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	r := &rule{regexp: re, replacements: make([]string, len(keys))}
+	for i, key := range keys {
+		r.replacements[i] = item[key]
+	}
+	return r, nil
+}
+
+// Splits a "key: value" line.
+func keyValue(line string) (string, string, error) {
+	i := strings.Index(line, ":")
+	if i <= 0 {
+		return "", "", fmt.Errorf("expected key: value")
+	}
+	key, rest := line[:i], strings.TrimLeft(line[i+1:], " ")
+	if rest == "" {
+		return key, "", nil
+	}
+	var value string
+	switch rest[0] {
+	case '\'':
+		var buf bytes.Buffer
+		i := 1
+		for ; i < len(rest); i++ {
+			if rest[i] == '\'' {
+				if i+1 < len(rest) && rest[i+1] == '\'' {
+					buf.WriteByte('\'')
+					i++
+					continue
+				}
+				break
+			}
+			buf.WriteByte(rest[i])
+		}
+		if i == len(rest) {
+			return "", "", fmt.Errorf("unterminated quote")
+		}
+		value, rest = buf.String(), rest[i+1:]
+	case '"':
+		var buf bytes.Buffer
+		i := 1
+		for ; i < len(rest) && rest[i] != '"'; i++ {
+			if rest[i] != '\\' || i+1 == len(rest) {
+				buf.WriteByte(rest[i])
+				continue
+			}
+			i++
+			switch rest[i] {
+			case 'n':
+				buf.WriteByte('\n')
+			case 't':
+				buf.WriteByte('\t')
+			case '"', '\\', '/':
+				buf.WriteByte(rest[i])
+			default:
+				// Left as is, like the \d of regexes.
+				buf.WriteByte('\\')
+				buf.WriteByte(rest[i])
+			}
+		}
+		if i == len(rest) {
+			return "", "", fmt.Errorf("unterminated quote")
+		}
+		value, rest = buf.String(), rest[i+1:]
+	case '|', '>', '[', '{', '&', '*':
+		return "", "", fmt.Errorf("unsupported value %s", rest)
+	default:
+		if i := strings.Index(rest, " #"); i >= 0 {
+			rest = rest[:i]
+		}
+		return key, strings.TrimRight(rest, " "), nil
+	}
+	rest = strings.TrimLeft(rest, " ")
+	if rest != "" && rest[0] != '#' {
+		return "", "", fmt.Errorf("unexpected %s after the value", rest)
+	}
+	return key, value, nil
+}
@@ -0,0 +1,151 @@
+// Package useragent tells the browser, OS and device of User-Agent strings
+// with rules in the regexes.yaml format of ua-parser
+// (https://github.com/ua-parser/uap-core).
+package useragent
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/millken/kaman/lru"
+)
+
+// What a User-Agent was classified as. Families no rule matched are
+// "Other", versions are empty when unknown.
+type Result struct {
+	Browser        string
+	BrowserVersion string
+	OS             string
+	OSVersion      string
+	Device         string
+	Brand          string
+	Model          string
+	// ua-parser puts crawlers, monitors and other robots in the Spider
+	// device family.
+	Bot bool
+}
+
+// Adds the result to data, the fields are prefixed with prefix. Empty
+// versions, brands and models are left out.
+func (this *Result) Fields(prefix string, data map[string]interface{}) {
+	data[prefix+"browser"] = this.Browser
+	data[prefix+"os"] = this.OS
+	data[prefix+"device"] = this.Device
+	data[prefix+"is_bot"] = this.Bot
+	for name, value := range map[string]string{
+		"browser_version": this.BrowserVersion,
+		"os_version":      this.OSVersion,
+		"device_brand":    this.Brand,
+		"device_model":    this.Model,
+	} {
+		if value != "" {
+			data[prefix+name] = value
+		}
+	}
+}
+
+type Parser struct {
+	rules rules
+	cache *lru.Cache
+}
+
+// Returns a parser with the built-in rules, a short list of the common
+// browsers, systems and crawlers. cacheSize is the number of User-Agents
+// whose results are cached.
+func New(cacheSize int) *Parser {
+	rules, _, err := parseRules([]byte(builtinRules))
+	if err != nil {
+		panic(err)
+	}
+	return &Parser{rules: rules, cache: lru.New(cacheSize)}
+}
+
+// Returns a parser with the rules of a regexes.yaml file. The rules Go
+// regexps can't compile are returned in skipped, the others still apply.
+func NewFromFile(path string, cacheSize int) (p *Parser, skipped []error, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	rules, skipped, err := parseRules(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Parser{rules: rules, cache: lru.New(cacheSize)}, skipped, nil
+}
+
+// Classifies the User-Agent. The result is shared by the callers and must
+// not be changed.
+func (this *Parser) Parse(ua string) *Result {
+	if r, ok := this.cache.Get(ua); ok {
+		return r.(*Result)
+	}
+	r := &Result{Browser: "Other", OS: "Other", Device: "Other"}
+	if parts := this.match("user_agent_parsers", ua); parts != nil {
+		r.Browser, r.BrowserVersion = parts[0], version(parts[1:])
+	}
+	if parts := this.match("os_parsers", ua); parts != nil {
+		r.OS, r.OSVersion = parts[0], version(parts[1:])
+	}
+	if parts := this.match("device_parsers", ua); parts != nil {
+		r.Device, r.Brand, r.Model = parts[0], parts[1], parts[2]
+		r.Bot = r.Device == "Spider"
+	}
+	this.cache.Put(ua, r)
+	return r
+}
+
+// Returns the parts of the first rule of the list that matches, the
+// replacements with $1 to $9 expanded or else the groups of the list. Nil
+// when no rule matches or it yields no family.
+func (this *Parser) match(list, ua string) []string {
+	for _, r := range this.rules[list] {
+		groups := r.regexp.FindStringSubmatch(ua)
+		if groups == nil {
+			continue
+		}
+		parts := make([]string, len(r.replacements))
+		for i, replacement := range r.replacements {
+			group := lists[list].groups[i]
+			switch {
+			case replacement != "":
+				parts[i] = strings.TrimSpace(expand(replacement, groups))
+			case group > 0 && group < len(groups):
+				parts[i] = strings.TrimSpace(groups[group])
+			}
+		}
+		if parts[0] == "" {
+			return nil
+		}
+		return parts
+	}
+	return nil
+}
+
+func expand(replacement string, groups []string) string {
+	if strings.IndexByte(replacement, '$') < 0 {
+		return replacement
+	}
+	var expanded []byte
+	for i := 0; i < len(replacement); i++ {
+		c := replacement[i]
+		if c == '$' && i+1 < len(replacement) && replacement[i+1] >= '1' && replacement[i+1] <= '9' {
+			if n := int(replacement[i+1] - '0'); n < len(groups) {
+				expanded = append(expanded, groups[n]...)
+			}
+			i++
+			continue
+		}
+		expanded = append(expanded, c)
+	}
+	return string(expanded)
+}
+
+// Joins the major, minor and patch versions up to the first missing one.
+func version(parts []string) string {
+	n := 0
+	for n < len(parts) && parts[n] != "" {
+		n++
+	}
+	return strings.Join(parts[:n], ".")
+}
@@ -0,0 +1,167 @@
+package useragent
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestBuiltinRules(t *testing.T) {
+	if _, skipped, err := parseRules([]byte(builtinRules)); err != nil || len(skipped) != 0 {
+		t.Fatalf("built-in rules: %v %v", skipped, err)
+	}
+	p := New(10)
+	for ua, want := range map[string]Result{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.130 Safari/537.36": {
+			Browser: "Chrome", BrowserVersion: "120.0.6099", OS: "Windows", OSVersion: "10", Device: "Other",
+		},
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91": {
+			Browser: "Edge", BrowserVersion: "120.0.2210", OS: "Windows", OSVersion: "10", Device: "Other",
+		},
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1.2 Mobile/15E148 Safari/604.1": {
+			Browser: "Mobile Safari", BrowserVersion: "17.1.2", OS: "iOS", OSVersion: "17.1.2",
+			Device: "iPhone", Brand: "Apple", Model: "iPhone",
+		},
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15": {
+			Browser: "Safari", BrowserVersion: "17.1", OS: "Mac OS X", OSVersion: "10.15.7",
+			Device: "Mac", Brand: "Apple", Model: "Mac",
+		},
+		"Mozilla/5.0 (Linux; Android 13; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36": {
+			Browser: "Chrome Mobile", BrowserVersion: "120.0.0", OS: "Android", OSVersion: "13",
+			Device: "Samsung SM-S918B", Brand: "Samsung", Model: "SM-S918B",
+		},
+		// Not a bot despite the name.
+		"Mozilla/5.0 (Linux; Android 9; CUBOT_X19 Build/PPR1) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36": {
+			Browser: "Chrome Mobile", BrowserVersion: "120.0.0", OS: "Android", OSVersion: "9",
+			Device: "CUBOT_X19", Brand: "Generic_Android", Model: "CUBOT_X19",
+		},
+		"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0": {
+			Browser: "Firefox", BrowserVersion: "121.0", OS: "Ubuntu", Device: "Other",
+		},
+		"Mozilla/5.0 (Windows NT 6.1; Trident/7.0; rv:11.0) like Gecko": {
+			Browser: "IE", BrowserVersion: "11.0", OS: "Windows", OSVersion: "7", Device: "Other",
+		},
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)": {
+			Browser: "Googlebot", BrowserVersion: "2.1", OS: "Other",
+			Device: "Spider", Brand: "Spider", Model: "Desktop", Bot: true,
+		},
+		"Mozilla/5.0 (compatible; MJ12bot/v1.4.8; http://mj12bot.com/)": {
+			Browser: "MJ12bot", BrowserVersion: "1.4", OS: "Other",
+			Device: "Spider", Brand: "Spider", Model: "Desktop", Bot: true,
+		},
+		"curl/8.4.0": {Browser: "curl", BrowserVersion: "8.4.0", OS: "Other", Device: "Other"},
+		"-":          {Browser: "Other", OS: "Other", Device: "Other"},
+	} {
+		if got := p.Parse(ua); *got != want {
+			t.Errorf("%s:\ngot  %+v\nwant %+v", ua, *got, want)
+		}
+	}
+}
+
+// Written like the uap-core file.
+const testRules = `
+user_agent_parsers:
+  #### SPECIAL CASES ####
+  - regex: '(ESPN)[%20| ]+Radio/(\d+)\.(\d+)\.(\d+) CFNetwork'
+  - regex: "(Pale[Mm]oon)/(\\d+)\\.(\\d+)"
+    family_replacement: 'Pale Moon'  # trailing comment
+  - regex: '^(Opera)/(\d+)\.(\d+) \(Nintendo Wii'
+    family_replacement: 'Wii $1'
+    v1_replacement: 9
+  # Go regexps have no lookaheads.
+  - regex: '(Chrome)/(?!1)(\d+)'
+  - regex: 'Foo ''Bar'' (\d+)'
+    family_replacement: 'It''s Bar'
+
+os_parsers:
+  - regex: '(Windows NT 10\.0)'
+    os_replacement: 'Windows'
+    os_v1_replacement: '10'
+
+device_parsers:
+  - regex: '(?:bot|spider)'
+    regex_flag: 'i'
+    device_replacement: 'Spider'
+  - regex: '; (\w+) Build/'
+    device_replacement: '$1 phone'
+    brand_replacement: 'Generic'
+
+other_list:
+  - name: ignored
+`
+
+func TestRuleFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "regexes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(testRules)
+	f.Close()
+
+	p, skipped, err := NewFromFile(f.Name(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 1 {
+		t.Errorf("skipped %v", skipped)
+	}
+	for ua, want := range map[string]Result{
+		"ESPN%20Radio/3.2.1 CFNetwork": {Browser: "ESPN", BrowserVersion: "3.2.1", OS: "Other", Device: "Other"},
+		"Mozilla/5.0 (Windows NT 10.0; rv:4.0) PaleMoon/28.1": {
+			Browser: "Pale Moon", BrowserVersion: "28.1", OS: "Windows", OSVersion: "10", Device: "Other",
+		},
+		"Opera/9.30 (Nintendo Wii; U; ; 2071; Wii Shop Channel/1.0; en)": {
+			Browser: "Wii Opera", BrowserVersion: "9.30", OS: "Other", Device: "Other",
+		},
+		"Foo 'Bar' 1":          {Browser: "It's Bar", OS: "Other", Device: "Other"},
+		"Chrome/99 SomeSpider": {Browser: "Other", OS: "Other", Device: "Spider", Bot: true},
+		"Mozilla/5.0 (Linux; U; Android 4.0; X1 Build/1)": {
+			Browser: "Other", OS: "Other", Device: "X1 phone", Brand: "Generic", Model: "X1",
+		},
+	} {
+		if got := p.Parse(ua); *got != want {
+			t.Errorf("%s:\ngot  %+v\nwant %+v", ua, *got, want)
+		}
+	}
+
+	for _, rules := range []string{
+		"user_agent_parsers:\n  - regex: 'unterminated\n",
+		"  - regex: 'a'\n",
+		"user_agent_parsers:\n  - regex: |\n      a\n",
+		// The OS and device lists are missing.
+		"user_agent_parsers:\n  - regex: '(a)'\n",
+	} {
+		if _, _, err := parseRules([]byte(rules)); err == nil {
+			t.Errorf("%q parsed", rules)
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	p := New(1)
+	r := p.Parse("curl/8.4.0")
+	if p.Parse("curl/8.4.0") != r {
+		t.Error("not cached")
+	}
+	p.Parse("Wget/1.21")
+	if p.Parse("curl/8.4.0") == r {
+		t.Error("not evicted")
+	}
+}
+
+func TestFields(t *testing.T) {
+	data := make(map[string]interface{})
+	(&Result{Browser: "Chrome", BrowserVersion: "120.0", OS: "Windows", Device: "Other"}).Fields("ua_", data)
+	want := map[string]interface{}{
+		"ua_browser":         "Chrome",
+		"ua_browser_version": "120.0",
+		"ua_os":              "Windows",
+		"ua_device":          "Other",
+		"ua_is_bot":          false,
+	}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("got %v", data)
+	}
+}